@@ -80,4 +80,10 @@ Create the name of the minio configmap
 */}}
 {{- define "vinventory.minioConfigMapName" -}}
 {{- printf "%s-minio-config" (include "vinventory.fullname" .) }}
-{{- end }}
\ No newline at end of file
+{{- end }}
+{{/*
+Create the name of the rbac configmap
+*/}}
+{{- define "vinventory.rbacConfigMapName" -}}
+{{- printf "%s-rbac-config" (include "vinventory.fullname" .) }}
+{{- end }}
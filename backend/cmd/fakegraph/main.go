@@ -0,0 +1,74 @@
+// Command fakegraph runs a stand-in for the Microsoft identity platform and Microsoft Graph
+// serving the users of a directory file, for demos and integration tests without Azure AD:
+//
+//	fakegraph -directory directory.example.yaml -addr :9999
+//
+// Start vinventory with AZURE_LOGIN_URL and GRAPH_URL set to the printed URL, and sign in by
+// storing a token from POST /{tenant}/fakegraph/id-token?user={id or e-mail} as id_token.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"vinventory/internal/identity"
+	"vinventory/internal/identity/fakegraph"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:9999", "address to listen on")
+	baseURL := flag.String("url", "", "URL the server is reached at (default http://<addr>)")
+	directoryFile := flag.String("directory", "directory.example.yaml", "YAML or JSON directory file with the users")
+	photos := flag.String("photos", "", "directory of <user id>.jpg photos")
+	tenantID := flag.String("tenant", "00000000-0000-0000-0000-00000000fa6e", "tenant ID")
+	clientID := flag.String("client-id", "vinventory", "client ID of the app registration")
+	clientSecret := flag.String("client-secret", "fakegraph-secret", "client secret of the app registration")
+	flag.Parse()
+
+	if *baseURL == "" {
+		*baseURL = "http://" + *addr
+	}
+
+	directory, err := identity.LoadDirectoryFile(*directoryFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	userPhotos := map[string][]byte{}
+	if *photos != "" {
+		files, err := filepath.Glob(filepath.Join(*photos, "*.jpg"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, file := range files {
+			photo, err := os.ReadFile(file)
+			if err != nil {
+				log.Fatal(err)
+			}
+			userPhotos[strings.TrimSuffix(filepath.Base(file), ".jpg")] = photo
+		}
+	}
+
+	server, err := fakegraph.New(fakegraph.Config{
+		URL:          *baseURL,
+		TenantID:     *tenantID,
+		ClientID:     *clientID,
+		ClientSecret: *clientSecret,
+		Directory:    directory,
+		Photos:       userPhotos,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("Serving %d users on %s; start vinventory with:", len(directory.Users), *baseURL)
+	log.Printf("  AZURE_LOGIN_URL=%s GRAPH_URL=%s AZURE_TENANT_ID=%s AZURE_CLIENT_ID=%s AZURE_CLIENT_SECRET=%s",
+		*baseURL, *baseURL, *tenantID, *clientID, *clientSecret)
+	if err := http.ListenAndServe(*addr, server); err != nil {
+		log.Fatal(err)
+	}
+}
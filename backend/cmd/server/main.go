@@ -1,15 +1,25 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"time"
 	_ "vinventory/docs" // Swagger docs
+	"vinventory/internal/auth"
 	"vinventory/internal/config"
+	"vinventory/internal/identity"
+	"vinventory/internal/lifecycle"
 	"vinventory/internal/middleware"
+	"vinventory/internal/migrate"
 	email "vinventory/internal/notifications"
+	"vinventory/internal/repository/sqlstore"
 	"vinventory/internal/routes"
+	"vinventory/migrations"
+
+	"gorm.io/gorm"
 )
 
 // @title Vinventory API
@@ -36,9 +46,120 @@ func recordMetrics() {
 	}()
 }
 
+// newMigrator returns a migrator for the migrations of the database in use
+func newMigrator(database *gorm.DB) (*migrate.Migrator, error) {
+	files, err := migrations.For(database.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	return migrate.New(database, files)
+}
+
+// newIdentityProvider returns the identity provider selected by cfg.IdentityProvider
+func newIdentityProvider(cfg config.Config) (identity.Provider, error) {
+	token := auth.VerifierConfig{Audiences: cfg.TokenAudiences, Issuer: cfg.TokenIssuer, Leeway: cfg.TokenLeeway}
+
+	var directory *identity.Directory
+	if cfg.DirectoryFile != "" {
+		var err error
+		if directory, err = identity.LoadDirectoryFile(cfg.DirectoryFile); err != nil {
+			return nil, err
+		}
+	}
+
+	switch cfg.IdentityProvider {
+	case "azure":
+		return identity.NewAzure(identity.AzureConfig{
+			TenantID:     cfg.AzureTenantID,
+			ClientID:     cfg.AzureClientID,
+			ClientSecret: cfg.AzureClientSecret,
+			LoginURL:     cfg.AzureLoginURL,
+			GraphURL:     cfg.GraphURL,
+			Token:        token,
+		})
+	case "oidc":
+		return identity.NewOIDC(identity.OIDCConfig{
+			Issuer:     cfg.OIDCIssuer,
+			ClientID:   cfg.OIDCClientID,
+			IDClaim:    cfg.OIDCUserIDClaim,
+			Token:      token,
+			Directory:  directory,
+			UsersURL:   cfg.OIDCUsersURL,
+			UsersToken: cfg.OIDCUsersToken,
+		})
+	case "static":
+		return identity.NewStatic(identity.StaticConfig{
+			Directory:  directory,
+			SigningKey: []byte(cfg.StaticSigningKey),
+			Leeway:     cfg.TokenLeeway,
+		})
+	default:
+		return nil, fmt.Errorf("unsupported IDENTITY_PROVIDER %q (use azure, oidc or static)", cfg.IdentityProvider)
+	}
+}
+
+// signToken prints a token of the static provider for a user of the directory file:
+// vinventory token <user ID or e-mail> [validity, 12h by default]
+func signToken(cfg config.Config, args []string) error {
+	if cfg.IdentityProvider != "static" {
+		return errors.New("tokens can only be signed with IDENTITY_PROVIDER=static")
+	}
+	if len(args) < 1 || len(args) > 2 {
+		return errors.New("usage: vinventory token <user ID or e-mail> [validity, e.g. 12h]")
+	}
+	validity := 12 * time.Hour
+	if len(args) == 2 {
+		var err error
+		if validity, err = time.ParseDuration(args[1]); err != nil {
+			return fmt.Errorf("invalid validity: %w", err)
+		}
+	}
+
+	provider, err := newIdentityProvider(cfg)
+	if err != nil {
+		return err
+	}
+	token, err := provider.(*identity.Static).Sign(args[0], validity)
+	if err != nil {
+		return err
+	}
+	fmt.Println(token)
+	return nil
+}
+
 func main() {
 	cfg := config.LoadConfig()
 
+	if cfg.LifecycleConfig != "" {
+		componentLifecycle, err := lifecycle.LoadFile(cfg.LifecycleConfig)
+		if err != nil {
+			log.Fatal(err)
+		}
+		lifecycle.Use(componentLifecycle)
+	}
+
+	policy := auth.DefaultPolicy()
+	if cfg.RBACConfig != "" {
+		var err error
+		if policy, err = auth.LoadPolicyFile(cfg.RBACConfig); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if cfg.RBACDefaultRoles != nil {
+		policy.DefaultRoles = cfg.RBACDefaultRoles
+		if err := policy.Validate(); err != nil {
+			log.Fatalf("Invalid RBAC_DEFAULT_ROLES: %s", err.Error())
+		}
+	}
+	auth.UsePolicy(policy)
+
+	if len(os.Args) > 1 && os.Args[1] == "token" {
+		if err := signToken(cfg, os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	database, err := config.InitDatabase(cfg)
 	if err != nil {
 		log.Fatal(err)
@@ -46,7 +167,37 @@ func main() {
 
 	if len(os.Args) > 1 && os.Args[1] == "notification_job" {
 		email.NotifyExpiringWarranties(database, cfg)
+	} else if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		migrator, err := newMigrator(database)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := migrate.Run(migrator, os.Args[2:], os.Stdout); err != nil {
+			log.Fatal(err)
+		}
 	} else {
+		if cfg.DBSchemaCheck {
+			migrator, err := newMigrator(database)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if err := migrator.Check(); err != nil {
+				log.Fatalf("Refusing to start: %s (run \"vinventory migrate up\")", err.Error())
+			}
+		}
+
+		provider, err := newIdentityProvider(cfg)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer provider.Close()
+		identity.Use(provider)
+
+		// Holder searches on Postgres follow the in-use statuses of the lifecycle in use
+		if err := sqlstore.New(database).SyncInUseStatuses(lifecycle.Current().InUse); err != nil {
+			log.Printf("Failed to store the in-use statuses of the lifecycle: %s", err.Error())
+		}
+
 		recordMetrics()
 
 		// Set up the router
@@ -0,0 +1,399 @@
+// Package attributes defines and enforces the attribute schema of component types.
+//
+// A ComponentType declares an ordered list of attribute definitions. Definitions whose
+// name matches one of the fixed Component fields (brand, ram, warrantyEndDate...) describe
+// that column; every other definition is a custom attribute stored in Component.Attributes.
+package attributes
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"vinventory/internal/lifecycle"
+	"vinventory/internal/models"
+)
+
+// DateLayout is the layout accepted for date attributes besides RFC 3339.
+const DateLayout = "2006-01-02"
+
+type builtIn struct {
+	column     string
+	definition models.AttributeDefinition
+}
+
+// builtIns lists the fixed Component fields in the order forms present them.
+// The allowed values of status come from the lifecycle in use.
+var builtIns = []builtIn{
+	{"serial_number", models.AttributeDefinition{Name: "serialNumber", Type: models.AttributeString, Required: true}},
+	{"status", models.AttributeDefinition{Name: "status", Type: models.AttributeEnum}},
+	{"brand", models.AttributeDefinition{Name: "brand", Type: models.AttributeString}},
+	{"model", models.AttributeDefinition{Name: "model", Type: models.AttributeString}},
+	{"model_year", models.AttributeDefinition{Name: "modelYear", Type: models.AttributeInt}},
+	{"condition", models.AttributeDefinition{Name: "condition", Type: models.AttributeEnum, AllowedValues: []string{"Functioning", "Slightly Damaged", "Broken"}}},
+	{"screen_size", models.AttributeDefinition{Name: "screenSize", Type: models.AttributeString, Unit: "inch"}},
+	{"resolution", models.AttributeDefinition{Name: "resolution", Type: models.AttributeString}},
+	{"processor_type", models.AttributeDefinition{Name: "processorType", Type: models.AttributeString}},
+	{"processor_cores", models.AttributeDefinition{Name: "processorCores", Type: models.AttributeInt}},
+	{"ram", models.AttributeDefinition{Name: "ram", Type: models.AttributeInt, Unit: "GB"}},
+	{"warranty_end_date", models.AttributeDefinition{Name: "warrantyEndDate", Type: models.AttributeDate, Required: true}},
+	{"notes", models.AttributeDefinition{Name: "notes", Type: models.AttributeString}},
+}
+
+// mandatory attributes are part of every component type and are always required.
+var mandatory = []string{"warrantyEndDate", "serialNumber"}
+
+var validTypes = map[models.AttributeType]bool{
+	models.AttributeString:  true,
+	models.AttributeInt:     true,
+	models.AttributeDecimal: true,
+	models.AttributeDate:    true,
+	models.AttributeBool:    true,
+	models.AttributeEnum:    true,
+}
+
+// ValidationError lists every problem found while validating a schema or a component.
+type ValidationError struct {
+	Problems []string `json:"problems"`
+}
+
+func (e *ValidationError) Error() string {
+	return strings.Join(e.Problems, "; ")
+}
+
+func (e *ValidationError) add(format string, args ...interface{}) {
+	e.Problems = append(e.Problems, fmt.Sprintf(format, args...))
+}
+
+func (e *ValidationError) orNil() error {
+	if len(e.Problems) == 0 {
+		return nil
+	}
+	return e
+}
+
+// BuiltIns returns the default definitions of the fixed Component fields.
+func BuiltIns() models.AttributeSchema {
+	schema := make(models.AttributeSchema, 0, len(builtIns))
+	for _, b := range builtIns {
+		schema = append(schema, copyDefinition(b.definition))
+	}
+	return schema
+}
+
+// IsBuiltIn reports whether name refers to a fixed Component field.
+func IsBuiltIn(name string) bool {
+	_, ok := Column(name)
+	return ok
+}
+
+// Column returns the column of a fixed Component field, accepting either its API
+// name (modelYear) or its column name (model_year).
+func Column(name string) (string, bool) {
+	for _, b := range builtIns {
+		if b.definition.Name == name || b.column == name {
+			return b.column, true
+		}
+	}
+	return "", false
+}
+
+// IsMandatory reports whether every component type must declare the named attribute.
+func IsMandatory(name string) bool {
+	for _, m := range mandatory {
+		if m == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Custom returns the definitions of schema that are not fixed Component fields.
+func Custom(schema models.AttributeSchema) models.AttributeSchema {
+	var custom models.AttributeSchema
+	for _, definition := range schema {
+		if !IsBuiltIn(definition.Name) {
+			custom = append(custom, definition)
+		}
+	}
+	return custom
+}
+
+// Normalize validates a schema submitted by a client and fills in defaults.
+//
+// names is the legacy list of bare attribute names; each one without a definition in
+// schema gets the built-in definition or, for custom names, an optional string.
+// Built-in fields keep their data type, and the mandatory warrantyEndDate and
+// serialNumber attributes are added as required if the client left them out.
+func Normalize(schema models.AttributeSchema, names []string) (models.AttributeSchema, error) {
+	problems := &ValidationError{}
+
+	merged := make(models.AttributeSchema, 0, len(schema)+len(names))
+	merged = append(merged, schema...)
+	for _, name := range names {
+		if _, ok := merged.Find(name); !ok {
+			merged = append(merged, models.AttributeDefinition{Name: name})
+		}
+	}
+	for _, name := range mandatory {
+		if _, ok := merged.Find(name); !ok {
+			merged = append(merged, models.AttributeDefinition{Name: name})
+		}
+	}
+
+	seen := make(map[string]bool)
+	normalized := make(models.AttributeSchema, 0, len(merged))
+	for _, definition := range merged {
+		definition.Name = strings.TrimSpace(definition.Name)
+		if definition.Name == "" {
+			problems.add("attribute name is required")
+			continue
+		}
+		if seen[definition.Name] {
+			problems.add("attribute %q is declared more than once", definition.Name)
+			continue
+		}
+		seen[definition.Name] = true
+
+		if base, ok := builtInDefinition(definition.Name); ok {
+			if definition.Type != "" && definition.Type != base.Type {
+				problems.add("attribute %q is a component field of type %s", definition.Name, base.Type)
+			}
+			definition.Type = base.Type
+			if len(definition.AllowedValues) == 0 {
+				definition.AllowedValues = base.AllowedValues
+			}
+			if definition.Unit == "" {
+				definition.Unit = base.Unit
+			}
+			definition.Required = definition.Required || IsMandatory(definition.Name)
+		} else if definition.Type == "" {
+			definition.Type = models.AttributeString
+		}
+
+		if !validTypes[definition.Type] {
+			problems.add("attribute %q has unknown type %q", definition.Name, definition.Type)
+		}
+		if definition.Type == models.AttributeEnum && len(definition.AllowedValues) == 0 {
+			problems.add("enum attribute %q needs allowedValues", definition.Name)
+		}
+		if definition.Pattern != "" {
+			if _, err := regexp.Compile(definition.Pattern); err != nil {
+				problems.add("attribute %q has an invalid pattern: %s", definition.Name, err.Error())
+			}
+		}
+
+		normalized = append(normalized, definition)
+	}
+
+	if err := problems.orNil(); err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
+
+// Validate checks a component against the attribute schema of its type, including inherited definitions.
+// Declared fields must satisfy their definition, custom attributes the type does not
+// declare are rejected and required attributes must be present.
+func Validate(componentType models.ComponentType, component models.Component) error {
+	problems := &ValidationError{}
+
+	for name := range component.Attributes {
+		if IsBuiltIn(name) {
+			problems.add("attribute %q is a component field and cannot be set in attributes", name)
+		} else if _, ok := componentType.Definitions().Find(name); !ok {
+			problems.add("attribute %q is not declared by type %q", name, componentType.Name)
+		}
+	}
+
+	for _, definition := range componentType.Definitions() {
+		var value interface{}
+		if base, ok := builtInDefinition(definition.Name); ok {
+			// The column fixes the data type of built-in fields and the lifecycle owns the statuses
+			definition.Type = base.Type
+			if definition.Name == "status" {
+				definition.AllowedValues = base.AllowedValues
+			}
+			value = builtInValue(component, definition.Name)
+		} else {
+			value = component.Attributes[definition.Name]
+		}
+
+		if isEmpty(value) {
+			if definition.Required {
+				problems.add("attribute %q is required", definition.Name)
+			}
+			continue
+		}
+
+		if err := checkValue(definition, value); err != nil {
+			problems.add("attribute %q %s", definition.Name, err.Error())
+		}
+	}
+
+	sort.Strings(problems.Problems)
+	return problems.orNil()
+}
+
+// checkValue verifies a single non-empty value against its definition.
+func checkValue(definition models.AttributeDefinition, value interface{}) error {
+	var text string
+	switch definition.Type {
+	case models.AttributeString, models.AttributeEnum:
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("must be a string")
+		}
+		text = s
+	case models.AttributeInt:
+		switch v := value.(type) {
+		case int:
+			text = strconv.Itoa(v)
+		case float64:
+			if v != float64(int64(v)) {
+				return fmt.Errorf("must be a whole number")
+			}
+			text = strconv.FormatInt(int64(v), 10)
+		default:
+			return fmt.Errorf("must be a whole number")
+		}
+	case models.AttributeDecimal:
+		v, ok := value.(float64)
+		if !ok {
+			return fmt.Errorf("must be a number")
+		}
+		text = strconv.FormatFloat(v, 'f', -1, 64)
+	case models.AttributeDate:
+		switch v := value.(type) {
+		case time.Time:
+			text = v.Format(DateLayout)
+		case string:
+			if _, err := ParseDate(v); err != nil {
+				return fmt.Errorf("must be a date (YYYY-MM-DD)")
+			}
+			text = v
+		default:
+			return fmt.Errorf("must be a date (YYYY-MM-DD)")
+		}
+	case models.AttributeBool:
+		v, ok := value.(bool)
+		if !ok {
+			return fmt.Errorf("must be true or false")
+		}
+		text = strconv.FormatBool(v)
+	}
+
+	if len(definition.AllowedValues) > 0 && !contains(definition.AllowedValues, text) {
+		return fmt.Errorf("must be one of: %s", strings.Join(definition.AllowedValues, ", "))
+	}
+	if definition.Pattern != "" {
+		pattern, err := regexp.Compile(definition.Pattern)
+		if err == nil && !pattern.MatchString(text) {
+			return fmt.Errorf("does not match pattern %s", definition.Pattern)
+		}
+	}
+
+	return nil
+}
+
+// ParseDate parses a date attribute given either as YYYY-MM-DD or RFC 3339.
+func ParseDate(value string) (time.Time, error) {
+	if t, err := time.Parse(DateLayout, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// Value returns the value of an attribute of a component, given the API or column name of
+// a fixed Component field or the name of a custom attribute. It returns nil when the value is unset.
+func Value(component models.Component, name string) interface{} {
+	for _, b := range builtIns {
+		if b.definition.Name == name || b.column == name {
+			return builtInValue(component, b.definition.Name)
+		}
+	}
+	return component.Attributes[name]
+}
+
+// builtInValue returns the value of a fixed Component field, or nil when it is unset.
+func builtInValue(component models.Component, name string) interface{} {
+	intValue := func(v *int) interface{} {
+		if v == nil {
+			return nil
+		}
+		return *v
+	}
+
+	switch name {
+	case "status":
+		return component.Status
+	case "brand":
+		return component.Brand
+	case "model":
+		return component.Model
+	case "modelYear":
+		return intValue(component.ModelYear)
+	case "screenSize":
+		return component.ScreenSize
+	case "resolution":
+		return component.Resolution
+	case "processorType":
+		return component.ProcessorType
+	case "processorCores":
+		return intValue(component.ProcessorCores)
+	case "ram":
+		return intValue(component.RAM)
+	case "warrantyEndDate":
+		if component.WarrantyEndDate.IsZero() {
+			return nil
+		}
+		return component.WarrantyEndDate
+	case "serialNumber":
+		return component.SerialNumber
+	case "condition":
+		return component.Condition
+	case "notes":
+		return component.Notes
+	}
+	return nil
+}
+
+func builtInDefinition(name string) (models.AttributeDefinition, bool) {
+	for _, b := range builtIns {
+		if b.definition.Name == name {
+			return copyDefinition(b.definition), true
+		}
+	}
+	return models.AttributeDefinition{}, false
+}
+
+func copyDefinition(definition models.AttributeDefinition) models.AttributeDefinition {
+	if definition.Name == "status" {
+		definition.AllowedValues = lifecycle.Current().Statuses
+	}
+	definition.AllowedValues = append([]string(nil), definition.AllowedValues...)
+	return definition
+}
+
+func isEmpty(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return strings.TrimSpace(v) == ""
+	default:
+		return false
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package attributes
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"vinventory/internal/models"
+)
+
+// FieldChange is the value of a field before and after an edit; nil stands for an unset value.
+type FieldChange struct {
+	Field    string
+	OldValue *string
+	NewValue *string
+}
+
+// Changes lists the fields whose value differs between two states of a component: its type,
+// the fixed fields in form order and then the custom attributes by name.
+func Changes(before, after models.Component) []FieldChange {
+	var changes []FieldChange
+	add := func(field string, old, new interface{}) {
+		oldValue, newValue := formatValue(old), formatValue(new)
+		if !sameValue(oldValue, newValue) {
+			changes = append(changes, FieldChange{Field: field, OldValue: oldValue, NewValue: newValue})
+		}
+	}
+
+	add("typeId", before.TypeID, after.TypeID)
+	for _, b := range builtIns {
+		name := b.definition.Name
+		add(name, builtInValue(before, name), builtInValue(after, name))
+	}
+
+	names := make([]string, 0, len(before.Attributes)+len(after.Attributes))
+	for name := range before.Attributes {
+		names = append(names, name)
+	}
+	for name := range after.Attributes {
+		if _, ok := before.Attributes[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		add(name, before.Attributes[name], after.Attributes[name])
+	}
+	return changes
+}
+
+// formatValue renders a field value as recorded in the change history; empty values are unset.
+func formatValue(value interface{}) *string {
+	if isEmpty(value) {
+		return nil
+	}
+
+	var text string
+	switch v := value.(type) {
+	case time.Time:
+		text = v.Format(DateLayout)
+	case float64:
+		// Custom attribute numbers are decoded from JSON as float64
+		text = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		text = fmt.Sprint(v)
+	}
+	return &text
+}
+
+func sameValue(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,231 @@
+package attributes
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"vinventory/internal/models"
+)
+
+// Impact describes what a schema change does to one component.
+type Impact struct {
+	ComponentID  int    `json:"componentId"`
+	SerialNumber string `json:"serialNumber"`
+	// Changed is set when the stored attribute values are rewritten.
+	Changed bool `json:"changed"`
+	// Conflicts lists values that would be lost; applying the change requires force.
+	Conflicts []string `json:"conflicts,omitempty"`
+	// Missing lists required attributes the component has no value for.
+	Missing []string `json:"missing,omitempty"`
+	// Values are the migrated custom attribute values.
+	Values models.AttributeValues `json:"-"`
+}
+
+// Diff compares two versions of a schema. renames maps old custom attribute names to
+// their new names; renamed attributes are neither added nor removed.
+func Diff(previous, updated models.AttributeSchema, renames map[string]string) (models.SchemaChange, error) {
+	problems := &ValidationError{}
+	change := models.SchemaChange{Renamed: map[string]string{}}
+
+	renamedTo := make(map[string]string)
+	for from, to := range renames {
+		switch {
+		case IsBuiltIn(from) || IsBuiltIn(to):
+			problems.add("cannot rename %q to %q: only custom attributes can be renamed", from, to)
+		case from == to:
+		default:
+			if _, ok := previous.Find(from); !ok {
+				problems.add("cannot rename %q: the type does not declare it", from)
+			} else if _, ok := updated.Find(to); !ok {
+				problems.add("cannot rename %q to %q: the new schema does not declare %q", from, to, to)
+			} else if _, ok := previous.Find(to); ok {
+				problems.add("cannot rename %q to %q: %q already exists", from, to, to)
+			} else {
+				change.Renamed[from] = to
+				renamedTo[to] = from
+			}
+		}
+	}
+
+	for _, definition := range previous {
+		if _, renamed := change.Renamed[definition.Name]; renamed {
+			continue
+		}
+		if _, ok := updated.Find(definition.Name); !ok {
+			change.Removed = append(change.Removed, definition.Name)
+		}
+	}
+
+	for _, definition := range updated {
+		previousName := definition.Name
+		if from, renamed := renamedTo[definition.Name]; renamed {
+			previousName = from
+		}
+
+		before, ok := previous.Find(previousName)
+		if !ok {
+			change.Added = append(change.Added, definition.Name)
+		} else if before.Type != definition.Type {
+			change.Retyped = append(change.Retyped, definition.Name)
+		}
+	}
+
+	if err := problems.orNil(); err != nil {
+		return models.SchemaChange{}, err
+	}
+	return change, nil
+}
+
+// Plan works out how a component is affected by moving its type to the updated schema.
+func Plan(component models.Component, change models.SchemaChange, updated models.AttributeSchema) Impact {
+	impact := Impact{
+		ComponentID:  component.ID,
+		SerialNumber: component.SerialNumber,
+		Values:       models.AttributeValues{},
+	}
+
+	for name, value := range component.Attributes {
+		impact.Values[name] = value
+	}
+
+	for from, to := range change.Renamed {
+		if value, ok := impact.Values[from]; ok {
+			delete(impact.Values, from)
+			impact.Values[to] = value
+			impact.Changed = true
+		}
+	}
+
+	for _, name := range change.Removed {
+		if IsBuiltIn(name) {
+			// Column values stay in place, the type just stops presenting them
+			if !isEmpty(builtInValue(component, name)) {
+				impact.Conflicts = append(impact.Conflicts, fmt.Sprintf("removed attribute %q still holds a value", name))
+			}
+			continue
+		}
+		if value, ok := impact.Values[name]; ok {
+			if !isEmpty(value) {
+				impact.Conflicts = append(impact.Conflicts, fmt.Sprintf("removed attribute %q still holds a value", name))
+			}
+			delete(impact.Values, name)
+			impact.Changed = true
+		}
+	}
+
+	for _, definition := range Custom(updated) {
+		value, ok := impact.Values[definition.Name]
+		if !ok || isEmpty(value) {
+			if definition.Required {
+				impact.Missing = append(impact.Missing, definition.Name)
+			}
+			continue
+		}
+
+		converted, err := convert(value, definition.Type)
+		if err == nil {
+			err = checkValue(definition, converted)
+		}
+		if err != nil {
+			impact.Conflicts = append(impact.Conflicts, fmt.Sprintf("value %v of %q %s", value, definition.Name, err.Error()))
+			delete(impact.Values, definition.Name)
+			impact.Changed = true
+			continue
+		}
+		if converted != value {
+			impact.Values[definition.Name] = converted
+			impact.Changed = true
+		}
+	}
+
+	sort.Strings(impact.Conflicts)
+	sort.Strings(impact.Missing)
+	return impact
+}
+
+// convert turns a stored value into the JSON representation of the target type.
+func convert(value interface{}, target models.AttributeType) (interface{}, error) {
+	text := fmt.Sprint(value)
+	if f, ok := value.(float64); ok {
+		text = strconv.FormatFloat(f, 'f', -1, 64)
+	}
+
+	switch target {
+	case models.AttributeString, models.AttributeEnum:
+		return text, nil
+	case models.AttributeInt:
+		if f, ok := value.(float64); ok {
+			return f, nil
+		}
+		n, err := strconv.ParseInt(strings.TrimSpace(text), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("cannot be converted to a whole number")
+		}
+		return float64(n), nil
+	case models.AttributeDecimal:
+		if f, ok := value.(float64); ok {
+			return f, nil
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+		if err != nil {
+			return nil, fmt.Errorf("cannot be converted to a number")
+		}
+		return f, nil
+	case models.AttributeBool:
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+		b, err := strconv.ParseBool(strings.TrimSpace(text))
+		if err != nil {
+			return nil, fmt.Errorf("cannot be converted to true or false")
+		}
+		return b, nil
+	case models.AttributeDate:
+		date, err := ParseDate(strings.TrimSpace(text))
+		if err != nil {
+			return nil, fmt.Errorf("cannot be converted to a date")
+		}
+		return date.Format(DateLayout), nil
+	}
+	return value, nil
+}
+
+// MergeChange describes moving components from the source schema to the target schema.
+// mapping maps source custom attribute names to target ones; unmapped custom attributes
+// keep their name. Source attributes the target does not declare are removed.
+func MergeChange(source, target models.AttributeSchema, mapping map[string]string) (models.SchemaChange, error) {
+	problems := &ValidationError{}
+	change := models.SchemaChange{Renamed: map[string]string{}}
+
+	for from, to := range mapping {
+		switch {
+		case IsBuiltIn(from) || IsBuiltIn(to):
+			problems.add("cannot map %q to %q: only custom attributes can be mapped", from, to)
+		default:
+			if _, ok := source.Find(from); !ok {
+				problems.add("cannot map %q: the source type does not declare it", from)
+			} else if _, ok := target.Find(to); !ok {
+				problems.add("cannot map %q to %q: the target type does not declare %q", from, to, to)
+			} else if from != to {
+				change.Renamed[from] = to
+			}
+		}
+	}
+
+	for _, definition := range source {
+		name := definition.Name
+		if to, ok := change.Renamed[name]; ok {
+			name = to
+		}
+		if _, ok := target.Find(name); !ok {
+			change.Removed = append(change.Removed, definition.Name)
+		}
+	}
+
+	if err := problems.orNil(); err != nil {
+		return models.SchemaChange{}, err
+	}
+	return change, nil
+}
@@ -0,0 +1,211 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+)
+
+// Permission is the right to call a group of API routes.
+type Permission string
+
+// Permissions declared by the API routes.
+const (
+	ReadComponents Permission = "components.read"
+	// WriteComponents allows creating and editing components and uploading their images.
+	WriteComponents Permission = "components.write"
+	// OperateComponents allows lifecycle operations such as assigning, returning or deactivating a component.
+	OperateComponents Permission = "components.operate"
+	ReadTypes         Permission = "types.read"
+	// WriteTypes allows creating, editing, merging and deleting component types.
+	WriteTypes Permission = "types.write"
+	// ReadUsers allows looking up any user and their inventory history; every user can look up themselves.
+	ReadUsers Permission = "users.read"
+	// ListUsers allows listing the whole directory.
+	ListUsers Permission = "users.list"
+	// ActOnBehalf allows recording operations for another user, such as assigning a component to them.
+	ActOnBehalf Permission = "inventory.act-on-behalf"
+)
+
+// AllPermissions grants every permission when it appears in a role.
+const AllPermissions Permission = "*"
+
+var permissions = []Permission{
+	ReadComponents, WriteComponents, OperateComponents, ReadTypes, WriteTypes, ReadUsers, ListUsers, ActOnBehalf,
+}
+
+// Built-in roles.
+const (
+	Admin            = "Admin"
+	InventoryManager = "InventoryManager"
+	Viewer           = "Viewer"
+	// Self grants no permissions; its users can only look up themselves and their own inventory history.
+	Self = "Self"
+)
+
+// Policy grants roles to principals from the app roles and groups of their token, and
+// permissions from the roles.
+//
+// The built-in policy can be replaced at startup by pointing RBAC_CONFIG at a JSON file:
+//
+//	{
+//	  "roles": {"Admin": ["*"], "Viewer": ["components.read", "types.read"]},
+//	  "appRoles": {"Inventory.Admin": ["Admin"]},
+//	  "groups": {"<group object ID>": ["Viewer"]},
+//	  "defaultRoles": ["Viewer"]
+//	}
+//
+// Groups are matched against the object IDs in the groups claim, which Azure AD only adds to
+// tokens when the app registration sets groupMembershipClaims; users in too many groups to fit
+// the token get no groups.
+type Policy struct {
+	// Roles maps each role to the permissions it grants.
+	Roles map[string][]Permission `json:"roles"`
+	// AppRoles maps app roles of the roles claim to roles.
+	AppRoles map[string][]string `json:"appRoles"`
+	// Groups maps group object IDs of the groups claim to roles.
+	Groups map[string][]string `json:"groups"`
+	// DefaultRoles are granted to every signed-in user.
+	DefaultRoles []string `json:"defaultRoles"`
+}
+
+// DefaultPolicy returns the built-in policy: every signed-in user is a Viewer, and the
+// Inventory.Admin and Inventory.Manager app roles make them an Admin or an InventoryManager.
+func DefaultPolicy() *Policy {
+	return &Policy{
+		Roles: map[string][]Permission{
+			Admin:            {AllPermissions},
+			InventoryManager: {ReadComponents, WriteComponents, OperateComponents, ReadTypes, ReadUsers, ListUsers, ActOnBehalf},
+			Viewer:           {ReadComponents, ReadTypes, ReadUsers},
+			Self:             {},
+		},
+		AppRoles: map[string][]string{
+			"Inventory.Admin":   {Admin},
+			"Inventory.Manager": {InventoryManager},
+			"Inventory.Viewer":  {Viewer},
+		},
+		Groups:       map[string][]string{},
+		DefaultRoles: []string{Viewer},
+	}
+}
+
+// LoadPolicyFile reads a policy from a JSON file.
+func LoadPolicyFile(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read RBAC file: %w", err)
+	}
+
+	var policy Policy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse RBAC file: %w", err)
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &policy, nil
+}
+
+// Validate checks that roles grant known permissions and that every mapping refers to declared roles.
+func (p *Policy) Validate() error {
+	for role, granted := range p.Roles {
+		for _, permission := range granted {
+			if permission != AllPermissions && !isPermission(permission) {
+				return fmt.Errorf("role %q grants unknown permission %q", role, permission)
+			}
+		}
+	}
+
+	check := func(source string, roles []string) error {
+		for _, role := range roles {
+			if _, ok := p.Roles[role]; !ok {
+				return fmt.Errorf("%s grants undeclared role %q", source, role)
+			}
+		}
+		return nil
+	}
+	for appRole, roles := range p.AppRoles {
+		if err := check(fmt.Sprintf("app role %q", appRole), roles); err != nil {
+			return err
+		}
+	}
+	for group, roles := range p.Groups {
+		if err := check(fmt.Sprintf("group %q", group), roles); err != nil {
+			return err
+		}
+	}
+	return check("defaultRoles", p.DefaultRoles)
+}
+
+// Grant returns the principal with the roles and permissions the policy gives it.
+func (p *Policy) Grant(principal Principal) Principal {
+	roles := map[string]bool{}
+	for _, role := range p.DefaultRoles {
+		roles[role] = true
+	}
+	for _, appRole := range principal.AppRoles {
+		for _, role := range p.AppRoles[appRole] {
+			roles[role] = true
+		}
+	}
+	for _, group := range principal.Groups {
+		for _, role := range p.Groups[group] {
+			roles[role] = true
+		}
+	}
+
+	principal.Roles = make([]string, 0, len(roles))
+	principal.Permissions = []Permission{}
+	granted := map[Permission]bool{}
+	for role := range roles {
+		principal.Roles = append(principal.Roles, role)
+		for _, permission := range p.Roles[role] {
+			if permission == AllPermissions {
+				for _, permission := range permissions {
+					granted[permission] = true
+				}
+				continue
+			}
+			granted[permission] = true
+		}
+	}
+	for _, permission := range permissions {
+		if granted[permission] {
+			principal.Permissions = append(principal.Permissions, permission)
+		}
+	}
+	sort.Strings(principal.Roles)
+
+	return principal
+}
+
+func isPermission(permission Permission) bool {
+	for _, p := range permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	mu     sync.RWMutex
+	policy = DefaultPolicy()
+)
+
+// CurrentPolicy returns the policy in use.
+func CurrentPolicy() *Policy {
+	mu.RLock()
+	defer mu.RUnlock()
+	return policy
+}
+
+// UsePolicy replaces the policy in use.
+func UsePolicy(p *Policy) {
+	mu.Lock()
+	defer mu.Unlock()
+	policy = p
+}
@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGrant(t *testing.T) {
+	policy := DefaultPolicy()
+	policy.Groups = map[string][]string{
+		"11111111-aaaa": {InventoryManager},
+		"22222222-bbbb": {Self},
+	}
+	viewer := []Permission{ReadComponents, ReadTypes, ReadUsers}
+	manager := []Permission{ReadComponents, WriteComponents, OperateComponents, ReadTypes, ReadUsers, ListUsers, ActOnBehalf}
+
+	tests := []struct {
+		name        string
+		principal   Principal
+		roles       []string
+		permissions []Permission
+	}{
+		{name: "default roles", principal: Principal{}, roles: []string{Viewer}, permissions: viewer},
+		{name: "unknown app role", principal: Principal{AppRoles: []string{"Inventory.Owner"}}, roles: []string{Viewer}, permissions: viewer},
+		{
+			name:        "app role",
+			principal:   Principal{AppRoles: []string{"Inventory.Manager"}},
+			roles:       []string{InventoryManager, Viewer},
+			permissions: manager,
+		},
+		{
+			// The wildcard grants every permission, in declaration order
+			name:        "wildcard",
+			principal:   Principal{AppRoles: []string{"Inventory.Admin"}},
+			roles:       []string{Admin, Viewer},
+			permissions: permissions,
+		},
+		{
+			name:        "group",
+			principal:   Principal{Groups: []string{"11111111-aaaa"}},
+			roles:       []string{InventoryManager, Viewer},
+			permissions: manager,
+		},
+		{
+			// Roles granted several times are only listed once, and permissions are merged
+			name:        "app roles and groups",
+			principal:   Principal{AppRoles: []string{"Inventory.Viewer", "Inventory.Manager"}, Groups: []string{"11111111-aaaa", "22222222-bbbb", "33333333-cccc"}},
+			roles:       []string{InventoryManager, Self, Viewer},
+			permissions: manager,
+		},
+	}
+	for _, test := range tests {
+		principal := policy.Grant(test.principal)
+		if !reflect.DeepEqual(principal.Roles, test.roles) || !reflect.DeepEqual(principal.Permissions, test.permissions) {
+			t.Errorf("%s: Grant() = %v %v; want %v %v", test.name, principal.Roles, principal.Permissions, test.roles, test.permissions)
+		}
+	}
+}
+
+func TestGrantWithoutDefaultRoles(t *testing.T) {
+	policy := DefaultPolicy()
+	policy.DefaultRoles = nil
+
+	principal := policy.Grant(Principal{ID: "1", AppRoles: []string{"Inventory.Owner"}})
+	if len(principal.Roles) != 0 || len(principal.Permissions) != 0 {
+		t.Errorf("Grant() = %v %v; want no roles or permissions", principal.Roles, principal.Permissions)
+	}
+	if principal.Can(ReadComponents) {
+		t.Error("Can(ReadComponents) = true; want false")
+	}
+
+	// Grant replaces roles and permissions instead of adding to them
+	principal = policy.Grant(Principal{Roles: []string{Admin}, Permissions: []Permission{WriteTypes}})
+	if len(principal.Roles) != 0 || principal.Can(WriteTypes) {
+		t.Errorf("Grant() = %v %v; want no roles or permissions", principal.Roles, principal.Permissions)
+	}
+}
+
+func TestValidatePolicy(t *testing.T) {
+	roles := map[string][]Permission{Viewer: {ReadComponents}, Admin: {AllPermissions}}
+	tests := []struct {
+		name   string
+		policy Policy
+		err    string
+	}{
+		{name: "default", policy: *DefaultPolicy()},
+		{name: "unknown permission", policy: Policy{Roles: map[string][]Permission{Viewer: {"components.delete"}}}, err: `role "Viewer" grants unknown permission "components.delete"`},
+		{name: "app role", policy: Policy{Roles: roles, AppRoles: map[string][]string{"Inventory.Owner": {"Owner"}}}, err: `app role "Inventory.Owner" grants undeclared role "Owner"`},
+		{name: "group", policy: Policy{Roles: roles, Groups: map[string][]string{"11111111-aaaa": {"Owner"}}}, err: `group "11111111-aaaa" grants undeclared role "Owner"`},
+		{name: "default roles", policy: Policy{Roles: roles, DefaultRoles: []string{"Owner"}}, err: `defaultRoles grants undeclared role "Owner"`},
+	}
+	for _, test := range tests {
+		err := test.policy.Validate()
+		if test.err == "" && err != nil || test.err != "" && (err == nil || err.Error() != test.err) {
+			t.Errorf("%s: Validate() = %v; want %q", test.name, err, test.err)
+		}
+	}
+}
+
+func TestLoadPolicyFile(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		err  string
+	}{
+		{
+			name: "valid",
+			file: `{"roles": {"Admin": ["*"], "Viewer": ["components.read"]}, "appRoles": {"Inventory.Admin": ["Admin"]}, "groups": {"11111111-aaaa": ["Admin"]}, "defaultRoles": ["Viewer"]}`,
+		},
+		{name: "invalid JSON", file: `{"roles": `, err: "failed to parse RBAC file"},
+		{name: "invalid policy", file: `{"roles": {}, "defaultRoles": ["Viewer"]}`, err: `defaultRoles grants undeclared role "Viewer"`},
+	}
+	for _, test := range tests {
+		path := filepath.Join(t.TempDir(), "rbac.json")
+		if err := os.WriteFile(path, []byte(test.file), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		policy, err := LoadPolicyFile(path)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: LoadPolicyFile() error = %v; want %q", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: LoadPolicyFile() error = %v", test.name, err)
+			continue
+		}
+		principal := policy.Grant(Principal{Groups: []string{"11111111-aaaa"}})
+		if !reflect.DeepEqual(principal.Roles, []string{Admin, Viewer}) || !reflect.DeepEqual(principal.Permissions, permissions) {
+			t.Errorf("%s: Grant() = %v %v; want every permission", test.name, principal.Roles, principal.Permissions)
+		}
+	}
+
+	if _, err := LoadPolicyFile(filepath.Join(t.TempDir(), "missing.json")); err == nil || !strings.Contains(err.Error(), "failed to read RBAC file") {
+		t.Errorf("LoadPolicyFile() of a missing file error = %v", err)
+	}
+}
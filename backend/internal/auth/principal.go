@@ -0,0 +1,85 @@
+// Package auth describes the signed-in user a request is made by and what they are allowed to do.
+//
+// AuthMiddleware has the identity provider in use verify the token of every protected request,
+// has the Policy in use grant the Principal it names its roles and permissions, and stores the
+// principal in the request context. Handlers take the acting user from there, never from path
+// parameters or request bodies.
+package auth
+
+import (
+	"context"
+	"fmt"
+)
+
+// Principal is the verified user a request is made by.
+type Principal struct {
+	// ID is the ID of the user in the directory, the object ID (oid) for Azure AD.
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	// AppRoles and Groups are the roles and groups claims of the token.
+	AppRoles []string `json:"appRoles"`
+	Groups   []string `json:"groups"`
+	// Roles and Permissions are granted by the policy; see Policy.Grant.
+	Roles       []string     `json:"roles"`
+	Permissions []Permission `json:"permissions"`
+}
+
+// Can reports whether the principal has been granted permission.
+func (p Principal) Can(permission Permission) bool {
+	for _, granted := range p.Permissions {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// FromClaims returns the principal named by the claims of a verified token, before any roles are granted.
+// idClaim names the claim holding the user ID: oid for Azure AD, usually sub for other issuers.
+// The e-mail address falls back to preferred_username, which Azure AD sets to the user principal name.
+func FromClaims(claims map[string]interface{}, idClaim string) (Principal, error) {
+	principal := Principal{
+		ID:       stringClaim(claims, idClaim),
+		Name:     stringClaim(claims, "name"),
+		Email:    stringClaim(claims, "email"),
+		AppRoles: stringsClaim(claims, "roles"),
+		Groups:   stringsClaim(claims, "groups"),
+	}
+	if principal.ID == "" {
+		return Principal{}, fmt.Errorf("the token does not identify a user (no %s claim)", idClaim)
+	}
+	if principal.Email == "" {
+		principal.Email = stringClaim(claims, "preferred_username")
+	}
+	return principal, nil
+}
+
+func stringClaim(claims map[string]interface{}, name string) string {
+	value, _ := claims[name].(string)
+	return value
+}
+
+func stringsClaim(claims map[string]interface{}, name string) []string {
+	values := []string{}
+	list, _ := claims[name].([]interface{})
+	for _, item := range list {
+		if value, ok := item.(string); ok {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying the principal.
+func NewContext(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, principal)
+}
+
+// FromContext returns the principal stored in ctx by NewContext.
+func FromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(contextKey{}).(Principal)
+	return principal, ok
+}
@@ -0,0 +1,189 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/MicahParks/keyfunc"
+	JWT "github.com/golang-jwt/jwt/v4"
+)
+
+// Reasons a token fails verification, as reported by VerificationError.
+const (
+	ReasonMalformed     = "malformed"
+	ReasonUnknownKey    = "unknown_key"
+	ReasonSignature     = "signature"
+	ReasonExpired       = "expired"
+	ReasonNotYetValid   = "not_yet_valid"
+	ReasonAudience      = "audience"
+	ReasonIssuer        = "issuer"
+	ReasonTenant        = "tenant"
+	ReasonNoSubject     = "no_subject"
+	ReasonInvalidClaims = "invalid_claims"
+	// ReasonNoProvider is reported while no identity provider is configured.
+	ReasonNoProvider = "no_provider"
+)
+
+// VerificationError is returned for tokens that fail verification.
+type VerificationError struct {
+	// Reason is one of the Reason constants.
+	Reason string
+	Err    error
+}
+
+func (e *VerificationError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *VerificationError) Unwrap() error {
+	return e.Err
+}
+
+func verificationError(reason string, format string, args ...interface{}) *VerificationError {
+	return &VerificationError{Reason: reason, Err: fmt.Errorf(format, args...)}
+}
+
+// VerifierConfig describes the tokens a Verifier accepts.
+type VerifierConfig struct {
+	// JWKSURL is the key set the tokens are signed with.
+	JWKSURL string
+	// Audiences lists the accepted values of the aud claim.
+	Audiences []string
+	// Issuer is the expected value of the iss claim.
+	Issuer string
+	// TenantID is the expected value of the tid claim; empty accepts any tenant.
+	TenantID string
+	// Leeway is the clock skew tolerated when checking the exp and nbf claims.
+	Leeway time.Duration
+	// RefreshInterval is how often the key set is fetched again in the background.
+	RefreshInterval time.Duration
+	// HTTPClient fetches the key set; http.DefaultClient if nil.
+	HTTPClient *http.Client
+}
+
+// Verifier checks the signature and claims of tokens against a key set that is fetched once
+// and refreshed in the background, and again whenever a token is signed with an unknown key.
+type Verifier struct {
+	config  VerifierConfig
+	keyfunc JWT.Keyfunc
+	close   func()
+	parser  *JWT.Parser
+}
+
+// NewVerifier fetches the key set and starts refreshing it until Close is called.
+func NewVerifier(config VerifierConfig) (*Verifier, error) {
+	if len(config.Audiences) == 0 {
+		return nil, errors.New("token verifier needs at least one audience")
+	}
+	if config.Issuer == "" {
+		return nil, errors.New("token verifier needs an issuer")
+	}
+	if config.RefreshInterval == 0 {
+		config.RefreshInterval = time.Hour
+	}
+
+	options := keyfunc.Options{
+		Client: config.HTTPClient,
+		Ctx:    context.Background(),
+		RefreshErrorHandler: func(err error) {
+			log.Printf("Failed to refresh the token signing keys: %s", err.Error())
+		},
+		RefreshInterval:   config.RefreshInterval,
+		RefreshRateLimit:  time.Minute * 5,
+		RefreshTimeout:    time.Second * 10,
+		RefreshUnknownKID: true,
+	}
+	jwks, err := keyfunc.Get(config.JWKSURL, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the token signing keys from %s: %w", config.JWKSURL, err)
+	}
+
+	return &Verifier{
+		config:  config,
+		keyfunc: jwks.Keyfunc,
+		close:   jwks.EndBackground,
+		// Claims are checked by Verify, which tolerates clock skew
+		parser: JWT.NewParser(JWT.WithValidMethods([]string{"RS256"}), JWT.WithoutClaimsValidation()),
+	}, nil
+}
+
+// NewKeyVerifier returns a verifier of tokens signed with a single local key, such as the tokens
+// of a static directory; config.JWKSURL and config.RefreshInterval are not used.
+func NewKeyVerifier(config VerifierConfig, method JWT.SigningMethod, key interface{}) (*Verifier, error) {
+	if len(config.Audiences) == 0 {
+		return nil, errors.New("token verifier needs at least one audience")
+	}
+	if config.Issuer == "" {
+		return nil, errors.New("token verifier needs an issuer")
+	}
+
+	return &Verifier{
+		config:  config,
+		keyfunc: func(*JWT.Token) (interface{}, error) { return key, nil },
+		close:   func() {},
+		parser:  JWT.NewParser(JWT.WithValidMethods([]string{method.Alg()}), JWT.WithoutClaimsValidation()),
+	}, nil
+}
+
+// Close stops refreshing the key set.
+func (v *Verifier) Close() {
+	v.close()
+}
+
+// Verify returns the claims of a token whose signature and claims are valid. Errors are *VerificationError.
+func (v *Verifier) Verify(tokenString string) (map[string]interface{}, error) {
+	token, err := v.parser.Parse(tokenString, v.keyfunc)
+	if err != nil {
+		var validationErr *JWT.ValidationError
+		switch {
+		case errors.As(err, &validationErr) && validationErr.Errors&JWT.ValidationErrorMalformed != 0:
+			return nil, verificationError(ReasonMalformed, "malformed token: %w", err)
+		case errors.Is(err, keyfunc.ErrKIDNotFound):
+			return nil, verificationError(ReasonUnknownKey, "token is signed with an unknown key: %w", err)
+		default:
+			return nil, verificationError(ReasonSignature, "invalid token signature: %w", err)
+		}
+	}
+
+	claims, ok := token.Claims.(JWT.MapClaims)
+	if !ok {
+		return nil, verificationError(ReasonInvalidClaims, "unexpected token claims")
+	}
+	if err := v.checkClaims(claims, time.Now()); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (v *Verifier) checkClaims(claims JWT.MapClaims, now time.Time) error {
+	if !claims.VerifyExpiresAt(now.Add(-v.config.Leeway).Unix(), true) {
+		return verificationError(ReasonExpired, "token has expired or has no expiry")
+	}
+	if !claims.VerifyNotBefore(now.Add(v.config.Leeway).Unix(), false) {
+		return verificationError(ReasonNotYetValid, "token is not valid yet")
+	}
+
+	audience := false
+	for _, accepted := range v.config.Audiences {
+		if claims.VerifyAudience(accepted, true) {
+			audience = true
+			break
+		}
+	}
+	if !audience {
+		return verificationError(ReasonAudience, "token is not meant for this application (aud %v)", claims["aud"])
+	}
+	if !claims.VerifyIssuer(v.config.Issuer, true) {
+		return verificationError(ReasonIssuer, "token is issued by %v, expected %s", claims["iss"], v.config.Issuer)
+	}
+	if v.config.TenantID != "" {
+		if tenant, _ := claims["tid"].(string); tenant != v.config.TenantID {
+			return verificationError(ReasonTenant, "token is issued for tenant %q", tenant)
+		}
+	}
+	return nil
+}
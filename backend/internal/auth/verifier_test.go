@@ -0,0 +1,157 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	JWT "github.com/golang-jwt/jwt/v4"
+)
+
+var testKey = []byte("test signing key")
+
+func testVerifier(t *testing.T) *Verifier {
+	t.Helper()
+	verifier, err := NewKeyVerifier(VerifierConfig{
+		Audiences: []string{"api://vinventory", "client-id"},
+		Issuer:    "https://login.example.com/tenant/v2.0",
+		TenantID:  "tenant",
+		Leeway:    time.Minute,
+	}, JWT.SigningMethodHS256, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return verifier
+}
+
+// validClaims returns claims the test verifier accepts at now, with overrides applied; a nil
+// override removes the claim.
+func validClaims(now time.Time, overrides JWT.MapClaims) JWT.MapClaims {
+	claims := JWT.MapClaims{
+		"aud": "client-id",
+		"iss": "https://login.example.com/tenant/v2.0",
+		"tid": "tenant",
+		"oid": "user",
+		"iat": numericDate(now),
+		"nbf": numericDate(now),
+		"exp": numericDate(now.Add(time.Hour)),
+	}
+	for name, value := range overrides {
+		if value == nil {
+			delete(claims, name)
+			continue
+		}
+		claims[name] = value
+	}
+	return claims
+}
+
+func TestCheckClaims(t *testing.T) {
+	verifier := testVerifier(t)
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		overrides JWT.MapClaims
+		reason    string
+	}{
+		{name: "valid"},
+		{name: "audience list", overrides: JWT.MapClaims{"aud": []interface{}{"other", "api://vinventory"}}},
+		{name: "no nbf", overrides: JWT.MapClaims{"nbf": nil}},
+		{name: "expired within leeway", overrides: JWT.MapClaims{"exp": numericDate(now.Add(-30 * time.Second))}},
+		{name: "expired", overrides: JWT.MapClaims{"exp": numericDate(now.Add(-2 * time.Minute))}, reason: ReasonExpired},
+		{name: "no expiry", overrides: JWT.MapClaims{"exp": nil}, reason: ReasonExpired},
+		{name: "not yet valid within leeway", overrides: JWT.MapClaims{"nbf": numericDate(now.Add(30 * time.Second))}},
+		{name: "not yet valid", overrides: JWT.MapClaims{"nbf": numericDate(now.Add(2 * time.Minute))}, reason: ReasonNotYetValid},
+		{name: "other audience", overrides: JWT.MapClaims{"aud": "api://other"}, reason: ReasonAudience},
+		{name: "other audiences", overrides: JWT.MapClaims{"aud": []interface{}{"api://other", "another"}}, reason: ReasonAudience},
+		{name: "no audience", overrides: JWT.MapClaims{"aud": nil}, reason: ReasonAudience},
+		{name: "other issuer", overrides: JWT.MapClaims{"iss": "https://login.example.com/other/v2.0"}, reason: ReasonIssuer},
+		{name: "no issuer", overrides: JWT.MapClaims{"iss": nil}, reason: ReasonIssuer},
+		{name: "other tenant", overrides: JWT.MapClaims{"tid": "other"}, reason: ReasonTenant},
+		{name: "no tenant", overrides: JWT.MapClaims{"tid": nil}, reason: ReasonTenant},
+	}
+	for _, test := range tests {
+		err := verifier.checkClaims(validClaims(now, test.overrides), now)
+		if test.reason == "" {
+			if err != nil {
+				t.Errorf("%s: checkClaims() = %v", test.name, err)
+			}
+			continue
+		}
+		var verificationErr *VerificationError
+		if !errors.As(err, &verificationErr) || verificationErr.Reason != test.reason {
+			t.Errorf("%s: checkClaims() = %v; want reason %q", test.name, err, test.reason)
+		}
+	}
+}
+
+func TestCheckClaimsAnyTenant(t *testing.T) {
+	verifier := testVerifier(t)
+	verifier.config.TenantID = ""
+	now := time.Now()
+
+	for _, tenant := range []interface{}{"other", nil} {
+		if err := verifier.checkClaims(validClaims(now, JWT.MapClaims{"tid": tenant}), now); err != nil {
+			t.Errorf("checkClaims() with tid %v = %v", tenant, err)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	verifier := testVerifier(t)
+	now := time.Now()
+	sign := func(method JWT.SigningMethod, key interface{}, claims JWT.MapClaims) string {
+		token, err := JWT.NewWithClaims(method, claims).SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	claims, err := verifier.Verify(sign(JWT.SigningMethodHS256, testKey, validClaims(now, nil)))
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if claims["oid"] != "user" {
+		t.Errorf("Verify() claims = %v", claims)
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		reason string
+	}{
+		{name: "not a token", token: "not a token", reason: ReasonMalformed},
+		{name: "empty", token: "", reason: ReasonMalformed},
+		{name: "other key", token: sign(JWT.SigningMethodHS256, []byte("other key"), validClaims(now, nil)), reason: ReasonSignature},
+		{name: "other algorithm", token: sign(JWT.SigningMethodHS512, testKey, validClaims(now, nil)), reason: ReasonSignature},
+		{name: "unsigned", token: sign(JWT.SigningMethodNone, JWT.UnsafeAllowNoneSignatureType, validClaims(now, nil)), reason: ReasonSignature},
+		{name: "expired", token: sign(JWT.SigningMethodHS256, testKey, validClaims(now, JWT.MapClaims{"exp": numericDate(now.Add(-time.Hour))})), reason: ReasonExpired},
+		{name: "other tenant", token: sign(JWT.SigningMethodHS256, testKey, validClaims(now, JWT.MapClaims{"tid": "other"})), reason: ReasonTenant},
+	}
+	for _, test := range tests {
+		_, err := verifier.Verify(test.token)
+		var verificationErr *VerificationError
+		if !errors.As(err, &verificationErr) || verificationErr.Reason != test.reason {
+			t.Errorf("%s: Verify() error = %v; want reason %q", test.name, err, test.reason)
+		}
+	}
+}
+
+func TestNewKeyVerifierConfig(t *testing.T) {
+	configs := []VerifierConfig{
+		{Issuer: "https://login.example.com/tenant/v2.0"},
+		{Audiences: []string{"client-id"}},
+	}
+	for _, config := range configs {
+		if _, err := NewKeyVerifier(config, JWT.SigningMethodHS256, testKey); err == nil {
+			t.Errorf("NewKeyVerifier(%+v) succeeded", config)
+		}
+	}
+}
+
+// numericDate returns t as decoded from the claims of a token.
+func numericDate(t time.Time) float64 {
+	return float64(t.Unix())
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"github.com/glebarez/sqlite"
 	"github.com/joho/godotenv"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -9,11 +10,16 @@ import (
 	"log"
 	"net/url"
 	"os"
+	"strings"
 	"time"
 )
 
 // Config holds the database configuration.
 type Config struct {
+	// DBDriver selects the database: "postgres" (default) or "sqlite".
+	DBDriver string
+	// DBPath is the database file used by the sqlite driver.
+	DBPath        string
 	DBUser        string
 	DBPassword    string
 	DBName        string
@@ -25,6 +31,39 @@ type Config struct {
 	SMTPPassword  string
 	SenderEmail   string
 	ReceiverEmail string
+	// DBSchemaCheck makes the server refuse to start while migrations are pending.
+	DBSchemaCheck bool
+	// LifecycleConfig is the path of a JSON file replacing the built-in component lifecycle.
+	LifecycleConfig string
+	// RBACConfig is the path of a JSON file replacing the built-in role-based access control policy.
+	RBACConfig string
+	// RBACDefaultRoles replaces the default roles of the policy when set (RBAC_DEFAULT_ROLES, comma separated).
+	RBACDefaultRoles []string
+	// IdentityProvider selects where users sign in and are looked up: "azure" (default), "oidc" or "static".
+	IdentityProvider  string
+	AzureTenantID     string
+	AzureClientID     string
+	AzureClientSecret string
+	// AzureLoginURL and GraphURL are the base URLs of the Microsoft identity platform and Graph,
+	// changed for sovereign clouds or a stand-in; empty uses the public cloud.
+	AzureLoginURL   string
+	GraphURL        string
+	OIDCIssuer      string
+	OIDCClientID    string
+	OIDCUserIDClaim string
+	OIDCUsersURL    string
+	OIDCUsersToken  string
+	// DirectoryFile is the YAML or JSON file users are read from by the static and oidc providers.
+	DirectoryFile string
+	// StaticSigningKey is the secret the static provider signs tokens with.
+	StaticSigningKey string
+	// TokenAudiences are the accepted aud claims of tokens; TOKEN_AUDIENCE is comma separated and
+	// defaults to the client ID, the audience of the ID tokens the frontend sends.
+	TokenAudiences []string
+	// TokenIssuer is the expected iss claim of tokens; it defaults to the issuer of the provider.
+	TokenIssuer string
+	// TokenLeeway is the clock skew tolerated when checking token expiry.
+	TokenLeeway time.Duration
 }
 
 type MinioConfig struct {
@@ -55,31 +94,104 @@ func ConfigMinio() MinioConfig {
 	}
 }
 
-// LoadConfig loads the database and SMTP configuration from environment variables.
+// LoadConfig loads the database, SMTP and identity provider configuration from environment variables.
 func LoadConfig() Config {
+	driver := os.Getenv("DB_DRIVER")
+	if driver == "" {
+		driver = "postgres"
+	}
+	path := os.Getenv("DB_PATH")
+	if path == "" {
+		path = "vinventory.db"
+	}
+
+	provider := os.Getenv("IDENTITY_PROVIDER")
+	if provider == "" {
+		provider = "azure"
+	}
+	audiences := []string{}
+	for _, audience := range strings.Split(os.Getenv("TOKEN_AUDIENCE"), ",") {
+		if audience = strings.TrimSpace(audience); audience != "" {
+			audiences = append(audiences, audience)
+		}
+	}
+	if len(audiences) == 0 && provider == "azure" && os.Getenv("AZURE_CLIENT_ID") != "" {
+		audiences = append(audiences, os.Getenv("AZURE_CLIENT_ID"))
+	}
+	var defaultRoles []string
+	for _, role := range strings.Split(os.Getenv("RBAC_DEFAULT_ROLES"), ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			defaultRoles = append(defaultRoles, role)
+		}
+	}
+	leeway := time.Minute
+	if value := os.Getenv("TOKEN_LEEWAY"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			log.Printf("Ignoring invalid TOKEN_LEEWAY %q: %s", value, err.Error())
+		} else {
+			leeway = parsed
+		}
+	}
+
 	return Config{
-		DBUser:        os.Getenv("DB_USER"),
-		DBPassword:    os.Getenv("DB_PASSWORD"),
-		DBName:        os.Getenv("DB_NAME"),
-		DBHost:        os.Getenv("DB_HOST"),
-		DBPort:        os.Getenv("DB_PORT"),
-		SMTPHost:      os.Getenv("SMTP_HOST"),
-		SMTPPort:      os.Getenv("SMTP_PORT"),
-		SMTPUsername:  os.Getenv("SMTP_USERNAME"),
-		SMTPPassword:  os.Getenv("SMTP_PASSWORD"),
-		SenderEmail:   os.Getenv("SENDER_EMAIL"),
-		ReceiverEmail: os.Getenv("RECEIVER_EMAIL"),
+		DBDriver:          driver,
+		DBPath:            path,
+		DBUser:            os.Getenv("DB_USER"),
+		DBPassword:        os.Getenv("DB_PASSWORD"),
+		DBName:            os.Getenv("DB_NAME"),
+		DBHost:            os.Getenv("DB_HOST"),
+		DBPort:            os.Getenv("DB_PORT"),
+		SMTPHost:          os.Getenv("SMTP_HOST"),
+		SMTPPort:          os.Getenv("SMTP_PORT"),
+		SMTPUsername:      os.Getenv("SMTP_USERNAME"),
+		SMTPPassword:      os.Getenv("SMTP_PASSWORD"),
+		SenderEmail:       os.Getenv("SENDER_EMAIL"),
+		ReceiverEmail:     os.Getenv("RECEIVER_EMAIL"),
+		DBSchemaCheck:     os.Getenv("DB_SCHEMA_CHECK") == "true",
+		LifecycleConfig:   os.Getenv("LIFECYCLE_CONFIG"),
+		RBACConfig:        os.Getenv("RBAC_CONFIG"),
+		RBACDefaultRoles:  defaultRoles,
+		IdentityProvider:  provider,
+		AzureTenantID:     os.Getenv("AZURE_TENANT_ID"),
+		AzureClientID:     os.Getenv("AZURE_CLIENT_ID"),
+		AzureClientSecret: os.Getenv("AZURE_CLIENT_SECRET"),
+		AzureLoginURL:     os.Getenv("AZURE_LOGIN_URL"),
+		GraphURL:          os.Getenv("GRAPH_URL"),
+		OIDCIssuer:        os.Getenv("OIDC_ISSUER"),
+		OIDCClientID:      os.Getenv("OIDC_CLIENT_ID"),
+		OIDCUserIDClaim:   os.Getenv("OIDC_USER_ID_CLAIM"),
+		OIDCUsersURL:      os.Getenv("OIDC_USERS_URL"),
+		OIDCUsersToken:    os.Getenv("OIDC_USERS_TOKEN"),
+		DirectoryFile:     os.Getenv("DIRECTORY_FILE"),
+		StaticSigningKey:  os.Getenv("STATIC_SIGNING_KEY"),
+		TokenAudiences:    audiences,
+		TokenIssuer:       os.Getenv("TOKEN_ISSUER"),
+		TokenLeeway:       leeway,
 	}
 }
 
+// InitDatabase opens the database selected by cfg.DBDriver.
 func InitDatabase(cfg Config) (*gorm.DB, error) {
-	dbUser := url.QueryEscape(cfg.DBUser)
-	dbPassword := url.QueryEscape(cfg.DBPassword)
-	dbHost := url.QueryEscape(cfg.DBHost)
-	dbName := url.QueryEscape(cfg.DBName)
+	var dialector gorm.Dialector
+	switch cfg.DBDriver {
+	case "postgres":
+		dbUser := url.QueryEscape(cfg.DBUser)
+		dbPassword := url.QueryEscape(cfg.DBPassword)
+		dbHost := url.QueryEscape(cfg.DBHost)
+		dbName := url.QueryEscape(cfg.DBName)
 
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable",
-		dbHost, dbUser, dbPassword, dbName, cfg.DBPort)
+		dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable",
+			dbHost, dbUser, dbPassword, dbName, cfg.DBPort)
+		dialector = postgres.Open(dsn)
+	case "sqlite":
+		// Foreign keys are off by default in SQLite; the busy timeout lets concurrent
+		// requests wait for the write lock instead of failing
+		dsn := fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)", cfg.DBPath)
+		dialector = sqlite.Open(dsn)
+	default:
+		return nil, fmt.Errorf("unsupported DB_DRIVER %q (use postgres or sqlite)", cfg.DBDriver)
+	}
 
 	configuration := &gorm.Config{
 		SkipDefaultTransaction:                   false,
@@ -103,11 +215,21 @@ func InitDatabase(cfg Config) (*gorm.DB, error) {
 		Plugins:                                  nil,
 	}
 
-	db, err := gorm.Open(postgres.Open(dsn), configuration)
+	db, err := gorm.Open(dialector, configuration)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
+	if cfg.DBDriver == "sqlite" {
+		// SQLite allows a single writer; one connection serialises transactions the way
+		// row locks do on Postgres
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure database: %w", err)
+		}
+		sqlDB.SetMaxOpenConns(1)
+	}
+
 	log.Println("Database connection successfully established")
 	return db, nil
 }
@@ -9,13 +9,17 @@ import (
 )
 
 type Config struct {
-	TenantID string `json:"tenantId"`
-	ClientID string `json:"clientId"`
+	// IdentityProvider is the provider users sign in with: azure, oidc or static
+	IdentityProvider string `json:"identityProvider"`
+	TenantID         string `json:"tenantId"`
+	ClientID         string `json:"clientId"`
+	// Authority is the issuer users sign in at with the oidc provider
+	Authority string `json:"authority,omitempty"`
 }
 
 // GetConfigHandler handles the config endpoint
 // @Summary Get configuration
-// @Description Get Azure AD configuration for the Web App
+// @Description Get the sign-in configuration for the Web App
 // @Tags config
 // @Produce json
 // @Success 200 {object} Config
@@ -23,8 +27,16 @@ type Config struct {
 func GetConfigHandler() http.HandlerFunc {
 	return socket.GinHandlerToMux(func(c *gin.Context) {
 		config := Config{
-			TenantID: os.Getenv("AZURE_TENANT_ID"),
-			ClientID: os.Getenv("AZURE_CLIENT_ID"),
+			IdentityProvider: os.Getenv("IDENTITY_PROVIDER"),
+			TenantID:         os.Getenv("AZURE_TENANT_ID"),
+			ClientID:         os.Getenv("AZURE_CLIENT_ID"),
+		}
+		switch config.IdentityProvider {
+		case "":
+			config.IdentityProvider = "azure"
+		case "oidc":
+			config.ClientID = os.Getenv("OIDC_CLIENT_ID")
+			config.Authority = os.Getenv("OIDC_ISSUER")
 		}
 
 		c.JSON(http.StatusOK, config)
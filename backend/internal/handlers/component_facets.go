@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"vinventory/internal/models"
+	"vinventory/internal/query"
+	"vinventory/internal/repository"
+	"vinventory/internal/socket"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FacetValue is one value of a field and the number of matching components having it; a null value counts the components where the field is unset
+type FacetValue struct {
+	Value interface{} `json:"value"`
+	Label string      `json:"label,omitempty"`
+	Count int64       `json:"count"`
+}
+
+// FacetsResponse holds the number of matching components and, per requested field, the counts of its values
+type FacetsResponse struct {
+	Total  int64                   `json:"total"`
+	Facets map[string][]FacetValue `json:"facets"`
+}
+
+// GetComponentFacets godoc
+// @Summary Count components per value of their fields
+// @Description Takes the filter and search parameters of GET /components and returns, for each requested field,
+// @Description how many matching components have each value, most frequent first. The filter on a field does not
+// @Description apply to its own counts, so a filter sidebar can show what choosing another value would match.
+// @Description Type counts are labelled with the type name.
+// @Tags components
+// @Produce  json
+// @Param facets query string false "Comma separated fields to count (default: status, brand, model, modelYear, condition, screenSize, resolution, processorType, processorCores, ram and typeId)"
+// @Success 200 {object} FacetsResponse
+// @Failure 400 {object} map[string]string "Invalid filter or unknown field"
+// @Router /components/facets [get]
+func GetComponentFacets(store repository.Store) http.HandlerFunc {
+	return socket.GinHandlerToMux(func(context *gin.Context) {
+		fields, err := parseFacets(context.Query("facets"))
+		if err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		filter, ok := componentFilter(context, store)
+		if !ok {
+			return
+		}
+
+		columns := make([]string, len(fields))
+		for i, field := range fields {
+			columns[i] = field.Column
+		}
+		facets, err := store.Components().Facets(filter, columns)
+		if err != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		typeNames := map[string]string{}
+		for _, field := range fields {
+			if field.Name != "typeId" {
+				continue
+			}
+			types, err := store.Types().List()
+			if err != nil {
+				context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			for _, componentType := range types {
+				typeNames[strconv.Itoa(componentType.ID)] = componentType.Name
+			}
+		}
+
+		response := FacetsResponse{Total: facets.Total, Facets: make(map[string][]FacetValue, len(fields))}
+		for _, field := range fields {
+			response.Facets[field.Name] = facetValues(field, facets.Counts[field.Column], typeNames)
+		}
+		context.JSON(http.StatusOK, response)
+	})
+}
+
+// parseFacets returns the fields named in the facets parameter, or every facetable field if it is empty
+func parseFacets(value string) ([]query.Field, error) {
+	var fields []query.Field
+	if value == "" {
+		for _, field := range query.Fields() {
+			if field.Facetable {
+				fields = append(fields, field)
+			}
+		}
+		return fields, nil
+	}
+
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		field, ok := query.Lookup(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown facet field %q", name)
+		}
+		if !field.Facetable {
+			return nil, fmt.Errorf("cannot count values of %q", name)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// facetValues converts the counts of a column to the type of its field, folding empty strings into the unset count.
+// typeNames labels the values of typeId
+func facetValues(field query.Field, counts []repository.FacetCount, typeNames map[string]string) []FacetValue {
+	values := []FacetValue{}
+	var unset int64
+	for _, count := range counts {
+		if count.Value == nil || *count.Value == "" {
+			unset += count.Count
+			continue
+		}
+
+		value := FacetValue{Value: *count.Value, Count: count.Count}
+		if field.Name == "typeId" {
+			value.Label = typeNames[*count.Value]
+		}
+		if field.Type == models.AttributeInt {
+			if number, err := strconv.Atoi(*count.Value); err == nil {
+				value.Value = number
+			}
+		}
+		values = append(values, value)
+	}
+
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Count != values[j].Count {
+			return values[i].Count > values[j].Count
+		}
+		return fmt.Sprint(values[i].Value) < fmt.Sprint(values[j].Value)
+	})
+	if unset > 0 {
+		values = append(values, FacetValue{Value: nil, Count: unset})
+	}
+	return values
+}
@@ -6,11 +6,13 @@ import (
 	"github.com/gin-gonic/gin"
 	minio_ "github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
+	"log"
 	"mime/multipart"
 	"net/http"
 	"net/url"
 	"os"
 	"strconv"
+	"sync"
 	"time"
 	"vinventory/internal/config"
 	"vinventory/internal/socket"
@@ -24,19 +26,26 @@ type SuccessResponse struct {
 	Message string `json:"message"`
 }
 
-var minioClient *minio_.Client
-
-func init() {
-	minioConfig := config.ConfigMinio()
+var (
+	minioOnce   sync.Once
+	minioClient *minio_.Client
+	minioErr    error
+)
 
-	var err error
-	minioClient, err = minio_.New(minioConfig.MinioEndpoint, &minio_.Options{
-		Creds:  credentials.NewStaticV4(minioConfig.MinioAccessKey, minioConfig.MinioSecretKey, ""),
-		Secure: minioConfig.MinioUseSSL == true,
+// imageStorage returns the MinIO client, creating it on first use so that a missing
+// configuration only fails the image routes
+func imageStorage() (*minio_.Client, error) {
+	minioOnce.Do(func() {
+		minioConfig := config.ConfigMinio()
+		minioClient, minioErr = minio_.New(minioConfig.MinioEndpoint, &minio_.Options{
+			Creds:  credentials.NewStaticV4(minioConfig.MinioAccessKey, minioConfig.MinioSecretKey, ""),
+			Secure: minioConfig.MinioUseSSL == true,
+		})
+		if minioErr != nil {
+			log.Printf("Failed to create the image storage client: %s", minioErr.Error())
+		}
 	})
-	if err != nil {
-		panic(err)
-	}
+	return minioClient, minioErr
 }
 
 // AddComponentImages godoc
@@ -91,6 +100,12 @@ func AddComponentImages() http.HandlerFunc {
 			return
 		}
 
+		client, err := imageStorage()
+		if err != nil {
+			context.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Image storage is not available"})
+			return
+		}
+
 		bucketName := os.Getenv("MINIO_BUCKET")
 		if bucketName == "" {
 			bucketName = "vinventory" // Default bucket
@@ -110,7 +125,7 @@ func AddComponentImages() http.HandlerFunc {
 			}(file)
 
 			objectName := fmt.Sprintf("%s/%d_%s", componentID, time.Now().Unix(), fileHeader.Filename)
-			_, err = minioClient.PutObject(context.Request.Context(), bucketName, objectName, file, fileHeader.Size, minio_.PutObjectOptions{ContentType: fileHeader.Header.Get("Content-Type")})
+			_, err = client.PutObject(context.Request.Context(), bucketName, objectName, file, fileHeader.Size, minio_.PutObjectOptions{ContentType: fileHeader.Header.Get("Content-Type")})
 			if err != nil {
 				context.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Unable to save file"})
 				return
@@ -141,6 +156,12 @@ func GetComponentImages() http.HandlerFunc {
 			return
 		}
 
+		client, err := imageStorage()
+		if err != nil {
+			context.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Image storage is not available"})
+			return
+		}
+
 		bucketName := os.Getenv("MINIO_BUCKET")
 		if bucketName == "" {
 			bucketName = "vinventory" // Default bucket
@@ -148,7 +169,7 @@ func GetComponentImages() http.HandlerFunc {
 
 		images := make([]string, 0)
 
-		objectCh := minioClient.ListObjects(context.Request.Context(), bucketName, minio_.ListObjectsOptions{
+		objectCh := client.ListObjects(context.Request.Context(), bucketName, minio_.ListObjectsOptions{
 			Prefix:    componentID + "/",
 			Recursive: true,
 		})
@@ -161,7 +182,7 @@ func GetComponentImages() http.HandlerFunc {
 
 			// Generating pre-signed URL for each image
 			reqParams := make(url.Values)
-			presignedURL, err := minioClient.PresignedGetObject(context.Request.Context(), bucketName, object.Key, time.Hour*24, reqParams)
+			presignedURL, err := client.PresignedGetObject(context.Request.Context(), bucketName, object.Key, time.Hour*24, reqParams)
 			if err != nil {
 				context.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Unable to generate pre-signed URL"})
 				return
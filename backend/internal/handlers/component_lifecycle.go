@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"vinventory/internal/auth"
+	"vinventory/internal/identity"
+	"vinventory/internal/inventory"
+	"vinventory/internal/lifecycle"
+	"vinventory/internal/models"
+	"vinventory/internal/repository"
+	"vinventory/internal/socket"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TransitionRequest represents the request payload for applying a lifecycle operation to a component
+type TransitionRequest struct {
+	Operation string `json:"operation"`
+	// OnBehalfOf records the operation for another user; it requires the inventory.act-on-behalf permission.
+	OnBehalfOf string `json:"onBehalfOf"`
+	// UserID is the former name of OnBehalfOf.
+	UserID string `json:"userId"`
+}
+
+// TransitionResponse reports the new state of a component and the history entry it recorded
+type TransitionResponse struct {
+	Component models.Component        `json:"component"`
+	History   models.InventoryHistory `json:"history"`
+}
+
+// lookupActor resolves the user an operation is recorded for; it is done before any
+// transaction is opened so a missing user never leaves a partial write behind
+func lookupActor(context *gin.Context, userID string) (inventory.Actor, bool) {
+	user, err := identity.Current().User(context.Request.Context(), userID)
+	if err != nil {
+		return inventory.Actor{}, false
+	}
+
+	// Add the username info for the case of user deletion
+	userName := fmt.Sprintf("%s %s", user.FirstName, user.LastName)
+
+	return inventory.Actor{UserID: userID, UserName: userName}, true
+}
+
+// requestActor returns the actor of an operation performed by the signed-in principal. The operation is
+// recorded for the principal unless onBehalfOf names another user, which requires the auth.ActOnBehalf
+// permission. If the request may not proceed the error response is written and false returned
+func requestActor(context *gin.Context, onBehalfOf string) (inventory.Actor, bool) {
+	principal, ok := auth.FromContext(context.Request.Context())
+	if !ok {
+		context.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: no signed-in user"})
+		return inventory.Actor{}, false
+	}
+
+	actor := inventory.Actor{
+		UserID:         principal.ID,
+		UserName:       principal.Name,
+		RecordedByID:   principal.ID,
+		RecordedByName: principal.Name,
+	}
+	if onBehalfOf == "" || onBehalfOf == principal.ID {
+		return actor, true
+	}
+
+	if !principal.Can(auth.ActOnBehalf) {
+		context.JSON(http.StatusForbidden, gin.H{
+			"error":      fmt.Sprintf("Forbidden: acting on behalf of another user requires the %s permission", auth.ActOnBehalf),
+			"permission": auth.ActOnBehalf,
+		})
+		return inventory.Actor{}, false
+	}
+	user, ok := lookupActor(context, onBehalfOf)
+	if !ok {
+		context.JSON(http.StatusNotFound, gin.H{"error": "User not found from API."})
+		return inventory.Actor{}, false
+	}
+	actor.UserID = user.UserID
+	actor.UserName = user.UserName
+	return actor, true
+}
+
+// onBehalfOf returns the user a request names to act for, accepting the former field name as well
+func onBehalfOf(field string, formerField string) string {
+	if field != "" {
+		return field
+	}
+	return formerField
+}
+
+// inventoryErrorResponse writes the response for an error returned by the inventory service
+func inventoryErrorResponse(context *gin.Context, err error) {
+	var transitionErr *lifecycle.TransitionError
+	switch {
+	case errors.Is(err, inventory.ErrComponentNotFound):
+		context.JSON(http.StatusNotFound, gin.H{"error": "Component not found"})
+	case errors.Is(err, inventory.ErrInvalidStatus):
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, lifecycle.ErrUnknownOperation):
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "operations": lifecycle.Current().Operations()})
+	case errors.As(err, &transitionErr):
+		context.JSON(http.StatusConflict, gin.H{"error": err.Error(), "status": transitionErr.Status, "allowed": transitionErr.Allowed})
+	default:
+		context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// TransitionComponent godoc
+// @Summary Apply a lifecycle operation to a component
+// @Description Moves a component to the status the operation leads to and records the operation in its
+// @Description inventory history. Unknown operations are rejected with 400, operations that are not allowed
+// @Description from the current status with 409 and the list of allowed operations.
+// @Tags components
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Component ID"
+// @Param If-Match header string false "ETag of the component the operation is based on"
+// @Param transition body TransitionRequest true "Transition Request"
+// @Success 200 {object} TransitionResponse
+// @Failure 412 {object} map[string]interface{} "The component has changed; current holds its current state"
+// @Router /components/{id}/transitions [post]
+func TransitionComponent(store repository.Store) http.HandlerFunc {
+	service := inventory.NewService(store)
+	return socket.GinHandlerToMux(func(context *gin.Context) {
+		idStr := context.Param("id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid component ID: " + idStr})
+			return
+		}
+
+		var request TransitionRequest
+		if err := context.ShouldBindJSON(&request); err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		actor, ok := requestActor(context, onBehalfOf(request.OnBehalfOf, request.UserID))
+		if !ok {
+			return
+		}
+
+		component, history, err := service.TransitionIf(id, ifMatch(context), request.Operation, actor)
+		if errors.Is(err, repository.ErrVersionConflict) {
+			preconditionFailed(context, store, id)
+			return
+		}
+		if err != nil {
+			inventoryErrorResponse(context, err)
+			return
+		}
+		setComponentETag(context, component)
+
+		context.JSON(http.StatusOK, TransitionResponse{Component: component, History: history})
+	})
+}
+
+// GetLifecycle godoc
+// @Summary Get the component lifecycle
+// @Description Returns the statuses a component can be in, the initial status of new components and
+// @Description the operations that move a component from one status to another
+// @Tags components
+// @Produce  json
+// @Success 200 {object} lifecycle.Lifecycle
+// @Router /lifecycle [get]
+func GetLifecycle() http.HandlerFunc {
+	return socket.GinHandlerToMux(func(context *gin.Context) {
+		context.JSON(http.StatusOK, lifecycle.Current())
+	})
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"vinventory/internal/auth"
+	"vinventory/internal/lifecycle"
+)
+
+func TestTransitionComponent(t *testing.T) {
+	server := newTestServer(t)
+	laptop := server.addType(t, "Laptop")
+	component := server.addComponent(t, laptop.ID, "SN-1", nil)
+
+	tests := []struct {
+		name    string
+		path    string
+		body    string
+		ifMatch string
+		status  int
+		want    string
+	}{
+		{name: "unknown component", path: "/components/9/transitions", body: `{"operation": "Assigned"}`, status: http.StatusNotFound},
+		{name: "invalid ID", path: "/components/x/transitions", body: `{"operation": "Assigned"}`, status: http.StatusBadRequest},
+		{name: "unknown operation", path: "/components/1/transitions", body: `{"operation": "Stolen"}`, status: http.StatusBadRequest},
+		{name: "not allowed", path: "/components/1/transitions", body: `{"operation": "Returned"}`, status: http.StatusConflict},
+		{name: "stale version", path: "/components/1/transitions", body: `{"operation": "Assigned"}`, ifMatch: `"0"`, status: http.StatusPreconditionFailed},
+		{name: "assigned", path: "/components/1/transitions", body: `{"operation": "Assigned"}`, ifMatch: `"1"`, status: http.StatusOK, want: lifecycle.BeingUsed},
+		{name: "assigned twice", path: "/components/1/transitions", body: `{"operation": "Assigned"}`, status: http.StatusConflict},
+		{name: "returned", path: "/components/1/transitions", body: `{"operation": "Returned"}`, ifMatch: "*", status: http.StatusOK, want: lifecycle.ReadyToUse},
+	}
+	for _, test := range tests {
+		recorder := server.do(http.MethodPost, test.path, test.body, "If-Match", test.ifMatch)
+		if recorder.Code != test.status {
+			t.Errorf("%s: status = %d; want %d (%s)", test.name, recorder.Code, test.status, recorder.Body)
+			continue
+		}
+		if test.want == "" {
+			continue
+		}
+		var response TransitionResponse
+		decode(t, recorder, &response)
+		if response.Component.Status != test.want || response.History.OperationType == "" || response.History.RecordedByID != "manager" {
+			t.Errorf("%s: response = %+v; want status %q recorded by manager", test.name, response, test.want)
+		}
+		if etag := recorder.Header().Get("ETag"); etag != componentETag(response.Component.Version) {
+			t.Errorf("%s: ETag = %s; want version %d", test.name, etag, response.Component.Version)
+		}
+	}
+
+	// A forbidden operation lists the allowed ones
+	recorder := server.do(http.MethodPost, "/components/1/transitions", `{"operation": "Returned"}`)
+	var conflict struct {
+		Status  string   `json:"status"`
+		Allowed []string `json:"allowed"`
+	}
+	decode(t, recorder, &conflict)
+	want := []string{lifecycle.Assigned, lifecycle.Deactivated, lifecycle.SentToRepair, lifecycle.ReportedLost, lifecycle.Retire}
+	if conflict.Status != lifecycle.ReadyToUse || !reflect.DeepEqual(conflict.Allowed, want) {
+		t.Errorf("conflict = %+v; want allowed %v", conflict, want)
+	}
+
+	history, err := server.store.History().ListByComponent(component.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 {
+		t.Errorf("history = %+v; want the two applied operations", history)
+	}
+}
+
+func TestTransitionOnBehalfOf(t *testing.T) {
+	server := newTestServer(t)
+	laptop := server.addType(t, "Laptop")
+	component := server.addComponent(t, laptop.ID, "SN-1", nil)
+
+	// Naming yourself needs no permission
+	server.principal = auth.DefaultPolicy().Grant(auth.Principal{ID: "viewer", Name: "Mehmet Demir"})
+	recorder := server.do(http.MethodPost, "/components/1/transitions", `{"operation": "Assigned", "onBehalfOf": "viewer"}`)
+	if recorder.Code != http.StatusOK {
+		t.Errorf("operation for yourself status = %d; want 200 (%s)", recorder.Code, recorder.Body)
+	}
+
+	for _, body := range []string{
+		`{"operation": "Returned", "onBehalfOf": "someone"}`,
+		`{"operation": "Returned", "userId": "someone"}`,
+	} {
+		recorder := server.do(http.MethodPost, "/components/1/transitions", body)
+		if recorder.Code != http.StatusForbidden {
+			t.Errorf("%s: status = %d; want 403", body, recorder.Code)
+		}
+	}
+
+	stored, err := server.store.Components().Get(component.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != lifecycle.BeingUsed {
+		t.Errorf("status = %q; want %q", stored.Status, lifecycle.BeingUsed)
+	}
+}
@@ -1,38 +1,38 @@
 package handlers
 
 import (
+	"errors"
 	"github.com/gin-gonic/gin"
-	"github.com/lib/pq"
-	gormpkg "gorm.io/gorm"
 	"net/http"
 	"strconv"
+	"time"
+	"vinventory/internal/attributes"
+	"vinventory/internal/inventory"
 	"vinventory/internal/models"
+	"vinventory/internal/repository"
 	"vinventory/internal/socket"
+	"vinventory/internal/typetree"
 )
 
 // GetComponentTypes godoc
 // @Summary Get all component types
-// @Description Get details of all component types
+// @Description Get details of all component types. parentId links a type to its category and
+// @Description effectiveSchema includes the attribute definitions inherited from ancestor types.
 // @Tags types
 // @Accept  json
 // @Produce  json
 // @Success 200 {array} models.ComponentType
 // @Router /types [get].
-func GetComponentTypes(database *gormpkg.DB) http.HandlerFunc {
+func GetComponentTypes(store repository.Store) http.HandlerFunc {
 	return socket.GinHandlerToMux(func(context *gin.Context) {
-		var types []models.ComponentType
-
-		if err := database.Find(&types).Error; err != nil {
+		tree, err := typetree.Load(store.Types())
+		if err != nil {
 			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 
-		// Convert Attributes to AttributesList for each component type
-		for i := range types {
-			types[i].AttributesList = types[i].Attributes
-		}
-
-		context.JSON(http.StatusOK, types)
+		// Each type lists its own and its inherited attributes
+		context.JSON(http.StatusOK, tree.All())
 	})
 }
 
@@ -45,18 +45,25 @@ func GetComponentTypes(database *gormpkg.DB) http.HandlerFunc {
 // @Param id path int true "Component Type ID"
 // @Success 200 {object} models.ComponentType
 // @Router /types/{id} [get]
-func GetComponentTypeByID(database *gormpkg.DB) http.HandlerFunc {
+func GetComponentTypeByID(store repository.Store) http.HandlerFunc {
 	return socket.GinHandlerToMux(func(context *gin.Context) {
-		id := context.Param("id")
-
-		var componentType models.ComponentType
-		if err := database.First(&componentType, id).Error; err != nil {
+		id, err := strconv.Atoi(context.Param("id"))
+		if err != nil {
 			context.JSON(http.StatusNotFound, gin.H{"error": "Component type not found"})
 			return
 		}
 
-		// Convert Attributes to AttributesList for the response
-		componentType.AttributesList = componentType.Attributes
+		tree, err := typetree.Load(store.Types())
+		if err != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		componentType, ok := tree.Get(id)
+		if !ok {
+			context.JSON(http.StatusNotFound, gin.H{"error": "Component type not found"})
+			return
+		}
 
 		context.JSON(http.StatusOK, componentType)
 	})
@@ -64,14 +71,16 @@ func GetComponentTypeByID(database *gormpkg.DB) http.HandlerFunc {
 
 // CreateComponentType godoc
 // @Summary Create a new component type
-// @Description Create a new component type with the input payload
+// @Description Create a new component type with the input payload. Attributes are described by attributeSchema;
+// @Description bare names in attributes get the built-in definition or an optional string definition.
+// @Description The mandatory warrantyEndDate and serialNumber attributes are always part of the schema.
 // @Tags types
 // @Accept  json
 // @Produce  json
 // @Param component_type body models.ComponentType true "Component Type"
 // @Success 201 {object} models.ComponentType
 // @Router /types [post]
-func CreateComponentType(database *gormpkg.DB) http.HandlerFunc {
+func CreateComponentType(store repository.Store) http.HandlerFunc {
 	return socket.GinHandlerToMux(func(context *gin.Context) {
 		var componentType models.ComponentType
 		if err := context.ShouldBindJSON(&componentType); err != nil {
@@ -79,18 +88,44 @@ func CreateComponentType(database *gormpkg.DB) http.HandlerFunc {
 			return
 		}
 
-		// Convert AttributesList to Attributes
-		componentType.Attributes = pq.StringArray(componentType.AttributesList)
-		requiredAttributes := []string{"warrantyEndDate", "serialNumber"}
-		componentType.Attributes = append(componentType.Attributes, requiredAttributes...)
+		tree, err := typetree.Load(store.Types())
+		if err != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if componentType.ParentID != nil {
+			if err := tree.CheckParent(componentType.ID, *componentType.ParentID); err != nil {
+				context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		schema, err := attributes.Normalize(componentType.Schema, componentType.AttributesList)
+		if err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		componentType.Schema = schema
+		componentType.SchemaVersion = 1
 
-		if err := database.Create(&componentType).Error; err != nil {
+		err = store.Transaction(func(tx repository.Store) error {
+			if err := tx.Types().Create(&componentType); err != nil {
+				return err
+			}
+			return tx.Types().AddSchemaVersion(&models.ComponentTypeSchemaVersion{
+				TypeID:  componentType.ID,
+				Version: componentType.SchemaVersion,
+				Name:    componentType.Name,
+				Schema:  componentType.Schema,
+			})
+		})
+		if err != nil {
 			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 
-		// Convert Attributes to AttributesList for the response
-		componentType.AttributesList = componentType.Attributes
+		// Resolve the inherited attributes for the response
+		componentType, _ = tree.With(componentType).Get(componentType.ID)
 
 		context.JSON(http.StatusCreated, componentType)
 	})
@@ -98,14 +133,14 @@ func CreateComponentType(database *gormpkg.DB) http.HandlerFunc {
 
 // DeleteComponentType godoc
 // @Summary Delete a component type
-// @Description Delete a component type (only if no components are using this type)
+// @Description Delete a component type (only if no components or sub-types are using this type; use merge to re-home components)
 // @Tags types
 // @Accept  json
 // @Produce  json
 // @Param id path int true "Type ID"
 // @Success 204
 // @Router /types/{id} [delete].
-func DeleteComponentType(database *gormpkg.DB) http.HandlerFunc {
+func DeleteComponentType(store repository.Store) http.HandlerFunc {
 	return socket.GinHandlerToMux(func(context *gin.Context) {
 		idStr := context.Param("id")
 		if idStr == "" {
@@ -120,19 +155,31 @@ func DeleteComponentType(database *gormpkg.DB) http.HandlerFunc {
 		}
 
 		// Check if any components are using this type
-		var componentCount int64
-		if err := database.Model(&models.Component{}).Where("type_id = ?", id).Count(&componentCount).Error; err != nil {
+		componentCount, err := store.Components().CountByType(id)
+		if err != nil {
 			context.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking related components: " + err.Error()})
 			return
 		}
 
 		if componentCount > 0 {
-			context.JSON(http.StatusBadRequest, gin.H{"error": "Cannot delete component type; it is referenced by components"})
+			context.JSON(http.StatusBadRequest, gin.H{"error": "Cannot delete component type; it is referenced by components. Merge it into another type instead"})
+			return
+		}
+
+		// Check if any types are placed under this type
+		childCount, err := store.Types().CountChildren(id)
+		if err != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking sub-types: " + err.Error()})
+			return
+		}
+
+		if childCount > 0 {
+			context.JSON(http.StatusBadRequest, gin.H{"error": "Cannot delete component type; it has sub-types"})
 			return
 		}
 
 		// Delete the component type
-		if err := database.Delete(&models.ComponentType{}, id).Error; err != nil {
+		if err := store.Types().Delete(id); err != nil {
 			context.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting component type: " + err.Error()})
 			return
 		}
@@ -143,15 +190,19 @@ func DeleteComponentType(database *gormpkg.DB) http.HandlerFunc {
 
 // UpdateComponentType godoc
 // @Summary Update an existing component type
-// @Description Update an existing component type
+// @Description Update an existing component type. Custom attributes listed in renames keep their stored values
+// @Description under the new name and retyped values are converted. If stored values would be discarded the
+// @Description update is refused with 409 and a preview, unless force is set. Every update records a schema version,
+// @Description and rewritten values are recorded in the change history of their components.
 // @Tags types
 // @Accept  json
 // @Produce  json
 // @Param id path int true "Type ID"
-// @Param type body models.ComponentType true "Component Type"
+// @Param type body ComponentTypeUpdate true "Component Type Update"
 // @Success 200 {object} models.ComponentType
+// @Failure 409 {object} SchemaPreview
 // @Router /types/{id} [put].
-func UpdateComponentType(database *gormpkg.DB) http.HandlerFunc {
+func UpdateComponentType(store repository.Store) http.HandlerFunc {
 	return socket.GinHandlerToMux(func(context *gin.Context) {
 		idStr := context.Param("id")
 		if idStr == "" {
@@ -165,28 +216,166 @@ func UpdateComponentType(database *gormpkg.DB) http.HandlerFunc {
 			return
 		}
 
-		var componentType models.ComponentType
+		var input ComponentTypeUpdate
+		if err := context.ShouldBindJSON(&input); err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": "Error binding JSON: " + err.Error()})
+			return
+		}
 
-		if err := database.First(&componentType, id).Error; err != nil {
-			context.JSON(http.StatusNotFound, gin.H{"error": "Component type not found for ID: " + idStr})
+		updateComponentType(context, store, id, func(models.ComponentType) (ComponentTypeUpdate, error) {
+			return input, nil
+		})
+	})
+}
+
+// PatchComponentType godoc
+// @Summary Partially update a component type
+// @Description Applies a JSON Merge Patch (RFC 7396) to a component type, given as a ComponentTypeUpdate holding
+// @Description its current name, parentId and own attributeSchema. Only the members in the patch change; the
+// @Description attributeSchema is replaced as a whole and a null parentId moves the type to the top level.
+// @Description renames and force can be added to the patch and the update is checked like a full one.
+// @Tags types
+// @Accept  application/merge-patch+json
+// @Produce  json
+// @Param id path int true "Type ID"
+// @Param patch body object true "Merge patch of the component type"
+// @Success 200 {object} models.ComponentType
+// @Failure 400 {object} map[string]string "Invalid patch or patched type"
+// @Failure 409 {object} SchemaPreview
+// @Failure 415 {object} map[string]string "The body is not a merge patch"
+// @Router /types/{id} [patch]
+func PatchComponentType(store repository.Store) http.HandlerFunc {
+	return socket.GinHandlerToMux(func(context *gin.Context) {
+		idStr := context.Param("id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid component type ID: " + idStr})
 			return
 		}
 
-		var input models.ComponentType
-		if err := context.ShouldBindJSON(&input); err != nil {
-			context.JSON(http.StatusBadRequest, gin.H{"error": "Error binding JSON: " + err.Error()})
+		patch, ok := readMergePatch(context)
+		if !ok {
 			return
 		}
 
-		componentType.Name = input.Name
-		componentType.Attributes = pq.StringArray(input.AttributesList)
+		// The patch applies to the locked type, so concurrent updates cannot be lost
+		updateComponentType(context, store, id, func(componentType models.ComponentType) (ComponentTypeUpdate, error) {
+			current := ComponentTypeUpdate{Name: componentType.Name, Schema: componentType.Schema, ParentID: componentType.ParentID}
+			var input ComponentTypeUpdate
+			if err := applyMergePatch(current, patch, &input); err != nil {
+				return input, &attributes.ValidationError{Problems: []string{err.Error()}}
+			}
+			if input.Name == "" {
+				return input, &attributes.ValidationError{Problems: []string{"name is required"}}
+			}
+			// Without a parentId the type has no parent, rather than keeping its current one
+			if input.ParentID == nil {
+				topLevel := 0
+				input.ParentID = &topLevel
+			}
+			return input, nil
+		})
+	})
+}
 
-		if err := database.Save(&componentType).Error; err != nil {
-			context.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving to database: " + err.Error()})
-			return
+// updateComponentType applies the update built from the locked component type and records its schema version
+func updateComponentType(context *gin.Context, store repository.Store, id int, build func(models.ComponentType) (ComponentTypeUpdate, error)) {
+	idStr := strconv.Itoa(id)
+
+	// Rewritten attribute values are recorded in the change history of their components
+	actor, ok := requestActor(context, "")
+	if !ok {
+		return
+	}
+
+	var input ComponentTypeUpdate
+	var componentType models.ComponentType
+	var plan schemaPlan
+	err := store.Transaction(func(tx repository.Store) error {
+		locked, err := tx.Types().GetForUpdate(id)
+		if err != nil {
+			return err
 		}
-		componentType.AttributesList = componentType.Attributes
+		componentType = locked[0]
 
-		context.JSON(http.StatusOK, componentType)
+		input, err = build(componentType)
+		if err != nil {
+			return err
+		}
+
+		plan, err = planTypeUpdate(tx, componentType, input, true)
+		if err != nil {
+			return err
+		}
+		if plan.preview.Conflicts > 0 && !input.Force {
+			return errSchemaConflict
+		}
+
+		// Move the stored values of affected components to the new schema
+		now := time.Now()
+		var changes []models.ComponentChange
+		for _, impact := range plan.preview.Components {
+			if !impact.Changed {
+				continue
+			}
+			if err := tx.Components().UpdateAttributes(impact.ComponentID, impact.Values); err != nil {
+				return err
+			}
+
+			before := plan.components[impact.ComponentID]
+			after := before
+			after.Attributes = impact.Values
+			after.Version++
+			changes = append(changes, inventory.FieldChanges(before, after, actor, now)...)
+		}
+		if err := tx.History().CreateChanges(changes); err != nil {
+			return err
+		}
+
+		if input.Name != "" {
+			componentType.Name = input.Name
+		}
+		componentType.Schema = plan.schema
+		componentType.ParentID = plan.parentID
+		componentType.SchemaVersion++
+		if err := tx.Types().Save(&componentType); err != nil {
+			return err
+		}
+
+		return tx.Types().AddSchemaVersion(&models.ComponentTypeSchemaVersion{
+			TypeID:  componentType.ID,
+			Version: componentType.SchemaVersion,
+			Name:    componentType.Name,
+			Schema:  componentType.Schema,
+			Change:  plan.preview.Change,
+		})
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			context.JSON(http.StatusNotFound, gin.H{"error": "Component type not found for ID: " + idStr})
+		case errors.Is(err, errSchemaConflict):
+			context.JSON(http.StatusConflict, gin.H{"error": err.Error(), "preview": plan.preview})
+		default:
+			context.JSON(planErrorStatus(err), gin.H{"error": "Error updating component type: " + err.Error()})
+		}
+		return
+	}
+	componentType, _ = plan.tree.With(componentType).Get(componentType.ID)
+
+	context.JSON(http.StatusOK, componentType)
+}
+
+// GetBuiltInAttributes godoc
+// @Summary Get the built-in attribute definitions
+// @Description Lists the fixed component fields with their data type, unit and allowed values,
+// @Description so that clients can build component type and component forms
+// @Tags types
+// @Produce  json
+// @Success 200 {array} models.AttributeDefinition
+// @Router /types/attributes [get]
+func GetBuiltInAttributes() http.HandlerFunc {
+	return socket.GinHandlerToMux(func(context *gin.Context) {
+		context.JSON(http.StatusOK, attributes.BuiltIns())
 	})
 }
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+	"vinventory/internal/attributes"
+	"vinventory/internal/inventory"
+	"vinventory/internal/lifecycle"
+	"vinventory/internal/models"
+	"vinventory/internal/repository"
+	"vinventory/internal/socket"
+	"vinventory/internal/typetree"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MergeComponentTypeRequest represents the request payload for merging a component type into another
+type MergeComponentTypeRequest struct {
+	TargetTypeID int `json:"targetTypeId"`
+	// AttributeMap maps source custom attribute names to target ones; unmapped names are kept.
+	AttributeMap map[string]string `json:"attributeMap"`
+	// OnBehalfOf records the type changes for another user; it requires the inventory.act-on-behalf permission.
+	OnBehalfOf string `json:"onBehalfOf"`
+	// Force merges even if stored values have to be discarded.
+	Force bool `json:"force"`
+	// DryRun only returns the preview.
+	DryRun bool `json:"dryRun"`
+}
+
+// MergeComponentTypeResponse reports the outcome of a merge
+type MergeComponentTypeResponse struct {
+	Target          models.ComponentType `json:"target"`
+	MovedComponents int                  `json:"movedComponents"`
+	Preview         SchemaPreview        `json:"preview"`
+}
+
+// MergeComponentType godoc
+// @Summary Merge a component type into another
+// @Description Moves every component of the type to the target type in a single transaction, mapping custom
+// @Description attributes, records a "Type Changed" inventory history entry and the changed fields per component
+// @Description and deletes the source type. Refused with 409 and a preview if stored values would be discarded, unless force is set.
+// @Tags types
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Source Type ID"
+// @Param merge body MergeComponentTypeRequest true "Merge Request"
+// @Success 200 {object} MergeComponentTypeResponse
+// @Failure 409 {object} SchemaPreview
+// @Router /types/{id}/merge [post]
+func MergeComponentType(store repository.Store) http.HandlerFunc {
+	return socket.GinHandlerToMux(func(context *gin.Context) {
+		idStr := context.Param("id")
+		sourceID, err := strconv.Atoi(idStr)
+		if err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid component type ID: " + idStr})
+			return
+		}
+
+		var request MergeComponentTypeRequest
+		if err := context.ShouldBindJSON(&request); err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if request.TargetTypeID == sourceID {
+			context.JSON(http.StatusBadRequest, gin.H{"error": "Cannot merge a component type into itself"})
+			return
+		}
+
+		// Resolve the acting user before anything is written
+		var actor inventory.Actor
+		if !request.DryRun {
+			var ok bool
+			if actor, ok = requestActor(context, request.OnBehalfOf); !ok {
+				return
+			}
+		}
+
+		var response MergeComponentTypeResponse
+		err = store.Transaction(func(tx repository.Store) error {
+			// Lock both types so concurrent merges cannot interleave
+			if _, err := tx.Types().GetForUpdate(sourceID, request.TargetTypeID); err != nil {
+				return err
+			}
+
+			tree, err := typetree.Load(tx.Types())
+			if err != nil {
+				return err
+			}
+			if len(tree.Children(sourceID)) > 0 {
+				return &attributes.ValidationError{Problems: []string{"the source type has sub-types; move or merge them first"}}
+			}
+			source, _ := tree.Get(sourceID)
+			target, _ := tree.Get(request.TargetTypeID)
+
+			change, err := attributes.MergeChange(source.EffectiveSchema, target.EffectiveSchema, request.AttributeMap)
+			if err != nil {
+				return err
+			}
+
+			components, err := tx.Components().ListByTypeForUpdate(source.ID)
+			if err != nil {
+				return err
+			}
+
+			preview := SchemaPreview{Change: change, TotalComponents: len(components), Components: []attributes.Impact{}}
+			impacts := make([]attributes.Impact, 0, len(components))
+			for _, component := range components {
+				impact := attributes.Plan(component, change, target.EffectiveSchema)
+				impacts = append(impacts, impact)
+				if impact.Changed {
+					preview.AffectedComponents++
+				}
+				if len(impact.Conflicts) > 0 {
+					preview.Conflicts++
+				}
+				if impact.Changed || len(impact.Conflicts) > 0 || len(impact.Missing) > 0 {
+					preview.Components = append(preview.Components, impact)
+				}
+			}
+
+			response.Target = target
+			response.Preview = preview
+			if request.DryRun {
+				return nil
+			}
+			if preview.Conflicts > 0 && !request.Force {
+				return errSchemaConflict
+			}
+
+			now := time.Now()
+			var changes []models.ComponentChange
+			for i, impact := range impacts {
+				if err := tx.Components().ChangeType(impact.ComponentID, target.ID, impact.Values); err != nil {
+					return err
+				}
+
+				after := components[i]
+				after.TypeID = target.ID
+				after.Attributes = impact.Values
+				after.Version++
+				changes = append(changes, inventory.FieldChanges(components[i], after, actor, now)...)
+
+				history := models.InventoryHistory{
+					ComponentID:    impact.ComponentID,
+					UserID:         actor.UserID,
+					UserName:       actor.UserName,
+					OperationType:  lifecycle.TypeChanged,
+					CreatedAt:      now,
+					RecordedByID:   actor.RecordedByID,
+					RecordedByName: actor.RecordedByName,
+				}
+				if err := tx.History().Create(&history); err != nil {
+					return err
+				}
+			}
+			if err := tx.History().CreateChanges(changes); err != nil {
+				return err
+			}
+			response.MovedComponents = len(impacts)
+
+			return tx.Types().Delete(source.ID)
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrNotFound):
+				context.JSON(http.StatusNotFound, gin.H{"error": "Source or target component type not found"})
+			case errors.Is(err, errSchemaConflict):
+				context.JSON(http.StatusConflict, gin.H{"error": err.Error(), "preview": response.Preview})
+			default:
+				context.JSON(planErrorStatus(err), gin.H{"error": "Error merging component types: " + err.Error()})
+			}
+			return
+		}
+		context.JSON(http.StatusOK, response)
+	})
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"vinventory/internal/auth"
+	"vinventory/internal/lifecycle"
+	"vinventory/internal/models"
+	"vinventory/internal/repository"
+)
+
+func TestMergeComponentType(t *testing.T) {
+	server := newTestServer(t)
+	notebook := server.addType(t, "Notebook", "colour", "gpu")
+	laptop := server.addType(t, "Laptop", "color")
+	first := server.addComponent(t, notebook.ID, "SN-1", models.AttributeValues{"colour": "black", "gpu": "rtx"})
+	second := server.addComponent(t, notebook.ID, "SN-2", models.AttributeValues{"colour": "white"})
+
+	path := "/types/" + strconv.Itoa(notebook.ID) + "/merge"
+	mapped := `{"targetTypeId": 2, "attributeMap": {"colour": "color"}}`
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		status int
+	}{
+		{name: "unknown source", path: "/types/9/merge", body: mapped, status: http.StatusNotFound},
+		{name: "unknown target", path: path, body: `{"targetTypeId": 9}`, status: http.StatusNotFound},
+		{name: "itself", path: path, body: `{"targetTypeId": 1}`, status: http.StatusBadRequest},
+		{name: "unknown attribute", path: path, body: `{"targetTypeId": 2, "attributeMap": {"colour": "shade"}}`, status: http.StatusBadRequest},
+		// The first component would lose its gpu
+		{name: "conflict", path: path, body: mapped, status: http.StatusConflict},
+		{name: "dry run", path: path, body: `{"targetTypeId": 2, "attributeMap": {"colour": "color"}, "dryRun": true}`, status: http.StatusOK},
+	}
+	for _, test := range tests {
+		recorder := server.do(http.MethodPost, test.path, test.body)
+		if recorder.Code != test.status {
+			t.Errorf("%s: status = %d; want %d (%s)", test.name, recorder.Code, test.status, recorder.Body)
+		}
+	}
+	if _, err := server.store.Types().Get(notebook.ID); err != nil {
+		t.Fatalf("the source type is gone before the merge: %v", err)
+	}
+	if history, _ := server.store.History().ListByComponent(first.ID); len(history) != 0 {
+		t.Errorf("history before the merge = %+v; want none", history)
+	}
+
+	// Acting for someone else requires the permission
+	server.principal = auth.DefaultPolicy().Grant(auth.Principal{ID: "viewer", Name: "Mehmet Demir"})
+	recorder := server.do(http.MethodPost, path, `{"targetTypeId": 2, "attributeMap": {"colour": "color"}, "force": true, "onBehalfOf": "someone"}`)
+	if recorder.Code != http.StatusForbidden {
+		t.Errorf("merge on behalf of someone status = %d; want 403", recorder.Code)
+	}
+
+	// The actor comes from the signed-in principal
+	recorder = server.do(http.MethodPost, path, `{"targetTypeId": 2, "attributeMap": {"colour": "color"}, "force": true}`)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("forced merge status = %d; want 200 (%s)", recorder.Code, recorder.Body)
+	}
+	var response MergeComponentTypeResponse
+	decode(t, recorder, &response)
+	if response.MovedComponents != 2 || response.Target.ID != laptop.ID || response.Preview.Conflicts != 1 {
+		t.Errorf("response = %+v; want 2 components moved to Laptop", response)
+	}
+	if _, err := server.store.Types().Get(notebook.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("source type lookup error = %v; want ErrNotFound", err)
+	}
+
+	for _, test := range []struct {
+		component models.Component
+		values    models.AttributeValues
+		changed   []string
+	}{
+		{component: first, values: models.AttributeValues{"color": "black"}, changed: []string{"color", "colour", "gpu", "typeId"}},
+		{component: second, values: models.AttributeValues{"color": "white"}, changed: []string{"color", "colour", "typeId"}},
+	} {
+		stored, err := server.store.Components().Get(test.component.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.TypeID != laptop.ID || !reflect.DeepEqual(stored.Attributes, test.values) {
+			t.Errorf("component %d = type %d %v; want type %d %v", stored.ID, stored.TypeID, stored.Attributes, laptop.ID, test.values)
+		}
+
+		history, err := server.store.History().ListByComponent(test.component.ID)
+		if err != nil || len(history) != 1 {
+			t.Fatalf("history of component %d = %+v, %v; want one entry", test.component.ID, history, err)
+		}
+		entry := history[0]
+		if entry.OperationType != lifecycle.TypeChanged || entry.UserID != "viewer" || entry.RecordedByID != "viewer" || entry.RecordedByName != "Mehmet Demir" {
+			t.Errorf("history entry = %+v; want Type Changed recorded by viewer", entry)
+		}
+
+		fields := changedFields(t, server, test.component.ID, "viewer")
+		var changed []string
+		for _, field := range []string{"color", "colour", "gpu", "typeId"} {
+			if change, ok := fields[field]; ok {
+				changed = append(changed, field)
+				if change.Version != stored.Version {
+					t.Errorf("change of %s recorded for version %d; want %d", field, change.Version, stored.Version)
+				}
+			}
+		}
+		if len(fields) != len(test.changed) || !reflect.DeepEqual(changed, test.changed) {
+			t.Errorf("changes of component %d = %+v; want %v", test.component.ID, fields, test.changed)
+		}
+		if typeID := fields["typeId"]; value(typeID.OldValue) != strconv.Itoa(notebook.ID) || value(typeID.NewValue) != strconv.Itoa(laptop.ID) {
+			t.Errorf("typeId change = %s -> %s", value(typeID.OldValue), value(typeID.NewValue))
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"vinventory/internal/attributes"
+	"vinventory/internal/models"
+	"vinventory/internal/repository"
+	"vinventory/internal/socket"
+	"vinventory/internal/typetree"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errSchemaConflict is returned when a schema update would lose stored values and force is not set.
+var errSchemaConflict = errors.New("the schema change would discard stored attribute values; review the preview and retry with force")
+
+// ComponentTypeUpdate represents the request payload for updating a component type
+type ComponentTypeUpdate struct {
+	Name           string                 `json:"name"`
+	Schema         models.AttributeSchema `json:"attributeSchema"`
+	AttributesList []string               `json:"attributes"`
+	// Renames maps old custom attribute names to new ones; stored values are moved along.
+	Renames map[string]string `json:"renames"`
+	// ParentID moves the type under another type; 0 moves it to the top level and null keeps it in place.
+	ParentID *int `json:"parentId"`
+	// Force applies the change even if stored values have to be discarded.
+	Force bool `json:"force"`
+}
+
+// SchemaPreview describes the effect of a component type update on existing components,
+// including the components of its sub-types
+type SchemaPreview struct {
+	Change             models.SchemaChange `json:"change"`
+	TotalComponents    int                 `json:"totalComponents"`
+	AffectedComponents int                 `json:"affectedComponents"`
+	Conflicts          int                 `json:"conflicts"`
+	Components         []attributes.Impact `json:"components"`
+}
+
+// schemaPlan is a validated component type update together with its preview.
+type schemaPlan struct {
+	schema   models.AttributeSchema
+	parentID *int
+	tree     *typetree.Tree
+	preview  SchemaPreview
+	// components holds the components of the previewed impacts by ID, as they were read
+	components map[int]models.Component
+}
+
+// planTypeUpdate validates an update against the current type and works out its impact
+// on the components of the type and of all its sub-types. With lock set the components are
+// locked until the surrounding transaction ends, so the plan can be applied without losing
+// edits made meanwhile
+func planTypeUpdate(store repository.Store, componentType models.ComponentType, input ComponentTypeUpdate, lock bool) (schemaPlan, error) {
+	tree, err := typetree.Load(store.Types())
+	if err != nil {
+		return schemaPlan{}, err
+	}
+
+	// Inherited names stay inherited unless the type overrides them
+	var names []string
+	for _, name := range input.AttributesList {
+		if _, own := componentType.Schema.Find(name); own || !tree.Inherited(componentType.ID, name) {
+			names = append(names, name)
+		}
+	}
+
+	// Bare names keep the definition they already have on this type
+	definitions := input.Schema
+	if len(definitions) == 0 {
+		for _, name := range names {
+			if definition, ok := componentType.Schema.Find(name); ok {
+				definitions = append(definitions, definition)
+			}
+		}
+	}
+
+	schema, err := attributes.Normalize(definitions, names)
+	if err != nil {
+		return schemaPlan{}, err
+	}
+
+	updated := componentType
+	updated.Schema = schema
+	if input.ParentID != nil {
+		if *input.ParentID == 0 {
+			updated.ParentID = nil
+		} else if err := tree.CheckParent(componentType.ID, *input.ParentID); err != nil {
+			return schemaPlan{}, &attributes.ValidationError{Problems: []string{err.Error()}}
+		} else {
+			parentID := *input.ParentID
+			updated.ParentID = &parentID
+		}
+	}
+	updatedTree := tree.With(updated)
+
+	preview := SchemaPreview{Components: []attributes.Impact{}}
+	planned := map[int]models.Component{}
+	for _, typeID := range tree.Descendants(componentType.ID) {
+		effective := updatedTree.EffectiveSchema(typeID)
+		change, err := attributes.Diff(tree.EffectiveSchema(typeID), effective, input.Renames)
+		if err != nil {
+			return schemaPlan{}, err
+		}
+		if typeID == componentType.ID {
+			preview.Change = change
+		}
+
+		listByType := store.Components().ListByType
+		if lock {
+			listByType = store.Components().ListByTypeForUpdate
+		}
+		components, err := listByType(typeID)
+		if err != nil {
+			return schemaPlan{}, err
+		}
+
+		preview.TotalComponents += len(components)
+		for _, component := range components {
+			impact := attributes.Plan(component, change, effective)
+			if !impact.Changed && len(impact.Conflicts) == 0 && len(impact.Missing) == 0 {
+				continue
+			}
+			if impact.Changed {
+				preview.AffectedComponents++
+			}
+			if len(impact.Conflicts) > 0 {
+				preview.Conflicts++
+			}
+			preview.Components = append(preview.Components, impact)
+			planned[component.ID] = component
+		}
+	}
+
+	return schemaPlan{schema: schema, parentID: updated.ParentID, tree: tree, preview: preview, components: planned}, nil
+}
+
+// planErrorStatus maps an error from planTypeUpdate to an HTTP status
+func planErrorStatus(err error) int {
+	var validationErr *attributes.ValidationError
+	if errors.As(err, &validationErr) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// PreviewComponentTypeUpdate godoc
+// @Summary Preview a component type update
+// @Description Shows how an update would affect existing components: renamed values, values that would be
+// @Description discarded (conflicts) and required attributes without a value. Nothing is changed.
+// @Tags types
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Type ID"
+// @Param type body ComponentTypeUpdate true "Component Type Update"
+// @Success 200 {object} SchemaPreview
+// @Router /types/{id}/preview [post]
+func PreviewComponentTypeUpdate(store repository.Store) http.HandlerFunc {
+	return socket.GinHandlerToMux(func(context *gin.Context) {
+		idStr := context.Param("id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid component type ID: " + idStr})
+			return
+		}
+
+		componentType, err := store.Types().Get(id)
+		if err != nil {
+			context.JSON(http.StatusNotFound, gin.H{"error": "Component type not found for ID: " + idStr})
+			return
+		}
+
+		var input ComponentTypeUpdate
+		if err := context.ShouldBindJSON(&input); err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": "Error binding JSON: " + err.Error()})
+			return
+		}
+
+		plan, err := planTypeUpdate(store, componentType, input, false)
+		if err != nil {
+			context.JSON(planErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		context.JSON(http.StatusOK, plan.preview)
+	})
+}
+
+// GetComponentTypeSchemaVersions godoc
+// @Summary Get the schema history of a component type
+// @Description Lists every recorded schema version of a component type, newest first
+// @Tags types
+// @Produce  json
+// @Param id path int true "Type ID"
+// @Success 200 {array} models.ComponentTypeSchemaVersion
+// @Router /types/{id}/schema-versions [get]
+func GetComponentTypeSchemaVersions(store repository.Store) http.HandlerFunc {
+	return socket.GinHandlerToMux(func(context *gin.Context) {
+		idStr := context.Param("id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid component type ID: " + idStr})
+			return
+		}
+
+		versions, err := store.Types().SchemaVersions(id)
+		if err != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		context.JSON(http.StatusOK, versions)
+	})
+}
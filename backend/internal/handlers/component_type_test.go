@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"vinventory/internal/models"
+)
+
+func TestUpdateComponentType(t *testing.T) {
+	server := newTestServer(t)
+	laptop := server.addType(t, "Laptop", "color", "gpu")
+	first := server.addComponent(t, laptop.ID, "SN-1", models.AttributeValues{"color": "black", "gpu": "rtx"})
+	second := server.addComponent(t, laptop.ID, "SN-2", models.AttributeValues{"color": "white"})
+
+	renamed := `{"name": "Laptop", "attributeSchema": [{"name": "colour", "type": "string"}, {"name": "gpu", "type": "string"}], "renames": {"color": "colour"}}`
+	removed := `{"name": "Laptop", "attributeSchema": [{"name": "colour", "type": "string"}]}`
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		status int
+	}{
+		{name: "unknown type", path: "/types/9", body: renamed, status: http.StatusNotFound},
+		{name: "invalid ID", path: "/types/x", body: renamed, status: http.StatusBadRequest},
+		{name: "invalid schema", path: "/types/1", body: `{"name": "Laptop", "attributeSchema": [{"name": "gpu"}, {"name": "gpu"}]}`, status: http.StatusBadRequest},
+		{name: "rename", path: "/types/1", body: renamed, status: http.StatusOK},
+		// Removing gpu would discard the value of the first component
+		{name: "conflict", path: "/types/1", body: removed, status: http.StatusConflict},
+	}
+	for _, test := range tests {
+		recorder := server.do(http.MethodPut, test.path, test.body)
+		if recorder.Code != test.status {
+			t.Errorf("%s: status = %d; want %d (%s)", test.name, recorder.Code, test.status, recorder.Body)
+		}
+	}
+
+	// The rename moved the stored values and recorded them as changes of the acting user
+	for _, test := range []struct {
+		component models.Component
+		values    models.AttributeValues
+		version   int
+	}{
+		{component: first, values: models.AttributeValues{"colour": "black", "gpu": "rtx"}, version: 2},
+		{component: second, values: models.AttributeValues{"colour": "white"}, version: 2},
+	} {
+		stored, err := server.store.Components().Get(test.component.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(stored.Attributes, test.values) || stored.Version != test.version {
+			t.Errorf("component %d = %v version %d; want %v version %d", stored.ID, stored.Attributes, stored.Version, test.values, test.version)
+		}
+		fields := changedFields(t, server, test.component.ID, "manager")
+		color := test.component.Attributes["color"]
+		if len(fields) != 2 || fields["color"].OldValue == nil || *fields["color"].OldValue != color || fields["color"].NewValue != nil ||
+			value(fields["colour"].NewValue) != color || fields["colour"].Version != test.version {
+			t.Errorf("changes of component %d = %+v; want color moved to colour", test.component.ID, fields)
+		}
+	}
+
+	// A conflicting update changes nothing and answers with the preview
+	recorder := server.do(http.MethodPut, "/types/1", removed)
+	var conflict struct {
+		Preview SchemaPreview `json:"preview"`
+	}
+	decode(t, recorder, &conflict)
+	if conflict.Preview.Conflicts != 1 || len(conflict.Preview.Components) != 1 || conflict.Preview.Components[0].ComponentID != first.ID {
+		t.Errorf("conflict preview = %+v; want the first component", conflict.Preview)
+	}
+	if stored, _ := server.store.Components().Get(first.ID); stored.Attributes["gpu"] != "rtx" {
+		t.Errorf("conflicting update changed the component: %v", stored.Attributes)
+	}
+
+	// Forcing it discards the value and records that as well
+	recorder = server.do(http.MethodPut, "/types/1", `{"name": "Laptop", "attributeSchema": [{"name": "colour", "type": "string"}], "force": true}`)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("forced update status = %d; want 200 (%s)", recorder.Code, recorder.Body)
+	}
+	var componentType models.ComponentType
+	decode(t, recorder, &componentType)
+	if componentType.SchemaVersion != 3 {
+		t.Errorf("schema version = %d; want 3", componentType.SchemaVersion)
+	}
+	fields := changedFields(t, server, first.ID, "manager")
+	if gpu := fields["gpu"]; value(gpu.OldValue) != "rtx" || gpu.NewValue != nil || gpu.Version != 3 {
+		t.Errorf("gpu change = %+v; want rtx removed in version 3", gpu)
+	}
+	if changes, _ := server.store.History().ListChanges(second.ID); len(changes) != 2 {
+		t.Errorf("changes of the second component = %+v; want only the rename", changes)
+	}
+}
@@ -1,34 +1,57 @@
 package handlers
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
-	"time"
 
+	"vinventory/internal/attributes"
+	"vinventory/internal/identity"
+	"vinventory/internal/inventory"
+	"vinventory/internal/lifecycle"
 	"vinventory/internal/models"
+	"vinventory/internal/query"
+	"vinventory/internal/repository"
 	"vinventory/internal/socket"
+	"vinventory/internal/typetree"
 
 	"github.com/gin-gonic/gin"
-	gormpkg "gorm.io/gorm"
 )
 
+// fetchUsers returns the users of the directory by ID
+func fetchUsers(context *gin.Context) (map[string]models.User, error) {
+	users, err := identity.Current().Users(context.Request.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	userMap := make(map[string]models.User, len(users))
+	for _, user := range users {
+		userMap[user.ID] = user
+	}
+	return userMap, nil
+}
+
 // GetComponents godoc
 // @Summary Get details of all component items with optional search, filters, and sorting
 // @Description If no query parameter is passed api gets all components, search query parameter searchs
-// for components that contains the given string in their bran, model, serial number attribute or current user.
+// for components that contains the given string in their bran, model, serial number, notes, type or current user.
+// On Postgres the words of the search match as prefixes, results are ranked and highlights holds matching snippets.
 // also attributes like status, brand, type, model_year, screen_size, processor_type, processor_cores, ram, serial_number, condition
-// can be passed as query parameters to filter. sort query parameter can be passed to sort the results according to specific attribute
+// can be passed as query parameters to filter. An operator can be added in brackets, e.g. ram[gte]=16 or
+// status[in]=Ready to Use,In Repair. Custom attributes of a type are filtered with attributes[name]=value.
+// sort query parameter can be passed to sort the results according to specific attribute.
+// Results are paginated with limit and offset; the response holds the total count and links to the next and previous pages.
 // @Tags components
 // @Accept  json
 // @Produce  json
-// @Param search query string false "Search term"
+// @Param search query string false "Search terms, optionally with field terms such as type:Laptop status:\"Being Used\" ram>=16 holder:ayse warranty<90d"
 // @Param status query string false "Status of the component"
 // @Param brand query string false "Brand of the component"
-// @Param type query string false "Type ID of the component"
+// @Param type_id query int false "Type ID of the component, including its sub-types"
 // @Param model_year query int false "Model year of the component"
 // @Param screen_size query string false "Screen size of the component"
 // @Param processor_type query string false "Processor type of the component"
@@ -36,141 +59,165 @@ import (
 // @Param ram query int false "RAM size of the component"
 // @Param serial_number query string false "Serial number of the component"
 // @Param condition query string false "Condition of the component"
-// @Param sort query string false "Field to sort by"
-// @Param order query string false "Order direction (asc/desc)"
-// @Success 200 {array} models.Component
+// @Param field[operator] query string false "Filter with an operator: eq, ne, gt, gte, lt, lte, in (comma separated), between (two comma separated values) or isnull (e.g. ram[gte]=16, notes[isnull])"
+// @Param sort query string false "Comma separated fields to sort by, - prefix for descending (e.g. brand,-warrantyEndDate)"
+// @Param order query string false "Order direction of a single sort field (asc/desc)"
+// @Param limit query int false "Page size (default 25, at most 200)"
+// @Param offset query int false "Number of components to skip"
+// @Success 200 {object} ComponentPage
 // @Router /components [get]
-func fetchUsers() (map[string]models.User, error) {
-	token, err := GetAccessToken()
-	if err != nil {
-		return nil, err
-	}
+func GetComponents(store repository.Store) http.HandlerFunc {
+	return socket.GinHandlerToMux(func(context *gin.Context) {
+		limit, offset, err := parsePage(context)
+		if err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
-	usersURL := "https://graph.microsoft.com/v1.0/users"
-	req, err := http.NewRequest("GET", usersURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
+		filter, ok := componentFilter(context, store)
+		if !ok {
+			return
+		}
+		filter.Limit = limit
+		filter.Offset = offset
 
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token.AccessToken))
+		// Sorting
+		filter.Sort, err = query.ParseSort(context.Query("sort"), context.Query("order"))
+		if err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %w", err)
-	}
-	defer resp.Body.Close()
+		// Fetch the page and the total number of matches
+		components, err := store.Components().List(filter)
+		if err != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		total, err := store.Components().Count(filter)
+		if err != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if components == nil {
+			components = []models.Component{}
+		}
 
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		bodyString := string(bodyBytes)
-		return nil, fmt.Errorf("failed to fetch users: %s", bodyString)
-	}
+		var highlights map[int]string
+		if filter.Search != "" {
+			ids := make([]int, len(components))
+			for i, component := range components {
+				ids[i] = component.ID
+			}
+			highlights, err = store.Components().Highlights(ids, filter.Search)
+			if err != nil {
+				context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
-	}
+		context.JSON(http.StatusOK, ComponentPage{
+			Data:       components,
+			Total:      total,
+			Limit:      limit,
+			Offset:     offset,
+			Links:      pageLinks(context.Request, limit, offset, total),
+			Highlights: highlights,
+		})
+	})
+}
 
-	var usersData map[string]interface{}
-	if err := json.Unmarshal(body, &usersData); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response body: %w", err)
+// componentFilter builds the component filter described by the query parameters of a list
+// request, writing an error response and returning false if they are invalid. Paging and
+// sorting are left to the caller.
+func componentFilter(context *gin.Context, store repository.Store) (repository.ComponentFilter, bool) {
+	filter := repository.ComponentFilter{
+		Attributes: context.QueryMap("attributes"),
 	}
 
-	userMap := make(map[string]models.User)
-	for _, user := range usersData["value"].([]interface{}) {
-		u := user.(map[string]interface{})
-		id, _ := u["id"].(string)
-		displayName, _ := u["displayName"].(string)
-		email, _ := u["mail"].(string)
-		filteredUser := models.User{
-			ID:          id,
-			DisplayName: displayName,
-			Email:       email,
-		}
-		userMap[id] = filteredUser
+	// Filtering, by API name or column name with an optional operator
+	var err error
+	filter.Conditions, err = query.ParseFilters(context.Request.URL.Query())
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return filter, false
 	}
-
-	return userMap, nil
-}
-
-func GetComponents(database *gormpkg.DB) http.HandlerFunc {
-	return socket.GinHandlerToMux(func(context *gin.Context) {
-		var components []models.Component
-		query := database.Model(&models.Component{})
-
-		// Filtering
-		if status := context.Query("status"); status != "" {
-			query = query.Where("status = ?", status)
-		}
-		if brand := context.Query("brand"); brand != "" {
-			query = query.Where("brand = ?", brand)
-		}
-		if typeID := context.Query("type_id"); typeID != "" {
-			query = query.Where("type_id = ?", typeID)
-		}
-		if modelYear := context.Query("model_year"); modelYear != "" {
-			query = query.Where("model_year = ?", modelYear)
-		}
-		if screenSize := context.Query("screen_size"); screenSize != "" {
-			query = query.Where("screen_size = ?", screenSize)
-		}
-		if processorType := context.Query("processor_type"); processorType != "" {
-			query = query.Where("processor_type = ?", processorType)
-		}
-		if processorCores := context.Query("processor_cores"); processorCores != "" {
-			query = query.Where("processor_cores = ?", processorCores)
-		}
-		if ram := context.Query("ram"); ram != "" {
-			query = query.Where("ram = ?", ram)
-		}
-		if serialNumber := context.Query("serial_number"); serialNumber != "" {
-			query = query.Where("serial_number = ?", serialNumber)
-		}
-		if condition := context.Query("condition"); condition != "" {
-			query = query.Where("condition = ?", condition)
+	if typeID := context.Query("type_id"); typeID != "" {
+		// A type also matches every component of its sub-types
+		id, err := strconv.Atoi(typeID)
+		if err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid type_id"})
+			return filter, false
 		}
-
-		// Fetch user data
-		userMap, err := fetchUsers()
+		tree, err := typetree.Load(store.Types())
 		if err != nil {
 			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
+			return filter, false
 		}
+		filter.TypeIDs = tree.Descendants(id)
+	}
 
-		// Search
-		searchString := context.Query("search")
-		if searchString != "" {
-			searchPattern := "%" + searchString + "%"
-			userIDs := getUserIDs(userMap, searchString)
-
-			subQuery := database.Table("(?) as ih", database.Model(&models.InventoryHistory{}).
-				Select("DISTINCT ON (component_id) *").
-				Order("component_id, created_at DESC"))
+	// Search
+	search, err := query.ParseSearch(context.Query("search"))
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return filter, false
+	}
+	filter.Conditions = append(filter.Conditions, search.Conditions...)
 
-			query = query.Joins("LEFT JOIN (?) as last_ih ON components.id = last_ih.component_id", subQuery).
-				Where("components.brand ILIKE ? OR components.model ILIKE ? OR components.serial_number ILIKE ? OR (components.status = 'Being Used' AND last_ih.user_id IN (?))",
-					searchPattern, searchPattern, searchPattern, userIDs)
+	if len(search.Types) > 0 || len(search.Attributes) > 0 {
+		tree, err := typetree.Load(store.Types())
+		if err != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return filter, false
 		}
-
-		// Sorting
-		sort := context.Query("sort")
-		order := context.Query("order")
-		if sort != "" {
-			if order != "desc" {
-				order = "asc"
+		for name, value := range search.Attributes {
+			if !declared(tree, name) {
+				context.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown search field %q", name)})
+				return filter, false
 			}
-			query = query.Order(sort + " " + order)
+			filter.Attributes[name] = value
 		}
+		if len(search.Types) > 0 {
+			typeIDs, err := typeIDsByName(tree, search.Types)
+			if err != nil {
+				context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return filter, false
+			}
+			if filter.TypeIDs != nil {
+				typeIDs = intersect(filter.TypeIDs, typeIDs)
+			}
+			filter.TypeIDs = typeIDs
+		}
+	}
 
-		// Fetch the results
-		if err := query.Distinct("components.*").Find(&components).Error; err != nil {
+	filter.Search = search.Text
+
+	// Holders are matched against the users of the directory
+	if len(search.Holders) > 0 {
+		userMap, err := fetchUsers(context)
+		if err != nil {
 			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
+			return filter, false
 		}
+		filter.Holders = []string{}
+		for _, holder := range search.Holders {
+			filter.Holders = append(filter.Holders, getUserIDs(userMap, holder)...)
+		}
+	}
 
-		context.JSON(http.StatusOK, components)
-	})
+	return filter, true
+}
+
+// ComponentPage is one page of the component list
+type ComponentPage struct {
+	Data   []models.Component `json:"data"`
+	Total  int64              `json:"total"`
+	Limit  int                `json:"limit"`
+	Offset int                `json:"offset"`
+	Links  PageLinks          `json:"links"`
+	// Highlights maps component IDs to an HTML snippet of their escaped text with the search matches in <mark> tags
+	Highlights map[int]string `json:"highlights,omitempty"`
 }
 
 // getUserIDs returns a slice of user IDs whose display name matches the search pattern
@@ -199,24 +246,31 @@ func getUserIDs(userMap map[string]models.User, searchPattern string) []string {
 
 // GetComponentByID godoc
 // @Summary Get a specific component item
-// @Description Get details of a specific component item
+// @Description Get details of a specific component item. The ETag header holds its version, to be sent
+// @Description back in If-Match when changing it
 // @Tags components
 // @Accept  json
 // @Produce  json
 // @Param id path int true "Component ID"
 // @Success 200 {object} models.Component
+// @Header 200 {string} ETag "Version of the component"
 // @Router /components/{id} [get]
-func GetComponentByID(database *gormpkg.DB) http.HandlerFunc {
+func GetComponentByID(store repository.Store) http.HandlerFunc {
 	return socket.GinHandlerToMux(func(context *gin.Context) {
-		id := context.Param("id")
+		id, err := strconv.Atoi(context.Param("id"))
+		if err != nil {
+			context.JSON(http.StatusNotFound, gin.H{"error": "Component not found"})
+			return
+		}
 
-		var component models.Component
-		if err := database.First(&component, id).Error; err != nil {
+		component, err := store.Components().Get(id)
+		if err != nil {
 			context.JSON(http.StatusNotFound, gin.H{"error": "Component not found"})
 
 			return
 		}
 
+		setComponentETag(context, component)
 		context.JSON(http.StatusOK, component)
 	})
 }
@@ -224,7 +278,10 @@ func GetComponentByID(database *gormpkg.DB) http.HandlerFunc {
 // ComponentRequest represents the request payload for creating a component
 type ComponentRequest struct {
 	Component models.Component `json:"component"`
-	UserID    string           `json:"userId"`
+	// OnBehalfOf records the addition for another user; it requires the inventory.act-on-behalf permission.
+	OnBehalfOf string `json:"onBehalfOf"`
+	// UserID is the former name of OnBehalfOf.
+	UserID string `json:"userId"`
 }
 
 // CreateComponent godoc
@@ -236,7 +293,8 @@ type ComponentRequest struct {
 // @Param componentRequest body ComponentRequest true "Component Request"
 // @Success 201 {object} models.Component
 // @Router /components [post]
-func CreateComponent(database *gormpkg.DB) http.HandlerFunc {
+func CreateComponent(store repository.Store) http.HandlerFunc {
+	service := inventory.NewService(store)
 	return socket.GinHandlerToMux(func(context *gin.Context) {
 		var request ComponentRequest
 		if err := context.ShouldBindJSON(&request); err != nil {
@@ -245,49 +303,39 @@ func CreateComponent(database *gormpkg.DB) http.HandlerFunc {
 		}
 
 		component := request.Component
-		userID := request.UserID
 
-		// Ensure the status is set
-		if component.Status == "" {
-			component.Status = "Ready to Use"
+		// Resolve the user first so a missing user never leaves a component without history
+		actor, ok := requestActor(context, onBehalfOf(request.OnBehalfOf, request.UserID))
+		if !ok {
+			return
 		}
 
-		// Ensure the type_id exists
-		var componentType models.ComponentType
-		if err := database.First(&componentType, component.TypeID).Error; err != nil {
-			context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid type_id"})
-			return
+		// New components start in the initial status of the lifecycle
+		if component.Status == "" {
+			component.Status = lifecycle.Current().Initial
 		}
 
-		// Create the component
-		if err := database.Create(&component).Error; err != nil {
+		// Ensure the type_id exists
+		tree, err := typetree.Load(store.Types())
+		if err != nil {
 			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
-
-		// Create an inventory history entry
-		inventoryHistory := models.InventoryHistory{
-			ComponentID:   component.ID,
-			UserID:        userID,
-			OperationType: "Added",
-			CreatedAt:     time.Now(),
+		componentType, ok := tree.Get(component.TypeID)
+		if !ok {
+			context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid type_id"})
+			return
 		}
 
-		user, err := GetAUserByIDMid(userID)
-		if err != nil || user == nil {
-			context.JSON(http.StatusNotFound, gin.H{"error": "User not found from API."})
+		// Ensure the component matches the attribute schema of its type
+		if err := attributes.Validate(componentType, component); err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		// Add the username info for the case of user deletion
-		userData := user.(map[string]interface{})
-		userName := fmt.Sprintf("%s %s", userData["firstName"], userData["lastName"])
-
-		inventoryHistory.UserName = userName
-
-		if err := database.Create(&inventoryHistory).Error; err != nil {
-			log.Printf("Error creating inventory history: %v", err)
-			context.JSON(http.StatusInternalServerError, gin.H{"error": err})
+		// Create the component together with its inventory history entry
+		if _, err := service.AddComponent(&component, actor); err != nil {
+			inventoryErrorResponse(context, err)
 			return
 		}
 
@@ -302,15 +350,21 @@ func CreateComponent(database *gormpkg.DB) http.HandlerFunc {
 // @Accept  json
 // @Produce  json
 // @Param id path int true "Component ID"
+// @Param If-Match header string false "ETag of the component the update is based on"
 // @Param component body models.Component true "Component Item"
 // @Success 200 {object} models.Component
+// @Failure 412 {object} map[string]interface{} "The component has changed; current holds its current state"
 // @Router /components/{id} [put]
-func UpdateComponent(database *gormpkg.DB) http.HandlerFunc {
+func UpdateComponent(store repository.Store) http.HandlerFunc {
 	return socket.GinHandlerToMux(func(context *gin.Context) {
-		id := context.Param("id")
+		id, err := strconv.Atoi(context.Param("id"))
+		if err != nil {
+			context.JSON(http.StatusNotFound, gin.H{"error": "Component not found"})
+			return
+		}
 
-		var component models.Component
-		if err := database.First(&component, id).Error; err != nil {
+		component, err := store.Components().Get(id)
+		if err != nil {
 			context.JSON(http.StatusNotFound, gin.H{"error": "Component not found"})
 			return
 		}
@@ -321,138 +375,213 @@ func UpdateComponent(database *gormpkg.DB) http.HandlerFunc {
 			return
 		}
 
-		// Ensure the type_id exists
-		var componentType models.ComponentType
-		if err := database.First(&componentType, input.TypeID).Error; err != nil {
-			context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid type_id"})
+		updateComponent(context, store, component, input)
+	})
+}
+
+// PatchComponent godoc
+// @Summary Partially update a component item
+// @Description Applies a JSON Merge Patch (RFC 7396) to a component: only the fields in the patch change and a
+// @Description null field is cleared. Custom attributes are merged one by one, so {"attributes": {"color": null}}
+// @Description removes only color. The patched component is validated like a full update.
+// @Tags components
+// @Accept  application/merge-patch+json
+// @Produce  json
+// @Param id path int true "Component ID"
+// @Param If-Match header string false "ETag of the component the patch is based on"
+// @Param patch body object true "Merge patch of the component"
+// @Success 200 {object} models.Component
+// @Failure 400 {object} map[string]string "Invalid patch or patched component"
+// @Failure 409 {object} map[string]string "The patch changes the status"
+// @Failure 412 {object} map[string]interface{} "The component has changed; current holds its current state"
+// @Failure 415 {object} map[string]string "The body is not a merge patch"
+// @Router /components/{id} [patch]
+func PatchComponent(store repository.Store) http.HandlerFunc {
+	return socket.GinHandlerToMux(func(context *gin.Context) {
+		id, err := strconv.Atoi(context.Param("id"))
+		if err != nil {
+			context.JSON(http.StatusNotFound, gin.H{"error": "Component not found"})
 			return
 		}
 
-		if component.WarrantyEndDate != input.WarrantyEndDate {
-			component.EmailNotified = false
+		component, err := store.Components().Get(id)
+		if err != nil {
+			context.JSON(http.StatusNotFound, gin.H{"error": "Component not found"})
+			return
 		}
 
-		component.Status = input.Status
-		component.Brand = input.Brand
-		component.Model = input.Model
-		component.ModelYear = input.ModelYear
-		component.TypeID = input.TypeID
-		component.ScreenSize = input.ScreenSize
-		component.Resolution = input.Resolution
-		component.ProcessorType = input.ProcessorType
-		component.ProcessorCores = input.ProcessorCores
-		component.RAM = input.RAM
-		component.WarrantyEndDate = input.WarrantyEndDate
-		component.SerialNumber = input.SerialNumber
-		component.Condition = input.Condition
-		component.Notes = input.Notes
-
-		if err := database.Save(&component).Error; err != nil {
-			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		patch, ok := readMergePatch(context)
+		if !ok {
+			return
+		}
+		var input models.Component
+		if err := applyMergePatch(component, patch, &input); err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		context.JSON(http.StatusOK, component)
+		updateComponent(context, store, component, input)
 	})
 }
 
+// updateComponent replaces the editable fields of component with those of input, validates and saves it.
+// The update fails with 412 if the If-Match header or the stored version no longer match component
+func updateComponent(context *gin.Context, store repository.Store, component models.Component, input models.Component) {
+	if match := ifMatch(context); match != nil && !match(component.Version) {
+		preconditionFailed(context, store, component.ID)
+		return
+	}
+
+	// Ensure the type_id exists
+	tree, err := typetree.Load(store.Types())
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	componentType, ok := tree.Get(input.TypeID)
+	if !ok {
+		context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid type_id"})
+		return
+	}
+
+	// The status only changes through lifecycle transitions
+	if input.Status != "" && input.Status != component.Status {
+		context.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("The status cannot be changed from %q to %q by an update; use POST /components/{id}/transitions", component.Status, input.Status)})
+		return
+	}
+
+	if component.WarrantyEndDate != input.WarrantyEndDate {
+		component.EmailNotified = false
+	}
+
+	component.Brand = input.Brand
+	component.Model = input.Model
+	component.ModelYear = input.ModelYear
+	component.TypeID = input.TypeID
+	component.ScreenSize = input.ScreenSize
+	component.Resolution = input.Resolution
+	component.ProcessorType = input.ProcessorType
+	component.ProcessorCores = input.ProcessorCores
+	component.RAM = input.RAM
+	component.WarrantyEndDate = input.WarrantyEndDate
+	component.SerialNumber = input.SerialNumber
+	component.Condition = input.Condition
+	component.Notes = input.Notes
+	component.Attributes = input.Attributes
+
+	// Ensure the updated component matches the attribute schema of its type
+	if err := attributes.Validate(componentType, component); err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	actor, ok := requestActor(context, "")
+	if !ok {
+		return
+	}
+
+	// Record the changed fields together with the edit
+	if _, err := inventory.NewService(store).EditComponent(&component, actor); err != nil {
+		if errors.Is(err, repository.ErrVersionConflict) {
+			preconditionFailed(context, store, component.ID)
+			return
+		}
+		inventoryErrorResponse(context, err)
+		return
+	}
+
+	setComponentETag(context, component)
+	context.JSON(http.StatusOK, component)
+}
+
 // DeactivateComponent godoc
 // @Summary Deactivate a component item
-// @Description Mark a component item as inactive (change status to "Out of Inventory")
+// @Description Mark a component item as inactive (the "Deactivated" lifecycle operation, leading to "Out of Inventory")
 // @Tags components
 // @Accept  json
 // @Produce  json
 // @Param id path int true "Component ID"
+// @Param userID path string false "User to act on behalf of (former route)"
+// @Param If-Match header string false "ETag of the component the operation is based on"
 // @Success 204
+// @Failure 403 {object} map[string]string "userID names another user and the inventory.act-on-behalf permission is missing"
+// @Failure 412 {object} map[string]interface{} "The component has changed; current holds its current state"
+// @Router /components/{id}/deactivate [put]
 // @Router /components/{id}/deactivate/{userID} [put]
-func DeactivateComponent(database *gormpkg.DB) http.HandlerFunc {
+func DeactivateComponent(store repository.Store) http.HandlerFunc {
+	service := inventory.NewService(store)
 	return socket.GinHandlerToMux(func(context *gin.Context) {
-		id := context.Param("id")
-		userID := context.Param("userID")
-
-		var component models.Component
-		if err := database.First(&component, id).Error; err != nil {
-			context.JSON(http.StatusNotFound, gin.H{"error": "Component not found"})
+		idStr := context.Param("id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid component ID: " + idStr})
 			return
 		}
-
-		component.Status = "Out of Inventory"
-
-		if err := database.Save(&component).Error; err != nil {
-			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		// The user in the path of the former route is someone to act on behalf of
+		actor, ok := requestActor(context, context.Param("userID"))
+		if !ok {
 			return
 		}
 
-		// Create the inventory history entry
-		history := models.InventoryHistory{
-			ComponentID:   component.ID,
-			OperationType: "Deactivated",
-			UserID:        userID,     // Assumes the userID is stored in the context, adjust as needed
-			CreatedAt:     time.Now(), // Use the appropriate timestamp format
+		component, _, err := service.TransitionIf(id, ifMatch(context), lifecycle.Deactivated, actor)
+		if errors.Is(err, repository.ErrVersionConflict) {
+			preconditionFailed(context, store, id)
+			return
 		}
-
-		if err := database.Create(&history).Error; err != nil {
-			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if err != nil {
+			inventoryErrorResponse(context, err)
 			return
 		}
 
+		setComponentETag(context, component)
 		context.Status(http.StatusNoContent)
 	})
 }
 
 // ActivateComponent godoc
 // @Summary Activate a component item
-// @Description Mark a component item as active if it is inactive (change status to "Ready to Use")
+// @Description Mark a component item as active if it is inactive (the "Activated" lifecycle operation, leading to "Ready to Use")
 // @Tags components
 // @Accept  json
 // @Produce  json
 // @Param id path int true "Component ID"
+// @Param userID path string false "User to act on behalf of (former route)"
+// @Param If-Match header string false "ETag of the component the operation is based on"
 // @Success 204
+// @Failure 403 {object} map[string]string "userID names another user and the inventory.act-on-behalf permission is missing"
+// @Failure 412 {object} map[string]interface{} "The component has changed; current holds its current state"
+// @Router /components/{id}/activate [put]
 // @Router /components/{id}/activate/{userID} [put]
-func ActivateComponent(database *gormpkg.DB) http.HandlerFunc {
+func ActivateComponent(store repository.Store) http.HandlerFunc {
+	service := inventory.NewService(store)
 	return socket.GinHandlerToMux(func(context *gin.Context) {
-		id := context.Param("id")
-		userID := context.Param("userID")
-
-		var component models.Component
-		if err := database.First(&component, id).Error; err != nil {
-			context.JSON(http.StatusNotFound, gin.H{"error": "Component not found"})
+		idStr := context.Param("id")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid component ID: " + idStr})
 			return
 		}
-
-		component.Status = "Ready to Use"
-
-		if err := database.Save(&component).Error; err != nil {
-			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		// The user in the path of the former route is someone to act on behalf of
+		actor, ok := requestActor(context, context.Param("userID"))
+		if !ok {
 			return
 		}
 
-		// Create the inventory history entry
-		history := models.InventoryHistory{
-			ComponentID:   component.ID,
-			OperationType: "Activated",
-			UserID:        userID,     // Assumes the userID is stored in the context, adjust as needed
-			CreatedAt:     time.Now(), // Use the appropriate timestamp format
+		component, _, err := service.TransitionIf(id, ifMatch(context), lifecycle.Activated, actor)
+		if errors.Is(err, repository.ErrVersionConflict) {
+			preconditionFailed(context, store, id)
+			return
 		}
-
-		if err := database.Create(&history).Error; err != nil {
-			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if err != nil {
+			inventoryErrorResponse(context, err)
 			return
 		}
 
+		setComponentETag(context, component)
 		context.Status(http.StatusNoContent)
 	})
 }
 
-// GetAUserByIDMid fetches a user by ID from Microsoft Graph directly
-func GetAUserByIDMid(userID string) (interface{}, error) {
-	userData, err := GetUserByID(userID)
-	if err != nil {
-		return nil, err
-	}
-	return userData, nil
-}
-
 // GetLastInteractant godoc
 // @Summary Gets the last interactant user and the component's status
 // @Description Given a component ID, returns the last interactant user and the component's status
@@ -462,25 +591,29 @@ func GetAUserByIDMid(userID string) (interface{}, error) {
 // @Param id path int true "Component ID"
 // @Success 200 {object} map[string]interface{}
 // @Router /components/{id}/last-interactant [get]
-func GetLastInteractant(database *gormpkg.DB) http.HandlerFunc {
+func GetLastInteractant(store repository.Store) http.HandlerFunc {
 	return socket.GinHandlerToMux(func(context *gin.Context) {
-		componentID := context.Param("id")
+		componentID, err := strconv.Atoi(context.Param("id"))
+		if err != nil {
+			context.JSON(http.StatusNotFound, gin.H{"error": "Component not found"})
+			return
+		}
 
-		var component models.Component
-		if err := database.First(&component, componentID).Error; err != nil {
+		component, err := store.Components().Get(componentID)
+		if err != nil {
 			context.JSON(http.StatusNotFound, gin.H{"error": "Component not found"})
 			return
 		}
 
-		var lastHistory models.InventoryHistory
-		if err := database.Where("component_id = ?", componentID).Order("created_at desc").First(&lastHistory).Error; err != nil {
+		lastHistory, err := store.History().Latest(componentID)
+		if err != nil {
 			context.JSON(http.StatusNotFound, gin.H{"error": "No interaction found for this component"})
 			return
 		}
 
-		// Fetch the user from our API using GetUserByID
-		userID := lastHistory.UserID
-		user, err := GetAUserByIDMid(userID)
+		// Fetch the user from the directory
+		var user interface{}
+		user, err = identity.Current().User(context.Request.Context(), lastHistory.UserID)
 		if err != nil {
 			user = map[string]interface{}{
 				"id":          nil,
@@ -509,12 +642,16 @@ func GetLastInteractant(database *gormpkg.DB) http.HandlerFunc {
 // @Param id path int true "Component ID"
 // @Success 200 {array} models.InventoryHistory
 // @Router /components/{id}/inventory-history [get]
-func GetInventoryHistoryByComponentID(database *gormpkg.DB) http.HandlerFunc {
+func GetInventoryHistoryByComponentID(store repository.Store) http.HandlerFunc {
 	return socket.GinHandlerToMux(func(context *gin.Context) {
-		componentID := context.Param("id")
+		componentID, err := strconv.Atoi(context.Param("id"))
+		if err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid component ID"})
+			return
+		}
 
-		var history []models.InventoryHistory
-		if err := database.Where("component_id = ?", componentID).Find(&history).Error; err != nil {
+		history, err := store.History().ListByComponent(componentID)
+		if err != nil {
 			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -523,23 +660,83 @@ func GetInventoryHistoryByComponentID(database *gormpkg.DB) http.HandlerFunc {
 	})
 }
 
-func GetAttributeValues(database *gormpkg.DB) http.HandlerFunc {
+// GetComponentChanges godoc
+// @Summary Get the edit history of a specific component
+// @Description Lists the field changes made by edits of a component, oldest first, with the user who made
+// @Description them. The changes of one edit share its version. Assignments and other lifecycle operations
+// @Description are listed by /components/{id}/inventory-history.
+// @Tags inventory-history
+// @Produce  json
+// @Param id path int true "Component ID"
+// @Success 200 {array} models.ComponentChange
+// @Router /components/{id}/changes [get]
+func GetComponentChanges(store repository.Store) http.HandlerFunc {
+	return socket.GinHandlerToMux(func(context *gin.Context) {
+		componentID, err := strconv.Atoi(context.Param("id"))
+		if err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid component ID"})
+			return
+		}
+
+		changes, err := store.History().ListChanges(componentID)
+		if err != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if changes == nil {
+			changes = []models.ComponentChange{}
+		}
+
+		context.JSON(http.StatusOK, changes)
+	})
+}
+
+// GetAttributeValues godoc
+// @Summary Get the distinct values of an attribute
+// @Description Returns the distinct non-null values of a component field (e.g. ram, screen_size)
+// @Description or of a custom attribute declared by a component type
+// @Tags components
+// @Produce  json
+// @Param attribute path string true "Attribute name"
+// @Success 200 {array} interface{}
+// @Failure 400 {object} map[string]string "Unknown attribute"
+// @Router /components/{attribute}/uniquevalue [get]
+func GetAttributeValues(store repository.Store) http.HandlerFunc {
 	return socket.GinHandlerToMux(func(context *gin.Context) {
 		attribute := context.Param("attribute")
-		var values []interface{}
 
 		if attribute == "" {
 			context.JSON(http.StatusBadRequest, gin.H{"error": "Attribute parameter is required"})
 			return
 		}
 
-		query := database.Model(&models.Component{}).
-			Distinct(attribute).
-			Where(attribute+" IS NOT NULL").
-			Order(attribute+" ASC").
-			Pluck(attribute, &values)
+		field, isField := query.Lookup(attribute)
+		if isField && !field.Filterable {
+			context.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Attribute %q has no listable values", attribute)})
+			return
+		}
+		if !isField {
+			// Custom attributes live in the JSONB attributes column and must be declared by a type
+			tree, err := typetree.Load(store.Types())
+			if err != nil {
+				context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if !declared(tree, attribute) {
+				context.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown attribute %q", attribute)})
+				return
+			}
+			customValues, err := store.Components().DistinctAttributeValues(attribute)
+			if err != nil {
+				context.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve distinct values"})
+				return
+			}
+			context.JSON(http.StatusOK, customValues)
+			return
+		}
 
-		if err := query.Error; err != nil {
+		values, err := store.Components().DistinctColumnValues(field.Column)
+		if err != nil {
 			context.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve distinct values"})
 			return
 		}
@@ -570,3 +767,45 @@ func GetAttributeValues(database *gormpkg.DB) http.HandlerFunc {
 		}
 	})
 }
+
+// typeIDsByName returns the IDs of the named types, matched case-insensitively, and of all their sub-types.
+func typeIDsByName(tree *typetree.Tree, names []string) ([]int, error) {
+	var ids []int
+	for _, name := range names {
+		found := false
+		for _, componentType := range tree.All() {
+			if strings.EqualFold(componentType.Name, name) {
+				ids = append(ids, tree.Descendants(componentType.ID)...)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("Unknown component type %q", name)
+		}
+	}
+	return ids, nil
+}
+
+// intersect returns the IDs present in both lists, in the order of the first.
+func intersect(a []int, b []int) []int {
+	result := []int{}
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				result = append(result, x)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// declared reports whether any component type declares the named custom attribute.
+func declared(tree *typetree.Tree, name string) bool {
+	for _, componentType := range tree.All() {
+		if _, ok := componentType.Schema.Find(name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"vinventory/internal/auth"
+	"vinventory/internal/lifecycle"
+	"vinventory/internal/models"
+	"vinventory/internal/repository/memory"
+
+	"github.com/gorilla/mux"
+)
+
+// testServer routes requests to the handlers under test as the signed-in principal
+type testServer struct {
+	store     *memory.Store
+	router    *mux.Router
+	principal auth.Principal
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	server := &testServer{
+		store:     memory.New(),
+		router:    mux.NewRouter(),
+		principal: auth.DefaultPolicy().Grant(auth.Principal{ID: "manager", Name: "Ayşe Yılmaz", AppRoles: []string{"Inventory.Admin"}}),
+	}
+	server.router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(auth.NewContext(r.Context(), server.principal)))
+		})
+	})
+
+	store := server.store
+	server.router.Handle("/components", CreateComponent(store)).Methods(http.MethodPost)
+	server.router.Handle("/components/{id}", UpdateComponent(store)).Methods(http.MethodPut)
+	server.router.Handle("/components/{id}", PatchComponent(store)).Methods(http.MethodPatch)
+	server.router.Handle("/components/{id}/transitions", TransitionComponent(store)).Methods(http.MethodPost)
+	server.router.Handle("/types/{id}", UpdateComponentType(store)).Methods(http.MethodPut)
+	server.router.Handle("/types/{id}/merge", MergeComponentType(store)).Methods(http.MethodPost)
+	server.router.Handle("/suggestions", GetSuggestions(store)).Methods(http.MethodGet)
+	return server
+}
+
+// do sends a request with a JSON body; headers are given as name, value pairs
+func (s *testServer) do(method string, path string, body string, headers ...string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	for i := 0; i+1 < len(headers); i += 2 {
+		request.Header.Set(headers[i], headers[i+1])
+	}
+	recorder := httptest.NewRecorder()
+	s.router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// addType stores a component type with the given custom string attributes
+func (s *testServer) addType(t *testing.T, name string, attributeNames ...string) models.ComponentType {
+	t.Helper()
+	componentType := models.ComponentType{Name: name, Schema: models.AttributeSchema{}}
+	for _, attributeName := range attributeNames {
+		componentType.Schema = append(componentType.Schema, models.AttributeDefinition{Name: attributeName, Type: models.AttributeString})
+	}
+	if err := s.store.Types().Create(&componentType); err != nil {
+		t.Fatal(err)
+	}
+	return componentType
+}
+
+func (s *testServer) addComponent(t *testing.T, typeID int, serialNumber string, values models.AttributeValues) models.Component {
+	t.Helper()
+	component := models.Component{
+		TypeID:       typeID,
+		Brand:        "Dell",
+		Model:        "Latitude 5440",
+		SerialNumber: serialNumber,
+		Condition:    "Functioning",
+		Status:       lifecycle.ReadyToUse,
+		Attributes:   values,
+	}
+	if err := s.store.Components().Create(&component); err != nil {
+		t.Fatal(err)
+	}
+	return component
+}
+
+func decode(t *testing.T, recorder *httptest.ResponseRecorder, value interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(recorder.Body.Bytes(), value); err != nil {
+		t.Fatalf("invalid response %s: %v", recorder.Body, err)
+	}
+}
+
+// changedFields returns the recorded changes of a component by field, checking they were made by user
+func changedFields(t *testing.T, server *testServer, componentID int, user string) map[string]models.ComponentChange {
+	t.Helper()
+	changes, err := server.store.History().ListChanges(componentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := map[string]models.ComponentChange{}
+	for _, change := range changes {
+		if change.UserID != user {
+			t.Errorf("change of %s recorded for %q; want %q", change.Field, change.UserID, user)
+		}
+		fields[change.Field] = change
+	}
+	return fields
+}
+
+func value(pointer *string) string {
+	if pointer == nil {
+		return "<nil>"
+	}
+	return *pointer
+}
+
+func TestCreateComponent(t *testing.T) {
+	server := newTestServer(t)
+	laptop := server.addType(t, "Laptop", "color")
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{name: "valid", body: `{"component": {"typeId": 1, "brand": "Dell", "serialNumber": "SN-1", "condition": "Functioning", "attributes": {"color": "black"}}}`, status: http.StatusCreated},
+		{name: "unknown type", body: `{"component": {"typeId": 9, "serialNumber": "SN-2"}}`, status: http.StatusBadRequest},
+		{name: "undeclared attribute", body: `{"component": {"typeId": 1, "serialNumber": "SN-3", "attributes": {"gpu": "rtx"}}}`, status: http.StatusBadRequest},
+		{name: "not the initial status", body: `{"component": {"typeId": 1, "serialNumber": "SN-4", "status": "Being Used"}}`, status: http.StatusBadRequest},
+		{name: "invalid JSON", body: `{"component": `, status: http.StatusBadRequest},
+		{name: "on behalf of another user", body: `{"component": {"typeId": 1, "serialNumber": "SN-5"}, "onBehalfOf": "someone"}`, status: http.StatusForbidden},
+	}
+	server.principal = auth.DefaultPolicy().Grant(auth.Principal{ID: "viewer", Name: "Mehmet Demir"})
+	for _, test := range tests {
+		recorder := server.do(http.MethodPost, "/components", test.body)
+		if recorder.Code != test.status {
+			t.Errorf("%s: status = %d; want %d (%s)", test.name, recorder.Code, test.status, recorder.Body)
+		}
+	}
+
+	components, err := server.store.Components().ListByType(laptop.ID)
+	if err != nil || len(components) != 1 {
+		t.Fatalf("stored components = %v, %v; want the valid one", components, err)
+	}
+	component := components[0]
+	if component.Status != lifecycle.ReadyToUse || component.Attributes["color"] != "black" {
+		t.Errorf("stored component = %+v", component)
+	}
+
+	history, err := server.store.History().ListByComponent(component.ID)
+	if err != nil || len(history) != 1 {
+		t.Fatalf("history = %v, %v; want one entry", history, err)
+	}
+	entry := history[0]
+	if entry.OperationType != lifecycle.Added || entry.UserID != "viewer" || entry.RecordedByID != "viewer" || entry.RecordedByName != "Mehmet Demir" {
+		t.Errorf("history entry = %+v; want Added by viewer", entry)
+	}
+}
+
+func TestUpdateComponent(t *testing.T) {
+	server := newTestServer(t)
+	laptop := server.addType(t, "Laptop", "color")
+	component := server.addComponent(t, laptop.ID, "SN-1", models.AttributeValues{"color": "black"})
+
+	body := `{"typeId": 1, "brand": "HP", "model": "Latitude 5440", "serialNumber": "SN-1", "condition": "Functioning", "attributes": {"color": "silver"}}`
+	tests := []struct {
+		name    string
+		path    string
+		body    string
+		ifMatch string
+		status  int
+	}{
+		{name: "unknown component", path: "/components/9", body: body, status: http.StatusNotFound},
+		{name: "invalid ID", path: "/components/x", body: body, status: http.StatusNotFound},
+		{name: "stale version", path: "/components/1", body: body, ifMatch: `"2"`, status: http.StatusPreconditionFailed},
+		{name: "weak tag", path: "/components/1", body: body, ifMatch: `W/"1"`, status: http.StatusPreconditionFailed},
+		{name: "status change", path: "/components/1", body: `{"typeId": 1, "status": "Being Used"}`, status: http.StatusConflict},
+		{name: "undeclared attribute", path: "/components/1", body: `{"typeId": 1, "attributes": {"gpu": "rtx"}}`, status: http.StatusBadRequest},
+		{name: "unknown type", path: "/components/1", body: `{"typeId": 9}`, status: http.StatusBadRequest},
+		{name: "valid", path: "/components/1", body: body, ifMatch: `"7", "1"`, status: http.StatusOK},
+	}
+	for _, test := range tests {
+		recorder := server.do(http.MethodPut, test.path, test.body, "If-Match", test.ifMatch)
+		if recorder.Code != test.status {
+			t.Errorf("%s: status = %d; want %d (%s)", test.name, recorder.Code, test.status, recorder.Body)
+		}
+	}
+
+	stored, err := server.store.Components().Get(component.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Brand != "HP" || stored.Attributes["color"] != "silver" || stored.Version != 2 {
+		t.Errorf("stored component = %+v; want the valid update", stored)
+	}
+	fields := changedFields(t, server, component.ID, "manager")
+	if len(fields) != 2 || value(fields["brand"].NewValue) != "HP" || value(fields["color"].OldValue) != "black" {
+		t.Errorf("changes = %+v; want brand and color", fields)
+	}
+
+	// A stale update answers with the current state of the component
+	recorder := server.do(http.MethodPut, "/components/1", body, "If-Match", `"1"`)
+	var response struct {
+		Current models.Component `json:"current"`
+	}
+	decode(t, recorder, &response)
+	if recorder.Code != http.StatusPreconditionFailed || response.Current.Version != 2 || recorder.Header().Get("ETag") != `"2"` {
+		t.Errorf("stale update = %d %+v, ETag %s; want 412 with version 2", recorder.Code, response.Current, recorder.Header().Get("ETag"))
+	}
+}
+
+func TestPatchComponent(t *testing.T) {
+	server := newTestServer(t)
+	laptop := server.addType(t, "Laptop", "color", "gpu")
+	component := server.addComponent(t, laptop.ID, "SN-1", models.AttributeValues{"color": "black", "gpu": "rtx"})
+
+	recorder := server.do(http.MethodPatch, "/components/1", `{"notes": "spare"}`, "Content-Type", "text/plain")
+	if recorder.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("plain text patch status = %d; want 415", recorder.Code)
+	}
+	recorder = server.do(http.MethodPatch, "/components/9", `{"notes": "spare"}`, "Content-Type", "application/merge-patch+json")
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("patch of an unknown component status = %d; want 404", recorder.Code)
+	}
+
+	// Attributes are merged one by one and null removes a value
+	recorder = server.do(http.MethodPatch, "/components/1", `{"notes": "spare", "attributes": {"color": null}}`, "Content-Type", "application/merge-patch+json", "If-Match", `"1"`)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("patch status = %d; want 200 (%s)", recorder.Code, recorder.Body)
+	}
+	stored, err := server.store.Components().Get(component.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := stored.Attributes["color"]; ok || stored.Attributes["gpu"] != "rtx" || stored.Notes != "spare" || stored.Brand != "Dell" {
+		t.Errorf("patched component = %+v", stored)
+	}
+	if recorder.Header().Get("ETag") != `"2"` {
+		t.Errorf("ETag = %s; want \"2\"", recorder.Header().Get("ETag"))
+	}
+
+	recorder = server.do(http.MethodPatch, "/components/1", `{"notes": "broken"}`, "Content-Type", "application/merge-patch+json", "If-Match", `"1"`)
+	if recorder.Code != http.StatusPreconditionFailed {
+		t.Errorf("stale patch status = %d; want 412", recorder.Code)
+	}
+}
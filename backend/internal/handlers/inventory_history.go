@@ -1,76 +1,55 @@
 package handlers
 
 import (
-	"fmt"
 	"net/http"
+	"vinventory/internal/inventory"
+	"vinventory/internal/repository"
 	"vinventory/internal/socket"
 
-	"vinventory/internal/models"
-
 	"github.com/gin-gonic/gin"
-	gormpkg "gorm.io/gorm"
 )
 
+// InventoryHistoryRequest represents the request payload for recording an inventory operation
+type InventoryHistoryRequest struct {
+	ComponentID   int    `json:"componentId"`
+	OperationType string `json:"operationType"`
+	// OnBehalfOf records the operation for another user, such as the one a component is assigned to.
+	// It requires the inventory.act-on-behalf permission.
+	OnBehalfOf string `json:"onBehalfOf"`
+	// UserID is the former name of OnBehalfOf.
+	UserID string `json:"userId"`
+}
+
 // CreateInventoryHistory godoc
 // @Summary Create a new inventory history entry
-// @Description Create a new inventory history entry with the input payload
+// @Description Create a new inventory history entry with the input payload. The operation must be a
+// @Description lifecycle transition allowed from the component's current status (see GET /lifecycle).
+// @Description The entry is recorded for the signed-in user unless onBehalfOf names someone else.
 // @Tags inventory-history
 // @Accept json
 // @Produce json
-// @Param inventory_history body models.InventoryHistory true "Inventory History"
+// @Param inventory_history body InventoryHistoryRequest true "Inventory History"
 // @Success 201 {object} models.InventoryHistory
+// @Failure 403 {object} map[string]string "onBehalfOf names another user and the inventory.act-on-behalf permission is missing"
 // @Router /inventory-history [post]
-func CreateInventoryHistory(database *gormpkg.DB) http.HandlerFunc {
+func CreateInventoryHistory(store repository.Store) http.HandlerFunc {
+	service := inventory.NewService(store)
 	return socket.GinHandlerToMux(func(context *gin.Context) {
-		var history models.InventoryHistory
-		if err := context.ShouldBindJSON(&history); err != nil {
+		var request InventoryHistoryRequest
+		if err := context.ShouldBindJSON(&request); err != nil {
 			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		// Validate that ComponentID and UserID exist
-		var component models.Component
-		if err := database.First(&component, "id = ?", history.ComponentID).Error; err != nil {
-			context.JSON(http.StatusNotFound, gin.H{"error": "Component not found"})
-			return
-		}
-
-		// Fetch the user from our API using GetUserByID
-		userID := history.UserID
-		user, err := GetAUserByIDMid(userID)
-		if err != nil || user == nil {
-			context.JSON(http.StatusNotFound, gin.H{"error": "User not found from API."})
-			return
-		}
-
-		// Add the username info for the case of user deletion
-		userData := user.(map[string]interface{})
-		userName := fmt.Sprintf("%s %s", userData["firstName"], userData["lastName"])
-
-		history.UserName = userName
-
-		// Update the component's status based on the operation type
-		switch history.OperationType {
-		case "Assigned":
-			component.Status = "Being Used"
-		case "Returned":
-			component.Status = "Ready to Use"
-		case "Added":
-			component.Status = "Ready to Use"
-		default:
-			context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid operation type"})
-			return
-		}
-
-		// Save the updated component status
-		if err := database.Save(&component).Error; err != nil {
-			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		actor, ok := requestActor(context, onBehalfOf(request.OnBehalfOf, request.UserID))
+		if !ok {
 			return
 		}
 
-		// Create the new inventory history entry
-		if err := database.Create(&history).Error; err != nil {
-			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		// Update the component's status and record the entry in one transaction
+		_, history, err := service.Transition(request.ComponentID, request.OperationType, actor)
+		if err != nil {
+			inventoryErrorResponse(context, err)
 			return
 		}
 
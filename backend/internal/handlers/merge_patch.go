@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+
+	"vinventory/internal/mergepatch"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readMergePatch returns the body of a merge patch request, answering 415 if it is not
+// application/merge-patch+json. Plain application/json is accepted as well, as many clients cannot
+// set the media type
+func readMergePatch(context *gin.Context) ([]byte, bool) {
+	mediaType, _, err := mime.ParseMediaType(context.GetHeader("Content-Type"))
+	if err != nil || (mediaType != mergepatch.ContentType && mediaType != "application/json") {
+		context.Header("Accept-Patch", mergepatch.ContentType)
+		context.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "PATCH requests must be sent as " + mergepatch.ContentType})
+		return nil, false
+	}
+
+	patch, err := context.GetRawData()
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return patch, true
+}
+
+// applyMergePatch applies patch to the JSON form of current and decodes the result into patched
+func applyMergePatch(current interface{}, patch []byte, patched interface{}) error {
+	document, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+	document, err = mergepatch.Apply(document, patch)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(document, patched)
+}
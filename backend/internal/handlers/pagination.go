@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// defaultPageSize is used when a list request does not pass limit
+	defaultPageSize = 25
+	// maxPageSize caps limit so a single request cannot load the whole inventory
+	maxPageSize = 200
+)
+
+// PageLinks holds the URLs of the current and neighbouring pages of a list; missing pages are omitted
+type PageLinks struct {
+	Self string `json:"self"`
+	Next string `json:"next,omitempty"`
+	Prev string `json:"prev,omitempty"`
+}
+
+// parsePage reads the limit and offset query parameters
+func parsePage(context *gin.Context) (limit int, offset int, err error) {
+	limit = defaultPageSize
+	if value := context.Query("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			return 0, 0, fmt.Errorf("limit must be a number between 1 and %d", maxPageSize)
+		}
+	}
+	if value := context.Query("offset"); value != "" {
+		offset, err = strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return 0, 0, fmt.Errorf("offset must be a non-negative number")
+		}
+	}
+	return limit, offset, nil
+}
+
+// pageLinks builds the links of a page from the request URL, keeping every other query parameter
+func pageLinks(request *http.Request, limit int, offset int, total int64) PageLinks {
+	link := func(offset int) string {
+		query := request.URL.Query()
+		query.Set("limit", strconv.Itoa(limit))
+		query.Set("offset", strconv.Itoa(offset))
+		return request.URL.Path + "?" + query.Encode()
+	}
+
+	links := PageLinks{Self: link(offset)}
+	if int64(offset+limit) < total {
+		links.Next = link(offset + limit)
+	}
+	if offset > 0 {
+		links.Prev = link(max(offset-limit, 0))
+	}
+	return links
+}
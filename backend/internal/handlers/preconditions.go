@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"vinventory/internal/models"
+	"vinventory/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// componentETag returns the entity tag of a version of a component
+func componentETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+func setComponentETag(context *gin.Context, component models.Component) {
+	context.Header("ETag", componentETag(component.Version))
+}
+
+// ifMatch returns whether a component version satisfies the If-Match header of the request,
+// or nil if the request has no precondition. Entity tags are compared strongly, so weak tags never match
+func ifMatch(context *gin.Context) func(version int) bool {
+	header := strings.TrimSpace(context.GetHeader("If-Match"))
+	if header == "" || header == "*" {
+		return nil
+	}
+
+	tags := strings.Split(header, ",")
+	return func(version int) bool {
+		etag := componentETag(version)
+		for _, tag := range tags {
+			if strings.TrimSpace(tag) == etag {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// preconditionFailed answers a request whose If-Match does not match the component with its current state
+func preconditionFailed(context *gin.Context, store repository.Store, id int) {
+	component, err := store.Components().Get(id)
+	if err != nil {
+		context.JSON(http.StatusNotFound, gin.H{"error": "Component not found"})
+		return
+	}
+
+	setComponentETag(context, component)
+	context.JSON(http.StatusPreconditionFailed, gin.H{
+		"error":   "The component has been changed since it was read; review the current state and retry",
+		"current": component,
+	})
+}
@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"vinventory/internal/auth"
+	"vinventory/internal/middleware"
+	"vinventory/internal/repository"
+	"vinventory/internal/socket"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Kinds of suggestions, in the order equally good matches are listed
+const (
+	SuggestionSerialNumber = "serialNumber"
+	SuggestionModel        = "model"
+	SuggestionType         = "type"
+	SuggestionUser         = "user"
+)
+
+var suggestionKinds = []string{SuggestionSerialNumber, SuggestionModel, SuggestionType, SuggestionUser}
+
+const (
+	defaultSuggestions = 10
+	maxSuggestions     = 50
+)
+
+// Suggestion is one typeahead entry. Query is the search box term selecting what the suggestion names,
+// ID identifies the type or user it names
+type Suggestion struct {
+	Kind  string `json:"kind"`
+	Label string `json:"label"`
+	Query string `json:"query"`
+	ID    string `json:"id,omitempty"`
+}
+
+// GetSuggestions godoc
+// @Summary Get typeahead suggestions for a prefix
+// @Description Returns the best matches for a prefix among serial numbers, brand and model pairs, component
+// @Description type names and directory users, tagged by kind. Exact matches come first, then values starting
+// @Description with the prefix, then values with a later word starting with it. Users are only suggested to
+// @Description principals granted the users.list permission.
+// @Tags components
+// @Produce  json
+// @Param q query string true "Prefix to complete"
+// @Param limit query int false "Maximum number of suggestions (default 10, at most 50)"
+// @Param kinds query string false "Comma separated kinds to suggest: serialNumber, model, type, user (default all)"
+// @Success 200 {array} Suggestion
+// @Failure 400 {object} map[string]string "Missing prefix, invalid limit or unknown kind"
+// @Failure 403 {object} map[string]string "kinds names user and the users.list permission is missing"
+// @Router /suggestions [get]
+func GetSuggestions(store repository.Store) http.HandlerFunc {
+	return socket.GinHandlerToMux(func(context *gin.Context) {
+		prefix := strings.ToLower(strings.TrimSpace(context.Query("q")))
+		if prefix == "" {
+			context.JSON(http.StatusBadRequest, gin.H{"error": "q parameter is required"})
+			return
+		}
+
+		limit := defaultSuggestions
+		if value := context.Query("limit"); value != "" {
+			var err error
+			limit, err = strconv.Atoi(value)
+			if err != nil || limit < 1 || limit > maxSuggestions {
+				context.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be a number between 1 and %d", maxSuggestions)})
+				return
+			}
+		}
+
+		// Suggesting users lists the directory, which needs the same permission as listing it
+		principal, _ := auth.FromContext(context.Request.Context())
+		kinds := map[string]bool{}
+		if value := context.Query("kinds"); value != "" {
+			for _, kind := range strings.Split(value, ",") {
+				kind = strings.TrimSpace(kind)
+				if !containsKind(kind) {
+					context.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown suggestion kind %q", kind)})
+					return
+				}
+				kinds[kind] = true
+			}
+			if kinds[SuggestionUser] && !principal.Can(auth.ListUsers) {
+				middleware.Forbidden(context.Writer, auth.ListUsers)
+				return
+			}
+		} else {
+			for _, kind := range suggestionKinds {
+				kinds[kind] = kind != SuggestionUser || principal.Can(auth.ListUsers)
+			}
+		}
+
+		var suggestions []Suggestion
+
+		if kinds[SuggestionSerialNumber] {
+			serialNumbers, err := store.Components().SerialNumbers(prefix, limit)
+			if err != nil {
+				context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			for _, serialNumber := range serialNumbers {
+				suggestions = append(suggestions, Suggestion{Kind: SuggestionSerialNumber, Label: serialNumber, Query: searchTerm("serialNumber", serialNumber)})
+			}
+		}
+
+		if kinds[SuggestionModel] {
+			pairs, err := store.Components().BrandModels(prefix, limit)
+			if err != nil {
+				context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			for _, pair := range pairs {
+				terms := []string{}
+				if pair.Brand != "" {
+					terms = append(terms, searchTerm("brand", pair.Brand))
+				}
+				if pair.Model != "" {
+					terms = append(terms, searchTerm("model", pair.Model))
+				}
+				label := strings.TrimSpace(pair.Brand + " " + pair.Model)
+				suggestions = append(suggestions, Suggestion{Kind: SuggestionModel, Label: label, Query: strings.Join(terms, " ")})
+			}
+		}
+
+		if kinds[SuggestionType] {
+			types, err := store.Types().List()
+			if err != nil {
+				context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			for _, componentType := range types {
+				if matchesPrefix(componentType.Name, prefix) {
+					suggestions = append(suggestions, Suggestion{Kind: SuggestionType, Label: componentType.Name, Query: searchTerm("type", componentType.Name), ID: strconv.Itoa(componentType.ID)})
+				}
+			}
+		}
+
+		if kinds[SuggestionUser] {
+			userMap, err := fetchUsers(context)
+			if err != nil {
+				context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			var users []Suggestion
+			for _, user := range userMap {
+				if matchesPrefix(user.DisplayName, prefix) || matchesPrefix(user.Email, prefix) {
+					users = append(users, Suggestion{Kind: SuggestionUser, Label: user.DisplayName, Query: searchTerm("holder", user.DisplayName), ID: user.ID})
+				}
+			}
+			// The user map has no order of its own
+			sort.Slice(users, func(i, j int) bool { return users[i].Label < users[j].Label })
+			suggestions = append(suggestions, users...)
+		}
+
+		// Stable, so equally good matches keep the order of their kinds
+		sort.SliceStable(suggestions, func(i, j int) bool {
+			a, b := matchRank(suggestions[i].Label, prefix), matchRank(suggestions[j].Label, prefix)
+			if a != b {
+				return a < b
+			}
+			return len(suggestions[i].Label) < len(suggestions[j].Label)
+		})
+		if len(suggestions) > limit {
+			suggestions = suggestions[:limit]
+		}
+		if suggestions == nil {
+			suggestions = []Suggestion{}
+		}
+
+		context.JSON(http.StatusOK, suggestions)
+	})
+}
+
+func containsKind(kind string) bool {
+	for _, known := range suggestionKinds {
+		if known == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesPrefix reports whether text or one of its words starts with the lower-case prefix
+func matchesPrefix(text string, prefix string) bool {
+	return matchRank(text, prefix) < 3
+}
+
+// matchRank orders matches: 0 for the whole text, 1 for its start, 2 for a later word and 3 for no match
+func matchRank(text string, prefix string) int {
+	lower := strings.ToLower(text)
+	switch {
+	case lower == prefix:
+		return 0
+	case strings.HasPrefix(lower, prefix):
+		return 1
+	}
+	for _, word := range strings.Fields(lower) {
+		if strings.HasPrefix(word, prefix) {
+			return 2
+		}
+	}
+	return 3
+}
+
+// searchTerm returns the search box term requiring field to equal value, quoted when needed
+func searchTerm(field string, value string) string {
+	// The search syntax has no escapes, so a double quote cannot be part of a quoted value
+	value = strings.ReplaceAll(value, `"`, "")
+	if strings.ContainsAny(value, " \t,") {
+		value = `"` + value + `"`
+	}
+	return field + ":" + value
+}
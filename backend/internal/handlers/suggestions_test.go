@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"vinventory/internal/auth"
+	"vinventory/internal/identity"
+	"vinventory/internal/models"
+)
+
+// useDirectory makes a static directory the identity provider for the rest of the test
+func useDirectory(t *testing.T, users ...identity.DirectoryUser) {
+	t.Helper()
+	provider, err := identity.NewStatic(identity.StaticConfig{
+		Directory:  &identity.Directory{Users: users},
+		SigningKey: []byte("a signing key of at least 32 bytes"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := identity.Current()
+	identity.Use(provider)
+	t.Cleanup(func() { identity.Use(previous) })
+}
+
+func TestGetSuggestionsUsers(t *testing.T) {
+	server := newTestServer(t)
+	laptop := server.addType(t, "Laptop")
+	server.addComponent(t, laptop.ID, "AB-1", models.AttributeValues{})
+	useDirectory(t,
+		identity.DirectoryUser{ID: "u-1", FirstName: "Ayşe", LastName: "Yılmaz", Email: "ayse@example.com", DisplayName: "Ayşe Yılmaz"},
+		identity.DirectoryUser{ID: "u-2", FirstName: "Ahmet", LastName: "Demir", Email: "adem@example.com", DisplayName: "Ahmet Demir"},
+	)
+
+	viewer := auth.DefaultPolicy().Grant(auth.Principal{ID: "viewer", Name: "Mehmet Demir"})
+	manager := auth.DefaultPolicy().Grant(auth.Principal{ID: "manager", AppRoles: []string{"Inventory.Manager"}})
+	tests := []struct {
+		name      string
+		principal auth.Principal
+		query     string
+		status    int
+		kinds     []string
+	}{
+		// Viewers are suggested everything but users, which only listing the directory reveals
+		{name: "viewer, all kinds", principal: viewer, query: "q=a", status: http.StatusOK, kinds: []string{SuggestionSerialNumber}},
+		{name: "viewer, users", principal: viewer, query: "q=a&kinds=user", status: http.StatusForbidden},
+		{name: "viewer, users among other kinds", principal: viewer, query: "q=a&kinds=type,user", status: http.StatusForbidden},
+		{name: "viewer, other kinds", principal: viewer, query: "q=a&kinds=serialNumber,type", status: http.StatusOK, kinds: []string{SuggestionSerialNumber}},
+		{name: "manager, all kinds", principal: manager, query: "q=a", status: http.StatusOK, kinds: []string{SuggestionSerialNumber, SuggestionUser, SuggestionUser}},
+		{name: "manager, users", principal: manager, query: "q=a&kinds=user", status: http.StatusOK, kinds: []string{SuggestionUser, SuggestionUser}},
+	}
+	for _, test := range tests {
+		server.principal = test.principal
+		recorder := server.do(http.MethodGet, "/suggestions?"+test.query, "")
+		if recorder.Code != test.status {
+			t.Errorf("%s: status = %d; want %d (%s)", test.name, recorder.Code, test.status, recorder.Body)
+			continue
+		}
+		if test.status == http.StatusForbidden {
+			var response map[string]string
+			decode(t, recorder, &response)
+			if response["permission"] != string(auth.ListUsers) || response["error"] == "" {
+				t.Errorf("%s: response = %v; want the users.list permission named", test.name, response)
+			}
+			continue
+		}
+
+		var suggestions []Suggestion
+		decode(t, recorder, &suggestions)
+		kinds := []string{}
+		for _, suggestion := range suggestions {
+			kinds = append(kinds, suggestion.Kind)
+		}
+		if !reflect.DeepEqual(kinds, test.kinds) {
+			t.Errorf("%s: suggestion kinds = %v; want %v (%s)", test.name, kinds, test.kinds, recorder.Body)
+		}
+	}
+}
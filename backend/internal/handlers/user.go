@@ -2,12 +2,12 @@ package handlers
 
 import (
 	"net/http"
+	"vinventory/internal/auth"
+	"vinventory/internal/identity"
+	"vinventory/internal/repository"
 	"vinventory/internal/socket"
 
-	"vinventory/internal/models"
-
 	"github.com/gin-gonic/gin"
-	gormpkg "gorm.io/gorm"
 )
 
 // GetUserInventoryHistory godoc
@@ -19,20 +19,20 @@ import (
 // @Param id path int true "User ID"
 // @Success 200 {array} models.InventoryHistory
 // @Router /users/{id}/inventory-history [get]
-func GetUserInventoryHistory(database *gormpkg.DB) http.HandlerFunc {
+func GetUserInventoryHistory(store repository.Store) http.HandlerFunc {
 	return socket.GinHandlerToMux(func(context *gin.Context) {
 		id := context.Param("id")
 
 		// Validate that the user exists
-		user, err := GetAUserByIDMid(id)
-		if err != nil || user == nil {
+		_, err := identity.Current().User(context.Request.Context(), id)
+		if err != nil {
 			context.JSON(http.StatusNotFound, gin.H{"error": "User not found from API"})
 			return
 		}
 
 		// Retrieve the inventory history for the user
-		var history []models.InventoryHistory
-		if err := database.Where("user_id = ?", id).Find(&history).Error; err != nil {
+		history, err := store.History().ListByUser(id)
+		if err != nil {
 			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -40,3 +40,23 @@ func GetUserInventoryHistory(database *gormpkg.DB) http.HandlerFunc {
 		context.JSON(http.StatusOK, history)
 	})
 }
+
+// GetCurrentUser godoc
+// @Summary Get the signed-in user
+// @Description Get the signed-in user with the roles and permissions granted to them
+// @Tags users
+// @Produce  json
+// @Success 200 {object} auth.Principal
+// @Failure 401 {object} ErrorResponse
+// @Router /auth/me [get]
+func GetCurrentUser() http.HandlerFunc {
+	return socket.GinHandlerToMux(func(context *gin.Context) {
+		principal, ok := auth.FromContext(context.Request.Context())
+		if !ok {
+			context.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: no signed-in user"})
+			return
+		}
+
+		context.JSON(http.StatusOK, principal)
+	})
+}
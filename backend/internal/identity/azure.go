@@ -0,0 +1,207 @@
+package identity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"vinventory/internal/auth"
+	"vinventory/internal/models"
+)
+
+// Base URLs of the public Azure cloud.
+const (
+	DefaultLoginURL = "https://login.microsoftonline.com"
+	DefaultGraphURL = "https://graph.microsoft.com"
+)
+
+// AzureConfig configures the Azure AD and Microsoft Graph provider.
+type AzureConfig struct {
+	TenantID     string
+	ClientID     string
+	ClientSecret string
+	// LoginURL is the base URL of the Microsoft identity platform (AZURE_LOGIN_URL), and GraphURL
+	// that of Microsoft Graph (GRAPH_URL). They default to the public cloud and are changed for
+	// sovereign clouds, such as https://login.microsoftonline.us and https://graph.microsoft.us,
+	// or for a stand-in such as fakegraph.
+	LoginURL string
+	GraphURL string
+	// HTTPClient makes every request of the provider, including fetching the token signing keys.
+	HTTPClient *http.Client
+	// Token describes the tokens users sign in with; the key set and issuer default to those of the tenant.
+	Token auth.VerifierConfig
+}
+
+// Azure verifies Azure AD tokens and reads users from Microsoft Graph with the client
+// credentials of the app registration.
+type Azure struct {
+	config   AzureConfig
+	client   client
+	verifier *auth.Verifier
+
+	mu          sync.Mutex
+	accessToken string
+	expires     time.Time
+}
+
+// NewAzure returns the Azure provider; it fetches the token signing keys of the tenant.
+func NewAzure(config AzureConfig) (*Azure, error) {
+	if config.LoginURL == "" {
+		config.LoginURL = DefaultLoginURL
+	}
+	if config.GraphURL == "" {
+		config.GraphURL = DefaultGraphURL
+	}
+	config.LoginURL = strings.TrimSuffix(config.LoginURL, "/")
+	config.GraphURL = strings.TrimSuffix(config.GraphURL, "/")
+
+	tenant := url.PathEscape(config.TenantID)
+	if config.Token.JWKSURL == "" {
+		config.Token.JWKSURL = fmt.Sprintf("%s/%s/discovery/v2.0/keys", config.LoginURL, tenant)
+	}
+	if config.Token.Issuer == "" {
+		config.Token.Issuer = fmt.Sprintf("%s/%s/v2.0", config.LoginURL, config.TenantID)
+	}
+	if config.Token.TenantID == "" {
+		config.Token.TenantID = config.TenantID
+	}
+
+	client := newClient(config.HTTPClient)
+	config.Token.HTTPClient = client.http
+	verifier, err := auth.NewVerifier(config.Token)
+	if err != nil {
+		return nil, err
+	}
+	return &Azure{config: config, client: client, verifier: verifier}, nil
+}
+
+// Authenticate verifies an Azure AD token; users are identified by their object ID.
+func (a *Azure) Authenticate(token string) (auth.Principal, error) {
+	claims, err := a.verifier.Verify(token)
+	if err != nil {
+		return auth.Principal{}, err
+	}
+	principal, err := auth.FromClaims(claims, "oid")
+	if err != nil {
+		return auth.Principal{}, &auth.VerificationError{Reason: auth.ReasonNoSubject, Err: err}
+	}
+	return principal, nil
+}
+
+// graphUser is a user as returned by Microsoft Graph
+type graphUser struct {
+	ID          string `json:"id"`
+	GivenName   string `json:"givenName"`
+	Surname     string `json:"surname"`
+	Mail        string `json:"mail"`
+	DisplayName string `json:"displayName"`
+}
+
+func (u graphUser) user() models.User {
+	return models.User{ID: u.ID, FirstName: u.GivenName, LastName: u.Surname, Email: u.Mail, DisplayName: u.DisplayName}
+}
+
+// Users lists the users of the tenant, following the pages Graph splits the list into.
+func (a *Azure) Users(ctx context.Context) ([]models.User, error) {
+	users := []models.User{}
+	next := a.config.GraphURL + "/v1.0/users"
+	for next != "" {
+		var page struct {
+			Value    []graphUser `json:"value"`
+			NextLink string      `json:"@odata.nextLink"`
+		}
+		if err := a.graphJSON(ctx, next, &page); err != nil {
+			return nil, fmt.Errorf("failed to fetch users: %w", err)
+		}
+		for _, user := range page.Value {
+			users = append(users, user.user())
+		}
+		next = page.NextLink
+	}
+	return users, nil
+}
+
+// User returns a user of the tenant.
+func (a *Azure) User(ctx context.Context, id string) (models.User, error) {
+	var user graphUser
+	err := a.graphJSON(ctx, a.config.GraphURL+"/v1.0/users/"+url.PathEscape(id), &user)
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+		return models.User{}, ErrUserNotFound
+	}
+	if err != nil {
+		return models.User{}, err
+	}
+	return user.user(), nil
+}
+
+// Photo returns the profile photo of a user, or nil if they have none.
+func (a *Azure) Photo(ctx context.Context, id string) ([]byte, string, error) {
+	token, err := a.token(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := a.client.get(ctx, a.config.GraphURL+"/v1.0/users/"+url.PathEscape(id)+"/photo/$value", token)
+	if err != nil {
+		return nil, "", err
+	}
+	defer closeBody(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", nil // Users without a photo get a 404
+	}
+	photo, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read response body: %w", err)
+	}
+	return photo, resp.Header.Get("Content-Type"), nil
+}
+
+// Close stops refreshing the token signing keys.
+func (a *Azure) Close() {
+	a.verifier.Close()
+}
+
+// graphJSON decodes the response of an authorized Graph request into v
+func (a *Azure) graphJSON(ctx context.Context, requestURL string, v interface{}) error {
+	token, err := a.token(ctx)
+	if err != nil {
+		return err
+	}
+	return a.client.getJSON(ctx, requestURL, token, v)
+}
+
+// token returns an app-only Graph access token, reusing it until shortly before it expires
+func (a *Azure) token(ctx context.Context) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.accessToken != "" && time.Now().Before(a.expires) {
+		return a.accessToken, nil
+	}
+
+	form := url.Values{}
+	form.Set("client_id", a.config.ClientID)
+	form.Set("scope", a.config.GraphURL+"/.default")
+	form.Set("client_secret", a.config.ClientSecret)
+	form.Set("grant_type", "client_credentials")
+
+	var response struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	tokenURL := fmt.Sprintf("%s/%s/oauth2/v2.0/token", a.config.LoginURL, url.PathEscape(a.config.TenantID))
+	if err := a.client.postForm(ctx, tokenURL, form, &response); err != nil {
+		return "", fmt.Errorf("failed to get a Graph access token: %w", err)
+	}
+
+	a.accessToken = response.AccessToken
+	// Renew a minute early so requests in flight never carry an expired token
+	a.expires = time.Now().Add(time.Duration(response.ExpiresIn)*time.Second - time.Minute)
+	return a.accessToken, nil
+}
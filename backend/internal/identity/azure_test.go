@@ -0,0 +1,186 @@
+package identity_test
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"vinventory/internal/auth"
+	"vinventory/internal/identity"
+	"vinventory/internal/identity/fakegraph"
+	"vinventory/internal/models"
+)
+
+var directory = &identity.Directory{Users: []identity.DirectoryUser{
+	{ID: "u-1", FirstName: "Ayşe", LastName: "Yılmaz", Email: "ayse@example.com", DisplayName: "Ayşe Yılmaz", Roles: []string{"Inventory.Admin"}, Groups: []string{"g-1"}},
+	{ID: "u-2", FirstName: "Mehmet", LastName: "Demir", Email: "mehmet@example.com", DisplayName: "Mehmet Demir"},
+	{ID: "u-3", FirstName: "Elif", LastName: "Kaya", Email: "elif@example.com", DisplayName: "Elif Kaya"},
+}}
+
+var photo = []byte{0xff, 0xd8, 0xff, 0xe0, 'J', 'F', 'I', 'F'}
+
+func fakeConfig() fakegraph.Config {
+	return fakegraph.Config{
+		TenantID:     "tenant",
+		ClientID:     "client",
+		ClientSecret: "secret",
+		Directory:    directory,
+		Photos:       map[string][]byte{"u-1": photo},
+		PageSize:     2,
+	}
+}
+
+// newAzure returns the Azure provider pointed at a fake served by server
+func newAzure(t *testing.T, server *httptest.Server, clientSecret string) *identity.Azure {
+	t.Helper()
+	return newAzureWithToken(t, server, clientSecret, auth.VerifierConfig{Audiences: []string{"client"}})
+}
+
+func newAzureWithToken(t *testing.T, server *httptest.Server, clientSecret string, token auth.VerifierConfig) *identity.Azure {
+	t.Helper()
+	azure, err := identity.NewAzure(identity.AzureConfig{
+		TenantID:     "tenant",
+		ClientID:     "client",
+		ClientSecret: clientSecret,
+		LoginURL:     server.URL,
+		GraphURL:     server.URL + "/",
+		HTTPClient:   server.Client(),
+		Token:        token,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(azure.Close)
+	return azure
+}
+
+func newFake(t *testing.T, config fakegraph.Config) *fakegraph.Server {
+	t.Helper()
+	fake, err := fakegraph.New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fake
+}
+
+func TestAzureAuthenticate(t *testing.T) {
+	fake := newFake(t, fakeConfig())
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	azure := newAzure(t, server, "secret")
+
+	token, err := fake.IDToken(server.URL, "ayse@example.com", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	principal, err := azure.Authenticate(token)
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	want := auth.Principal{ID: "u-1", Name: "Ayşe Yılmaz", Email: "ayse@example.com", AppRoles: []string{"Inventory.Admin"}, Groups: []string{"g-1"}}
+	if !reflect.DeepEqual(principal, want) {
+		t.Errorf("Authenticate() = %+v; want %+v", principal, want)
+	}
+
+	// Tokens that are expired or signed by another key
+	for _, test := range []struct {
+		name   string
+		fake   *fakegraph.Server
+		ttl    time.Duration
+		reason string
+	}{
+		{name: "expired", fake: fake, ttl: -time.Hour, reason: auth.ReasonExpired},
+		{name: "other key", fake: newFake(t, fakeConfig()), ttl: time.Hour, reason: auth.ReasonSignature},
+	} {
+		token, err := test.fake.IDToken(server.URL, "u-2", test.ttl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = azure.Authenticate(token)
+		var verificationErr *auth.VerificationError
+		if !errors.As(err, &verificationErr) || verificationErr.Reason != test.reason {
+			t.Errorf("%s: Authenticate() error = %v; want reason %q", test.name, err, test.reason)
+		}
+	}
+
+	// Tokens of the fake, checked by providers that expect another application, issuer or tenant
+	for _, test := range []struct {
+		name   string
+		token  auth.VerifierConfig
+		reason string
+	}{
+		{name: "other application", token: auth.VerifierConfig{Audiences: []string{"api://other", "other"}}, reason: auth.ReasonAudience},
+		{name: "other issuer", token: auth.VerifierConfig{Audiences: []string{"client"}, Issuer: server.URL + "/other/v2.0"}, reason: auth.ReasonIssuer},
+		{name: "other tenant", token: auth.VerifierConfig{Audiences: []string{"client"}, TenantID: "other"}, reason: auth.ReasonTenant},
+		{name: "one of several audiences", token: auth.VerifierConfig{Audiences: []string{"api://vinventory", "client"}}},
+	} {
+		_, err := newAzureWithToken(t, server, "secret", test.token).Authenticate(token)
+		if test.reason == "" {
+			if err != nil {
+				t.Errorf("%s: Authenticate() error = %v", test.name, err)
+			}
+			continue
+		}
+		var verificationErr *auth.VerificationError
+		if !errors.As(err, &verificationErr) || verificationErr.Reason != test.reason {
+			t.Errorf("%s: Authenticate() error = %v; want reason %q", test.name, err, test.reason)
+		}
+	}
+	if _, err := azure.Authenticate("not a token"); err == nil {
+		t.Error("Authenticate() of a malformed token succeeded")
+	}
+}
+
+func TestAzureDirectory(t *testing.T) {
+	server := httptest.NewServer(newFake(t, fakeConfig()))
+	defer server.Close()
+	azure := newAzure(t, server, "secret")
+	ctx := context.Background()
+
+	// Three users come in two pages
+	users, err := azure.Users(ctx)
+	if err != nil {
+		t.Fatalf("Users() error = %v", err)
+	}
+	var ids []string
+	for _, user := range users {
+		ids = append(ids, user.ID)
+	}
+	if !reflect.DeepEqual(ids, []string{"u-1", "u-2", "u-3"}) {
+		t.Errorf("Users() = %v; want u-1, u-2 and u-3", ids)
+	}
+
+	user, err := azure.User(ctx, "u-2")
+	want := models.User{ID: "u-2", FirstName: "Mehmet", LastName: "Demir", Email: "mehmet@example.com", DisplayName: "Mehmet Demir"}
+	if err != nil || user != want {
+		t.Errorf("User(u-2) = %+v, %v; want %+v", user, err, want)
+	}
+	if _, err := azure.User(ctx, "u-9"); !errors.Is(err, identity.ErrUserNotFound) {
+		t.Errorf("User(u-9) error = %v; want ErrUserNotFound", err)
+	}
+
+	data, contentType, err := azure.Photo(ctx, "u-1")
+	if err != nil || !reflect.DeepEqual(data, photo) || contentType != "image/jpeg" {
+		t.Errorf("Photo(u-1) = %v, %q, %v; want the JPEG photo", data, contentType, err)
+	}
+	data, _, err = azure.Photo(ctx, "u-2")
+	if err != nil || data != nil {
+		t.Errorf("Photo(u-2) = %v, %v; want no photo", data, err)
+	}
+}
+
+func TestAzureClientCredentials(t *testing.T) {
+	server := httptest.NewServer(newFake(t, fakeConfig()))
+	defer server.Close()
+	azure := newAzure(t, server, "wrong")
+
+	if _, err := azure.Users(context.Background()); err == nil {
+		t.Error("Users() with a wrong client secret succeeded")
+	}
+	if _, err := azure.User(context.Background(), "u-1"); err == nil || errors.Is(err, identity.ErrUserNotFound) {
+		t.Errorf("User() with a wrong client secret error = %v; want a token error", err)
+	}
+}
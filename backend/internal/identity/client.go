@@ -0,0 +1,102 @@
+package identity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// client makes every HTTP request of the identity providers, so tests and demos can point
+// them at a stand-in such as fakegraph by changing base URLs and the http.Client alone.
+type client struct {
+	http *http.Client
+}
+
+// StatusError is returned for responses with an unexpected status.
+type StatusError struct {
+	URL        string
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s responded %d: %s", e.URL, e.StatusCode, e.Body)
+}
+
+// newClient wraps httpClient, or a client with a 30 second timeout if it is nil.
+func newClient(httpClient *http.Client) client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	return client{http: httpClient}
+}
+
+// get makes a GET request, sending token as a bearer token if set; the caller closes the body
+func (c client) get(ctx context.Context, requestURL string, token string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if token != "" {
+		req.Header.Add("Authorization", "Bearer "+token)
+	}
+	return c.do(req)
+}
+
+// getJSON decodes the response of a GET request into v
+func (c client) getJSON(ctx context.Context, requestURL string, token string, v interface{}) error {
+	resp, err := c.get(ctx, requestURL, token)
+	if err != nil {
+		return err
+	}
+	return decode(resp, v)
+}
+
+// postForm posts a form and decodes the response into v
+func (c client) postForm(ctx context.Context, requestURL string, form url.Values, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, requestURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	return decode(resp, v)
+}
+
+func (c client) do(req *http.Request) (*http.Response, error) {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	return resp, nil
+}
+
+// decode closes the body of a response after decoding it into v, or returns a *StatusError
+func decode(resp *http.Response, v interface{}) error {
+	defer closeBody(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return &StatusError{URL: resp.Request.URL.String(), StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode response body: %w", err)
+	}
+	return nil
+}
+
+func closeBody(body io.ReadCloser) {
+	if err := body.Close(); err != nil {
+		log.Printf("failed to close response body: %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package identity
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"vinventory/internal/models"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Directory is a list of users kept in a YAML or JSON file (DIRECTORY_FILE):
+//
+//	users:
+//	  - id: 3f0c1a52-ayse
+//	    firstName: Ayşe
+//	    lastName: Yılmaz
+//	    email: ayse@example.com
+//	    roles: [Inventory.Admin]
+//	    groups: []
+//
+// Roles and groups take the place of the roles and groups claims of the static provider's
+// tokens and are mapped to permissions by the RBAC policy like any other.
+type Directory struct {
+	Users []DirectoryUser `json:"users" yaml:"users"`
+}
+
+// DirectoryUser is a user of a directory file; DisplayName defaults to the first and last name.
+type DirectoryUser struct {
+	ID          string   `json:"id" yaml:"id"`
+	FirstName   string   `json:"firstName" yaml:"firstName"`
+	LastName    string   `json:"lastName" yaml:"lastName"`
+	Email       string   `json:"email" yaml:"email"`
+	DisplayName string   `json:"displayName" yaml:"displayName"`
+	Roles       []string `json:"roles" yaml:"roles"`
+	Groups      []string `json:"groups" yaml:"groups"`
+}
+
+// LoadDirectoryFile reads a directory from a .yaml, .yml or .json file.
+func LoadDirectoryFile(path string) (*Directory, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory file: %w", err)
+	}
+
+	var directory Directory
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &directory)
+	default:
+		err = json.Unmarshal(data, &directory)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse directory file: %w", err)
+	}
+
+	ids := map[string]bool{}
+	for i, user := range directory.Users {
+		if user.ID == "" {
+			return nil, fmt.Errorf("user %d of the directory file has no id", i+1)
+		}
+		if ids[user.ID] {
+			return nil, fmt.Errorf("user %q is defined more than once in the directory file", user.ID)
+		}
+		ids[user.ID] = true
+		if user.DisplayName == "" {
+			directory.Users[i].DisplayName = strings.TrimSpace(user.FirstName + " " + user.LastName)
+		}
+	}
+
+	return &directory, nil
+}
+
+// Find returns the user with an ID or e-mail address.
+func (d *Directory) Find(idOrEmail string) (DirectoryUser, bool) {
+	for _, user := range d.Users {
+		if user.ID == idOrEmail || (user.Email != "" && strings.EqualFold(user.Email, idOrEmail)) {
+			return user, true
+		}
+	}
+	return DirectoryUser{}, false
+}
+
+func (d *Directory) list() []models.User {
+	users := make([]models.User, 0, len(d.Users))
+	for _, user := range d.Users {
+		users = append(users, user.user())
+	}
+	return users
+}
+
+func (u DirectoryUser) user() models.User {
+	return models.User{ID: u.ID, FirstName: u.FirstName, LastName: u.LastName, Email: u.Email, DisplayName: u.DisplayName}
+}
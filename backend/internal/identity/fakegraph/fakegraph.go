@@ -0,0 +1,306 @@
+// Package fakegraph is a stand-in for the Microsoft identity platform and Microsoft Graph, for
+// integration tests and demos that must not reach Microsoft. Point the azure identity provider
+// at it by setting both AZURE_LOGIN_URL and GRAPH_URL to its URL.
+//
+// It serves the parts vinventory uses:
+//
+//	GET  /{tenant}/discovery/v2.0/keys                     token signing keys
+//	GET  /{tenant}/v2.0/.well-known/openid-configuration   discovery document
+//	POST /{tenant}/oauth2/v2.0/token                       client credentials grant
+//	GET  /v1.0/users                                       users, in pages of PageSize
+//	GET  /v1.0/users/{id}                                  a user
+//	GET  /v1.0/users/{id}/photo/$value                     a user's photo
+//
+// and, in place of an interactive sign-in,
+//
+//	POST /{tenant}/fakegraph/id-token?user={id or e-mail}  an ID token for a user
+//
+// ID tokens look like those of Azure AD: they are signed with RS256 and carry the aud, iss,
+// tid, oid, name, preferred_username, roles and groups claims.
+package fakegraph
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"vinventory/internal/identity"
+
+	JWT "github.com/golang-jwt/jwt/v4"
+	"github.com/gorilla/mux"
+)
+
+const keyID = "fakegraph"
+
+// Config configures a fake server.
+type Config struct {
+	// URL is the base URL the server is reached at; it is part of the issuer of its tokens.
+	// If empty it is taken from the requests the server receives.
+	URL          string
+	TenantID     string
+	ClientID     string
+	ClientSecret string
+	// Directory holds the users; their roles and groups are put in their ID tokens.
+	Directory *identity.Directory
+	// Photos maps user IDs to JPEG photos.
+	Photos map[string][]byte
+	// PageSize is the number of users per page of GET /v1.0/users, 100 by default like Graph.
+	PageSize int
+}
+
+// Server is a fake Microsoft identity platform and Graph; it is an http.Handler.
+type Server struct {
+	config Config
+	key    *rsa.PrivateKey
+	router *mux.Router
+
+	mu           sync.Mutex
+	accessTokens map[string]time.Time
+}
+
+// New returns a fake server with a freshly generated signing key.
+func New(config Config) (*Server, error) {
+	if config.TenantID == "" || config.ClientID == "" {
+		return nil, errors.New("fakegraph needs a tenant ID and a client ID")
+	}
+	if config.Directory == nil {
+		config.Directory = &identity.Directory{}
+	}
+	if config.PageSize == 0 {
+		config.PageSize = 100
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate the signing key: %w", err)
+	}
+
+	s := &Server{config: config, key: key, router: mux.NewRouter(), accessTokens: map[string]time.Time{}}
+	s.router.HandleFunc("/{tenant}/discovery/v2.0/keys", s.tenant(s.keys)).Methods(http.MethodGet)
+	s.router.HandleFunc("/{tenant}/v2.0/.well-known/openid-configuration", s.tenant(s.discovery)).Methods(http.MethodGet)
+	s.router.HandleFunc("/{tenant}/oauth2/v2.0/token", s.tenant(s.token)).Methods(http.MethodPost)
+	s.router.HandleFunc("/{tenant}/fakegraph/id-token", s.tenant(s.idToken)).Methods(http.MethodPost)
+	s.router.HandleFunc("/v1.0/users", s.authorized(s.users)).Methods(http.MethodGet)
+	s.router.HandleFunc("/v1.0/users/{id}", s.authorized(s.user)).Methods(http.MethodGet)
+	s.router.HandleFunc("/v1.0/users/{id}/photo/$value", s.authorized(s.photo)).Methods(http.MethodGet)
+	return s, nil
+}
+
+// ServeHTTP serves the fake endpoints.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.router.ServeHTTP(w, r)
+}
+
+// IDToken returns an ID token for the user with an ID or e-mail address, valid for ttl, issued
+// by the server reached at baseURL.
+func (s *Server) IDToken(baseURL string, idOrEmail string, ttl time.Duration) (string, error) {
+	user, ok := s.config.Directory.Find(idOrEmail)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", identity.ErrUserNotFound, idOrEmail)
+	}
+
+	now := time.Now()
+	token := JWT.NewWithClaims(JWT.SigningMethodRS256, JWT.MapClaims{
+		"aud":                s.config.ClientID,
+		"iss":                s.issuer(baseURL),
+		"tid":                s.config.TenantID,
+		"oid":                user.ID,
+		"sub":                user.ID,
+		"name":               user.DisplayName,
+		"preferred_username": user.Email,
+		"roles":              nonNil(user.Roles),
+		"groups":             nonNil(user.Groups),
+		"iat":                now.Unix(),
+		"nbf":                now.Unix(),
+		"exp":                now.Add(ttl).Unix(),
+	})
+	token.Header["kid"] = keyID
+	return token.SignedString(s.key)
+}
+
+func (s *Server) issuer(baseURL string) string {
+	return fmt.Sprintf("%s/%s/v2.0", baseURL, s.config.TenantID)
+}
+
+// baseURL returns the configured URL of the server, or the one a request was sent to
+func (s *Server) baseURL(r *http.Request) string {
+	if s.config.URL != "" {
+		return s.config.URL
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// tenant rejects requests for other tenants, as Azure AD does for unknown ones
+func (s *Server) tenant(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if mux.Vars(r)["tenant"] != s.config.TenantID {
+			writeJSON(w, http.StatusBadRequest, map[string]string{
+				"error":             "invalid_tenant",
+				"error_description": fmt.Sprintf("Tenant '%s' not found.", mux.Vars(r)["tenant"]),
+			})
+			return
+		}
+		next(w, r)
+	}
+}
+
+// authorized rejects Graph requests without an access token issued by the token endpoint
+func (s *Server) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get("Authorization")
+		s.mu.Lock()
+		expires, ok := s.accessTokens[token]
+		s.mu.Unlock()
+		if !ok || time.Now().After(expires) {
+			graphError(w, http.StatusUnauthorized, "InvalidAuthenticationToken", "Access token is empty or invalid.")
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (s *Server) keys(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": keyID,
+			"n":   base64.RawURLEncoding.EncodeToString(s.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(s.key.E)).Bytes()),
+		}},
+	})
+}
+
+func (s *Server) discovery(w http.ResponseWriter, r *http.Request) {
+	baseURL := s.baseURL(r)
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":         s.issuer(baseURL),
+		"jwks_uri":       fmt.Sprintf("%s/%s/discovery/v2.0/keys", baseURL, s.config.TenantID),
+		"token_endpoint": fmt.Sprintf("%s/%s/oauth2/v2.0/token", baseURL, s.config.TenantID),
+	})
+}
+
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	if r.PostFormValue("grant_type") != "client_credentials" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+	if r.PostFormValue("client_id") != s.config.ClientID || r.PostFormValue("client_secret") != s.config.ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	accessToken := hex.EncodeToString(random)
+	s.mu.Lock()
+	s.accessTokens["Bearer "+accessToken] = time.Now().Add(time.Hour)
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"access_token": accessToken,
+	})
+}
+
+func (s *Server) idToken(w http.ResponseWriter, r *http.Request) {
+	token, err := s.IDToken(s.baseURL(r), r.URL.Query().Get("user"), time.Hour)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"id_token": token})
+}
+
+// graphUser renders a user the way Graph does
+func graphUser(user identity.DirectoryUser) map[string]interface{} {
+	return map[string]interface{}{
+		"id":                user.ID,
+		"givenName":         user.FirstName,
+		"surname":           user.LastName,
+		"mail":              user.Email,
+		"displayName":       user.DisplayName,
+		"userPrincipalName": user.Email,
+	}
+}
+
+func (s *Server) users(w http.ResponseWriter, r *http.Request) {
+	skip, _ := strconv.Atoi(r.URL.Query().Get("$skiptoken"))
+	if skip < 0 || skip > len(s.config.Directory.Users) {
+		skip = 0
+	}
+	end := skip + s.config.PageSize
+	if end > len(s.config.Directory.Users) {
+		end = len(s.config.Directory.Users)
+	}
+
+	value := []map[string]interface{}{}
+	for _, user := range s.config.Directory.Users[skip:end] {
+		value = append(value, graphUser(user))
+	}
+	page := map[string]interface{}{"value": value}
+	if end < len(s.config.Directory.Users) {
+		page["@odata.nextLink"] = fmt.Sprintf("%s/v1.0/users?$skiptoken=%d", s.baseURL(r), end)
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+func (s *Server) user(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	user, ok := s.config.Directory.Find(id)
+	if !ok {
+		graphError(w, http.StatusNotFound, "Request_ResourceNotFound", fmt.Sprintf("Resource '%s' does not exist or one of its queried reference-property objects are not present.", id))
+		return
+	}
+	writeJSON(w, http.StatusOK, graphUser(user))
+}
+
+func (s *Server) photo(w http.ResponseWriter, r *http.Request) {
+	photo, ok := s.config.Photos[mux.Vars(r)["id"]]
+	if !ok {
+		graphError(w, http.StatusNotFound, "ImageNotFound", "Exception of type 'Microsoft.Fast.Profile.Core.Exception.ImageNotFoundException' was thrown.")
+		return
+	}
+	w.Header().Set("Content-Type", "image/jpeg")
+	if _, err := w.Write(photo); err != nil {
+		log.Printf("failed to write photo: %v", err)
+	}
+}
+
+func graphError(w http.ResponseWriter, status int, code string, message string) {
+	writeJSON(w, status, map[string]interface{}{"error": map[string]string{"code": code, "message": message}})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("failed to write response: %v", err)
+	}
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
@@ -0,0 +1,92 @@
+// Package identity connects vinventory to the directory of the organisation using it: the
+// provider verifies the tokens users sign in with and looks users up for the inventory history,
+// the holder search and the user pickers of the frontend.
+//
+// IDENTITY_PROVIDER selects one of three providers:
+//
+//   - azure (default) verifies Azure AD tokens and reads users from Microsoft Graph;
+//   - oidc verifies the tokens of any OpenID Connect issuer and reads users from a directory
+//     file or a JSON users endpoint, since OIDC itself has no way to list users;
+//   - static reads users from a YAML or JSON directory file and verifies tokens signed locally
+//     with "vinventory token", for offline development and small setups.
+package identity
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"vinventory/internal/auth"
+	"vinventory/internal/models"
+)
+
+// ErrUserNotFound is returned for users that are not in the directory.
+var ErrUserNotFound = errors.New("user not found")
+
+// ErrNotConfigured is returned by every method of the provider in use before one is set with Use.
+var ErrNotConfigured = errors.New("no identity provider is configured")
+
+// Provider is a directory of users together with the issuer of the tokens they sign in with.
+type Provider interface {
+	// Authenticate verifies a token and returns the principal it names, before any roles are
+	// granted. Errors are *auth.VerificationError.
+	Authenticate(token string) (auth.Principal, error)
+	// Users lists the users of the directory.
+	Users(ctx context.Context) ([]models.User, error)
+	// User returns a user of the directory, or ErrUserNotFound.
+	User(ctx context.Context, id string) (models.User, error)
+	// Photo returns the profile photo of a user and its content type, or nil if they have none.
+	Photo(ctx context.Context, id string) ([]byte, string, error)
+	// Close releases the resources of the provider, such as background key refreshes.
+	Close()
+}
+
+// findUser returns the user with id from a listed directory.
+func findUser(users []models.User, id string) (models.User, error) {
+	for _, user := range users {
+		if user.ID == id {
+			return user, nil
+		}
+	}
+	return models.User{}, ErrUserNotFound
+}
+
+// unconfigured is the provider in use until Use is called.
+type unconfigured struct{}
+
+func (unconfigured) Authenticate(string) (auth.Principal, error) {
+	return auth.Principal{}, &auth.VerificationError{Reason: auth.ReasonNoProvider, Err: ErrNotConfigured}
+}
+
+func (unconfigured) Users(context.Context) ([]models.User, error) {
+	return nil, ErrNotConfigured
+}
+
+func (unconfigured) User(context.Context, string) (models.User, error) {
+	return models.User{}, ErrNotConfigured
+}
+
+func (unconfigured) Photo(context.Context, string) ([]byte, string, error) {
+	return nil, "", ErrNotConfigured
+}
+
+func (unconfigured) Close() {}
+
+var (
+	mu      sync.RWMutex
+	current Provider = unconfigured{}
+)
+
+// Current returns the provider in use.
+func Current() Provider {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// Use replaces the provider in use; it is set once at startup.
+func Use(provider Provider) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = provider
+}
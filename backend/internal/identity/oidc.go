@@ -0,0 +1,145 @@
+package identity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"vinventory/internal/auth"
+	"vinventory/internal/models"
+)
+
+// OIDCConfig configures the generic OpenID Connect provider.
+type OIDCConfig struct {
+	// Issuer is the issuer URL (OIDC_ISSUER); its discovery document names the token signing keys.
+	Issuer string
+	// ClientID is the client the frontend signs in with (OIDC_CLIENT_ID), the default audience of tokens.
+	ClientID string
+	// IDClaim is the claim identifying users (OIDC_USER_ID_CLAIM), sub by default.
+	IDClaim string
+	// Token describes the tokens users sign in with; the key set, issuer and audience default to
+	// those of the discovery document and ClientID.
+	Token auth.VerifierConfig
+	// Directory is the user-info source when users are kept in a file (DIRECTORY_FILE).
+	Directory *Directory
+	// UsersURL is the user-info source otherwise (OIDC_USERS_URL): an endpoint answering GET
+	// with a JSON array of users shaped like the GET /auth/users/{id} response.
+	UsersURL string
+	// UsersToken is sent as a bearer token to UsersURL (OIDC_USERS_TOKEN), if set.
+	UsersToken string
+	// HTTPClient makes every request of the provider, including fetching the token signing keys.
+	HTTPClient *http.Client
+}
+
+// OIDC verifies the tokens of any OpenID Connect issuer, such as Keycloak, Authentik or Google,
+// and reads users from a directory file or a users endpoint. The roles and groups claims are
+// mapped to permissions by the RBAC policy; issuers that nest roles elsewhere need a claim mapper.
+type OIDC struct {
+	config   OIDCConfig
+	client   client
+	verifier *auth.Verifier
+}
+
+// NewOIDC returns the OIDC provider; it reads the discovery document of the issuer and fetches its signing keys.
+func NewOIDC(config OIDCConfig) (*OIDC, error) {
+	if config.Issuer == "" {
+		return nil, errors.New("the oidc identity provider needs an issuer (OIDC_ISSUER)")
+	}
+	if config.Directory == nil && config.UsersURL == "" {
+		return nil, errors.New("the oidc identity provider needs a user-info source (DIRECTORY_FILE or OIDC_USERS_URL)")
+	}
+	if config.IDClaim == "" {
+		config.IDClaim = "sub"
+	}
+
+	client := newClient(config.HTTPClient)
+	discovery, err := discover(client, config.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	if config.Token.JWKSURL == "" {
+		config.Token.JWKSURL = discovery.JWKSURI
+	}
+	if config.Token.Issuer == "" {
+		config.Token.Issuer = discovery.Issuer
+	}
+	if len(config.Token.Audiences) == 0 && config.ClientID != "" {
+		config.Token.Audiences = []string{config.ClientID}
+	}
+
+	config.Token.HTTPClient = client.http
+	verifier, err := auth.NewVerifier(config.Token)
+	if err != nil {
+		return nil, err
+	}
+	return &OIDC{config: config, client: client, verifier: verifier}, nil
+}
+
+// discovery is the part of an OpenID Connect discovery document the provider needs
+type discovery struct {
+	Issuer  string `json:"issuer"`
+	JWKSURI string `json:"jwks_uri"`
+}
+
+func discover(client client, issuer string) (discovery, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var document discovery
+	discoveryURL := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+	if err := client.getJSON(ctx, discoveryURL, "", &document); err != nil {
+		return discovery{}, fmt.Errorf("failed to read the OpenID Connect discovery document: %w", err)
+	}
+	if document.JWKSURI == "" {
+		return discovery{}, fmt.Errorf("the discovery document of %s has no jwks_uri", issuer)
+	}
+	return document, nil
+}
+
+// Authenticate verifies a token of the issuer; users are identified by the configured ID claim.
+func (o *OIDC) Authenticate(token string) (auth.Principal, error) {
+	claims, err := o.verifier.Verify(token)
+	if err != nil {
+		return auth.Principal{}, err
+	}
+	principal, err := auth.FromClaims(claims, o.config.IDClaim)
+	if err != nil {
+		return auth.Principal{}, &auth.VerificationError{Reason: auth.ReasonNoSubject, Err: err}
+	}
+	return principal, nil
+}
+
+// Users lists the users of the user-info source.
+func (o *OIDC) Users(ctx context.Context) ([]models.User, error) {
+	if o.config.Directory != nil {
+		return o.config.Directory.list(), nil
+	}
+
+	var users []models.User
+	if err := o.client.getJSON(ctx, o.config.UsersURL, o.config.UsersToken, &users); err != nil {
+		return nil, fmt.Errorf("failed to fetch users: %w", err)
+	}
+	return users, nil
+}
+
+// User returns a user of the user-info source.
+func (o *OIDC) User(ctx context.Context, id string) (models.User, error) {
+	users, err := o.Users(ctx)
+	if err != nil {
+		return models.User{}, err
+	}
+	return findUser(users, id)
+}
+
+// Photo returns no photo; OpenID Connect has no standard way to fetch one.
+func (o *OIDC) Photo(context.Context, string) ([]byte, string, error) {
+	return nil, "", nil
+}
+
+// Close stops refreshing the token signing keys.
+func (o *OIDC) Close() {
+	o.verifier.Close()
+}
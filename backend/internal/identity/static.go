@@ -0,0 +1,119 @@
+package identity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"vinventory/internal/auth"
+	"vinventory/internal/models"
+
+	JWT "github.com/golang-jwt/jwt/v4"
+)
+
+// Issuer and audience of the tokens signed by the static provider.
+const (
+	StaticIssuer   = "vinventory"
+	StaticAudience = "vinventory"
+)
+
+// StaticConfig configures the static provider.
+type StaticConfig struct {
+	Directory *Directory
+	// SigningKey is the HS256 secret tokens are signed with (STATIC_SIGNING_KEY), at least 32 bytes long.
+	SigningKey []byte
+	// Leeway is the clock skew tolerated when checking token expiry.
+	Leeway time.Duration
+}
+
+// Static reads users from a directory file and verifies tokens it signs itself. Users are
+// granted the roles and groups of the directory file as it is now, not as it was when their
+// token was signed, so removing a user from the file revokes their tokens on the next start.
+type Static struct {
+	config   StaticConfig
+	verifier *auth.Verifier
+}
+
+// NewStatic returns the static provider.
+func NewStatic(config StaticConfig) (*Static, error) {
+	if config.Directory == nil {
+		return nil, errors.New("the static identity provider needs a directory file (DIRECTORY_FILE)")
+	}
+	if len(config.SigningKey) < 32 {
+		return nil, errors.New("the static identity provider needs a signing key of at least 32 bytes (STATIC_SIGNING_KEY)")
+	}
+
+	verifier, err := auth.NewKeyVerifier(auth.VerifierConfig{
+		Audiences: []string{StaticAudience},
+		Issuer:    StaticIssuer,
+		Leeway:    config.Leeway,
+	}, JWT.SigningMethodHS256, config.SigningKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Static{config: config, verifier: verifier}, nil
+}
+
+// Sign returns a token for the user with an ID or e-mail address that is valid for ttl.
+func (s *Static) Sign(idOrEmail string, ttl time.Duration) (string, error) {
+	user, ok := s.config.Directory.Find(idOrEmail)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUserNotFound, idOrEmail)
+	}
+
+	now := time.Now()
+	token := JWT.NewWithClaims(JWT.SigningMethodHS256, JWT.MapClaims{
+		"iss":   StaticIssuer,
+		"aud":   StaticAudience,
+		"sub":   user.ID,
+		"name":  user.DisplayName,
+		"email": user.Email,
+		"iat":   now.Unix(),
+		"nbf":   now.Unix(),
+		"exp":   now.Add(ttl).Unix(),
+	})
+	return token.SignedString(s.config.SigningKey)
+}
+
+// Authenticate verifies a token signed by Sign; users are identified by the sub claim.
+func (s *Static) Authenticate(token string) (auth.Principal, error) {
+	claims, err := s.verifier.Verify(token)
+	if err != nil {
+		return auth.Principal{}, err
+	}
+	principal, err := auth.FromClaims(claims, "sub")
+	if err != nil {
+		return auth.Principal{}, &auth.VerificationError{Reason: auth.ReasonNoSubject, Err: err}
+	}
+
+	user, ok := s.config.Directory.Find(principal.ID)
+	if !ok || user.ID != principal.ID {
+		return auth.Principal{}, &auth.VerificationError{Reason: auth.ReasonNoSubject, Err: fmt.Errorf("user %q is not in the directory", principal.ID)}
+	}
+	return auth.Principal{
+		ID:       user.ID,
+		Name:     user.DisplayName,
+		Email:    user.Email,
+		AppRoles: append([]string{}, user.Roles...),
+		Groups:   append([]string{}, user.Groups...),
+	}, nil
+}
+
+// Users lists the users of the directory file.
+func (s *Static) Users(context.Context) ([]models.User, error) {
+	return s.config.Directory.list(), nil
+}
+
+// User returns a user of the directory file.
+func (s *Static) User(_ context.Context, id string) (models.User, error) {
+	return findUser(s.config.Directory.list(), id)
+}
+
+// Photo returns no photo; directory files have none.
+func (s *Static) Photo(context.Context, string) ([]byte, string, error) {
+	return nil, "", nil
+}
+
+// Close does nothing.
+func (s *Static) Close() {}
@@ -0,0 +1,176 @@
+// Package inventory performs the operations that change the state of components.
+//
+// Every operation runs in a single database transaction: the component row is locked,
+// its status is changed through the lifecycle and the matching inventory history entry
+// is written, so a component never ends up in a status its history does not explain.
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"vinventory/internal/attributes"
+	"vinventory/internal/lifecycle"
+	"vinventory/internal/models"
+	"vinventory/internal/repository"
+)
+
+var (
+	// ErrComponentNotFound is returned when the component of an operation does not exist.
+	ErrComponentNotFound = errors.New("component not found")
+	// ErrInvalidStatus is returned when a new component is given a status other than the initial one.
+	ErrInvalidStatus = errors.New("invalid status")
+)
+
+// Actor is the user an operation is recorded for.
+type Actor struct {
+	UserID string
+	// UserName is kept in the history so entries stay readable after the user is deleted.
+	UserName string
+	// RecordedByID and RecordedByName name the signed-in user performing the operation,
+	// who is someone other than the user when acting on their behalf.
+	RecordedByID   string
+	RecordedByName string
+}
+
+// Service performs inventory operations on a store.
+type Service struct {
+	store repository.Store
+	now   func() time.Time
+}
+
+// NewService returns a service working on store.
+func NewService(store repository.Store) *Service {
+	return &Service{store: store, now: time.Now}
+}
+
+// AddComponent creates a component in the initial status of the lifecycle together with
+// its "Added" history entry. An empty status is set to the initial one.
+func (s *Service) AddComponent(component *models.Component, actor Actor) (models.InventoryHistory, error) {
+	initial := lifecycle.Current().Initial
+	if component.Status == "" {
+		component.Status = initial
+	}
+	if component.Status != initial {
+		return models.InventoryHistory{}, fmt.Errorf("%w: new components start as %q", ErrInvalidStatus, initial)
+	}
+
+	var history models.InventoryHistory
+	err := s.store.Transaction(func(tx repository.Store) error {
+		if err := tx.Components().Create(component); err != nil {
+			return err
+		}
+
+		history = s.entry(component.ID, lifecycle.Added, actor)
+		return tx.History().Create(&history)
+	})
+	return history, err
+}
+
+// Transition applies a lifecycle operation to a component. The component row is locked
+// for the duration of the transaction, so concurrent operations on the same component
+// are applied one after the other against its latest status.
+func (s *Service) Transition(componentID int, operation string, actor Actor) (models.Component, models.InventoryHistory, error) {
+	return s.TransitionIf(componentID, nil, operation, actor)
+}
+
+// TransitionIf is Transition for a client that has seen a particular version of the component:
+// unless match accepts the version of the locked component, nothing is changed and
+// repository.ErrVersionConflict is returned. A nil match accepts any version.
+func (s *Service) TransitionIf(componentID int, match func(version int) bool, operation string, actor Actor) (models.Component, models.InventoryHistory, error) {
+	var component models.Component
+	var history models.InventoryHistory
+	err := s.store.Transaction(func(tx repository.Store) error {
+		var err error
+		component, err = tx.Components().GetForUpdate(componentID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrComponentNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if match != nil && !match(component.Version) {
+			return repository.ErrVersionConflict
+		}
+
+		status, err := lifecycle.Current().Apply(component.Status, operation)
+		if err != nil {
+			return err
+		}
+
+		component.Status = status
+		if err := tx.Components().UpdateStatus(component.ID, status); err != nil {
+			return err
+		}
+		component.Version++
+
+		history = s.entry(component.ID, operation, actor)
+		return tx.History().Create(&history)
+	})
+	return component, history, err
+}
+
+// EditComponent saves an edited component together with the change of every field the edit makes,
+// recorded for actor. component must hold the version it was read at: if the stored component has
+// been changed since, nothing is saved and repository.ErrVersionConflict is returned.
+func (s *Service) EditComponent(component *models.Component, actor Actor) ([]models.ComponentChange, error) {
+	read := component.Version
+	var changes []models.ComponentChange
+	err := s.store.Transaction(func(tx repository.Store) error {
+		before, err := tx.Components().GetForUpdate(component.ID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrComponentNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if before.Version != read {
+			return repository.ErrVersionConflict
+		}
+
+		if err := tx.Components().Save(component); err != nil {
+			return err
+		}
+
+		changes = FieldChanges(before, *component, actor, s.now())
+		return tx.History().CreateChanges(changes)
+	})
+	if err != nil {
+		// The transaction was rolled back, so the component keeps the version it was read at
+		component.Version = read
+		return nil, err
+	}
+	return changes, nil
+}
+
+// FieldChanges returns the change history entries of every field that differs between two states of
+// a component, recorded for actor at the given time. after holds the version the change produced.
+func FieldChanges(before, after models.Component, actor Actor, at time.Time) []models.ComponentChange {
+	var changes []models.ComponentChange
+	for _, change := range attributes.Changes(before, after) {
+		changes = append(changes, models.ComponentChange{
+			CreatedAt:   at,
+			ComponentID: after.ID,
+			Version:     after.Version,
+			UserID:      actor.UserID,
+			UserName:    actor.UserName,
+			Field:       change.Field,
+			OldValue:    change.OldValue,
+			NewValue:    change.NewValue,
+		})
+	}
+	return changes
+}
+
+func (s *Service) entry(componentID int, operation string, actor Actor) models.InventoryHistory {
+	return models.InventoryHistory{
+		ComponentID:    componentID,
+		UserID:         actor.UserID,
+		UserName:       actor.UserName,
+		OperationType:  operation,
+		CreatedAt:      s.now(),
+		RecordedByID:   actor.RecordedByID,
+		RecordedByName: actor.RecordedByName,
+	}
+}
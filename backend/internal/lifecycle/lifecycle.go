@@ -0,0 +1,259 @@
+// Package lifecycle defines the statuses a component can be in, the transitions between
+// them and the inventory history operation each transition records.
+//
+// The built-in lifecycle can be replaced at startup by pointing LIFECYCLE_CONFIG at a JSON
+// file with the same shape as the GET /lifecycle response:
+//
+//	{
+//	  "initial": "Ready to Use",
+//	  "statuses": ["Ready to Use", "Being Used", "Out of Inventory"],
+//	  "inUse": ["Being Used"],
+//	  "transitions": [{"operation": "Assigned", "from": ["Ready to Use"], "to": "Being Used"}]
+//	}
+//
+// Without inUse, components are in use in the status the Assigned operation leads to.
+package lifecycle
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Built-in statuses.
+const (
+	ReadyToUse     = "Ready to Use"
+	BeingUsed      = "Being Used"
+	OutOfInventory = "Out of Inventory"
+	InRepair       = "In Repair"
+	Lost           = "Lost"
+	Retired        = "Retired"
+)
+
+// Built-in operations recorded in the inventory history.
+const (
+	Added        = "Added"
+	Assigned     = "Assigned"
+	Returned     = "Returned"
+	Deactivated  = "Deactivated"
+	Activated    = "Activated"
+	SentToRepair = "Sent to Repair"
+	Repaired     = "Repaired"
+	ReportedLost = "Reported Lost"
+	Found        = "Found"
+	Retire       = "Retired"
+	// TypeChanged is recorded when a component moves to another type; it does not change the status.
+	TypeChanged = "Type Changed"
+)
+
+// ErrUnknownOperation is returned for operations that are not part of the lifecycle.
+var ErrUnknownOperation = errors.New("unknown operation")
+
+// Transition moves a component from one of the From statuses to To and records Operation.
+type Transition struct {
+	Operation string   `json:"operation"`
+	From      []string `json:"from"`
+	To        string   `json:"to"`
+}
+
+// Lifecycle is a validated set of statuses and transitions.
+type Lifecycle struct {
+	// Initial is the status of newly added components; adding them records the Added operation.
+	Initial  string   `json:"initial"`
+	Statuses []string `json:"statuses"`
+	// InUse lists the statuses in which a component is held by the user of its latest history
+	// entry; holder filters and searches only match components in these statuses.
+	InUse       []string     `json:"inUse"`
+	Transitions []Transition `json:"transitions"`
+}
+
+// TransitionError is returned when an operation is not allowed from a component's current status.
+type TransitionError struct {
+	Status    string   `json:"status"`
+	Operation string   `json:"operation"`
+	Allowed   []string `json:"allowed"`
+}
+
+func (e *TransitionError) Error() string {
+	if len(e.Allowed) == 0 {
+		return fmt.Sprintf("cannot %q a component that is %q; no operations are allowed from this status", e.Operation, e.Status)
+	}
+	return fmt.Sprintf("cannot %q a component that is %q; allowed operations: %s", e.Operation, e.Status, strings.Join(e.Allowed, ", "))
+}
+
+// Default returns the built-in lifecycle.
+func Default() *Lifecycle {
+	return &Lifecycle{
+		Initial:  ReadyToUse,
+		Statuses: []string{ReadyToUse, BeingUsed, OutOfInventory, InRepair, Lost, Retired},
+		InUse:    []string{BeingUsed},
+		Transitions: []Transition{
+			{Operation: Assigned, From: []string{ReadyToUse}, To: BeingUsed},
+			{Operation: Returned, From: []string{BeingUsed}, To: ReadyToUse},
+			{Operation: Deactivated, From: []string{ReadyToUse, BeingUsed, InRepair, Lost}, To: OutOfInventory},
+			{Operation: Activated, From: []string{OutOfInventory}, To: ReadyToUse},
+			{Operation: SentToRepair, From: []string{ReadyToUse, BeingUsed}, To: InRepair},
+			{Operation: Repaired, From: []string{InRepair}, To: ReadyToUse},
+			{Operation: ReportedLost, From: []string{ReadyToUse, BeingUsed, InRepair}, To: Lost},
+			{Operation: Found, From: []string{Lost}, To: ReadyToUse},
+			{Operation: Retire, From: []string{ReadyToUse, OutOfInventory, InRepair, Lost}, To: Retired},
+		},
+	}
+}
+
+// LoadFile reads a lifecycle definition from a JSON file.
+func LoadFile(path string) (*Lifecycle, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lifecycle file: %w", err)
+	}
+
+	var lifecycle Lifecycle
+	if err := json.Unmarshal(data, &lifecycle); err != nil {
+		return nil, fmt.Errorf("failed to parse lifecycle file: %w", err)
+	}
+	if lifecycle.InUse == nil {
+		lifecycle.InUse = []string{}
+		if assigned, ok := lifecycle.find(Assigned); ok {
+			lifecycle.InUse = append(lifecycle.InUse, assigned.To)
+		}
+	}
+	if err := lifecycle.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &lifecycle, nil
+}
+
+// Validate checks that every transition refers to declared statuses and that operations are unique.
+func (l *Lifecycle) Validate() error {
+	if !l.IsStatus(l.Initial) {
+		return fmt.Errorf("initial status %q is not declared", l.Initial)
+	}
+	for _, status := range l.InUse {
+		if !l.IsStatus(status) {
+			return fmt.Errorf("in-use status %q is not declared", status)
+		}
+	}
+
+	operations := map[string]bool{Added: true, TypeChanged: true}
+	for _, transition := range l.Transitions {
+		if transition.Operation == "" {
+			return fmt.Errorf("transition to %q has no operation", transition.To)
+		}
+		if operations[transition.Operation] {
+			return fmt.Errorf("operation %q is defined more than once", transition.Operation)
+		}
+		operations[transition.Operation] = true
+
+		if !l.IsStatus(transition.To) {
+			return fmt.Errorf("operation %q leads to undeclared status %q", transition.Operation, transition.To)
+		}
+		for _, from := range transition.From {
+			if !l.IsStatus(from) {
+				return fmt.Errorf("operation %q starts from undeclared status %q", transition.Operation, from)
+			}
+		}
+	}
+
+	return nil
+}
+
+// IsStatus reports whether status is declared.
+func (l *Lifecycle) IsStatus(status string) bool {
+	for _, s := range l.Statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// IsInUse reports whether a component in status is held by the user of its latest history entry.
+func (l *Lifecycle) IsInUse(status string) bool {
+	for _, s := range l.InUse {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// IsOperation reports whether operation can be recorded in the inventory history.
+func (l *Lifecycle) IsOperation(operation string) bool {
+	if operation == Added || operation == TypeChanged {
+		return true
+	}
+	_, ok := l.find(operation)
+	return ok
+}
+
+// Operations returns the operations recorded in the inventory history, transitions first.
+func (l *Lifecycle) Operations() []string {
+	operations := []string{Added, TypeChanged}
+	for _, transition := range l.Transitions {
+		operations = append(operations, transition.Operation)
+	}
+	return operations
+}
+
+// Allowed returns the operations that can be applied to a component in status.
+func (l *Lifecycle) Allowed(status string) []string {
+	allowed := []string{}
+	for _, transition := range l.Transitions {
+		for _, from := range transition.From {
+			if from == status {
+				allowed = append(allowed, transition.Operation)
+				break
+			}
+		}
+	}
+	return allowed
+}
+
+// Apply returns the status a component in status ends up in after operation.
+func (l *Lifecycle) Apply(status, operation string) (string, error) {
+	transition, ok := l.find(operation)
+	if !ok {
+		return "", fmt.Errorf("%w %q", ErrUnknownOperation, operation)
+	}
+
+	for _, from := range transition.From {
+		if from == status {
+			return transition.To, nil
+		}
+	}
+
+	return "", &TransitionError{Status: status, Operation: operation, Allowed: l.Allowed(status)}
+}
+
+func (l *Lifecycle) find(operation string) (Transition, bool) {
+	for _, transition := range l.Transitions {
+		if transition.Operation == operation {
+			return transition, true
+		}
+	}
+	return Transition{}, false
+}
+
+var (
+	mu      sync.RWMutex
+	current = Default()
+)
+
+// Current returns the lifecycle in use.
+func Current() *Lifecycle {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// Use replaces the lifecycle in use.
+func Use(lifecycle *Lifecycle) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = lifecycle
+}
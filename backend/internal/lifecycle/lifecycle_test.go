@@ -0,0 +1,163 @@
+package lifecycle
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	lifecycle := Default()
+	tests := []struct {
+		status    string
+		operation string
+		want      string
+	}{
+		{status: ReadyToUse, operation: Assigned, want: BeingUsed},
+		{status: BeingUsed, operation: Returned, want: ReadyToUse},
+		{status: ReadyToUse, operation: Deactivated, want: OutOfInventory},
+		{status: BeingUsed, operation: Deactivated, want: OutOfInventory},
+		{status: OutOfInventory, operation: Activated, want: ReadyToUse},
+		{status: BeingUsed, operation: SentToRepair, want: InRepair},
+		{status: InRepair, operation: Repaired, want: ReadyToUse},
+		{status: InRepair, operation: ReportedLost, want: Lost},
+		{status: Lost, operation: Found, want: ReadyToUse},
+		{status: Lost, operation: Retire, want: Retired},
+	}
+	for _, test := range tests {
+		status, err := lifecycle.Apply(test.status, test.operation)
+		if err != nil || status != test.want {
+			t.Errorf("Apply(%q, %q) = %q, %v; want %q", test.status, test.operation, status, err, test.want)
+		}
+	}
+}
+
+func TestApplyForbidden(t *testing.T) {
+	lifecycle := Default()
+	tests := []struct {
+		status    string
+		operation string
+		allowed   []string
+	}{
+		{status: BeingUsed, operation: Assigned, allowed: []string{Returned, Deactivated, SentToRepair, ReportedLost}},
+		{status: ReadyToUse, operation: Returned, allowed: []string{Assigned, Deactivated, SentToRepair, ReportedLost, Retire}},
+		{status: OutOfInventory, operation: Assigned, allowed: []string{Activated, Retire}},
+		{status: BeingUsed, operation: Retire, allowed: []string{Returned, Deactivated, SentToRepair, ReportedLost}},
+		// Retired components stay retired
+		{status: Retired, operation: Activated, allowed: []string{}},
+	}
+	for _, test := range tests {
+		_, err := lifecycle.Apply(test.status, test.operation)
+		var transitionErr *TransitionError
+		if !errors.As(err, &transitionErr) {
+			t.Errorf("Apply(%q, %q) error = %v; want a *TransitionError", test.status, test.operation, err)
+			continue
+		}
+		if transitionErr.Status != test.status || transitionErr.Operation != test.operation || !reflect.DeepEqual(transitionErr.Allowed, test.allowed) {
+			t.Errorf("Apply(%q, %q) error = %+v; want allowed %v", test.status, test.operation, transitionErr, test.allowed)
+		}
+	}
+}
+
+func TestApplyUnknownOperation(t *testing.T) {
+	// Added and Type Changed are recorded in the history but do not move a component
+	for _, operation := range []string{"Stolen", "", Added, TypeChanged} {
+		if _, err := Default().Apply(ReadyToUse, operation); !errors.Is(err, ErrUnknownOperation) {
+			t.Errorf("Apply(%q, %q) error = %v; want ErrUnknownOperation", ReadyToUse, operation, err)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	statuses := []string{"Free", "Loaned"}
+	tests := []struct {
+		name      string
+		lifecycle Lifecycle
+		err       string
+	}{
+		{name: "default", lifecycle: *Default()},
+		{
+			name: "custom",
+			lifecycle: Lifecycle{Initial: "Free", Statuses: statuses, InUse: []string{"Loaned"}, Transitions: []Transition{
+				{Operation: "Loan", From: []string{"Free"}, To: "Loaned"},
+			}},
+		},
+		{name: "undeclared initial", lifecycle: Lifecycle{Initial: "New", Statuses: statuses}, err: `initial status "New" is not declared`},
+		{name: "undeclared in use", lifecycle: Lifecycle{Initial: "Free", Statuses: statuses, InUse: []string{"Busy"}}, err: `in-use status "Busy" is not declared`},
+		{
+			name:      "no operation",
+			lifecycle: Lifecycle{Initial: "Free", Statuses: statuses, Transitions: []Transition{{From: []string{"Free"}, To: "Loaned"}}},
+			err:       "has no operation",
+		},
+		{
+			name: "duplicate operation",
+			lifecycle: Lifecycle{Initial: "Free", Statuses: statuses, Transitions: []Transition{
+				{Operation: "Loan", From: []string{"Free"}, To: "Loaned"},
+				{Operation: "Loan", From: []string{"Loaned"}, To: "Free"},
+			}},
+			err: `operation "Loan" is defined more than once`,
+		},
+		{
+			name:      "reserved operation",
+			lifecycle: Lifecycle{Initial: "Free", Statuses: statuses, Transitions: []Transition{{Operation: Added, From: []string{"Free"}, To: "Loaned"}}},
+			err:       `operation "Added" is defined more than once`,
+		},
+		{
+			name:      "undeclared target",
+			lifecycle: Lifecycle{Initial: "Free", Statuses: statuses, Transitions: []Transition{{Operation: "Loan", From: []string{"Free"}, To: "Gone"}}},
+			err:       `leads to undeclared status "Gone"`,
+		},
+		{
+			name:      "undeclared source",
+			lifecycle: Lifecycle{Initial: "Free", Statuses: statuses, Transitions: []Transition{{Operation: "Loan", From: []string{"Gone"}, To: "Loaned"}}},
+			err:       `starts from undeclared status "Gone"`,
+		},
+	}
+	for _, test := range tests {
+		err := test.lifecycle.Validate()
+		if test.err == "" && err != nil || test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("%s: Validate() = %v; want %q", test.name, err, test.err)
+		}
+	}
+}
+
+func TestLoadFileInUse(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		want []string
+	}{
+		{
+			name: "declared",
+			file: `{"initial": "Free", "statuses": ["Free", "Loaned", "Lent"], "inUse": ["Loaned", "Lent"], "transitions": []}`,
+			want: []string{"Loaned", "Lent"},
+		},
+		{
+			name: "from Assigned",
+			file: `{"initial": "Free", "statuses": ["Free", "Loaned"], "transitions": [{"operation": "Assigned", "from": ["Free"], "to": "Loaned"}]}`,
+			want: []string{"Loaned"},
+		},
+		{
+			name: "none",
+			file: `{"initial": "Free", "statuses": ["Free", "Loaned"], "transitions": [{"operation": "Loan", "from": ["Free"], "to": "Loaned"}]}`,
+			want: []string{},
+		},
+	}
+	for _, test := range tests {
+		path := filepath.Join(t.TempDir(), "lifecycle.json")
+		if err := os.WriteFile(path, []byte(test.file), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		lifecycle, err := LoadFile(path)
+		if err != nil {
+			t.Errorf("%s: LoadFile() error = %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(lifecycle.InUse, test.want) {
+			t.Errorf("%s: InUse = %v; want %v", test.name, lifecycle.InUse, test.want)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+// Package mergepatch applies JSON Merge Patch documents (RFC 7396).
+//
+// A merge patch looks like the resource it changes: members it sets replace those of the
+// resource, objects are merged recursively and a null member removes the member. Arrays
+// and other values are replaced as a whole.
+package mergepatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// ContentType is the media type of merge patch request bodies.
+const ContentType = "application/merge-patch+json"
+
+// Apply applies patch to the JSON document and returns the patched document.
+func Apply(document []byte, patch []byte) ([]byte, error) {
+	var patchValue interface{}
+	if err := decode(patch, &patchValue); err != nil {
+		return nil, fmt.Errorf("invalid merge patch: %w", err)
+	}
+
+	var documentValue interface{}
+	if len(bytes.TrimSpace(document)) > 0 {
+		if err := decode(document, &documentValue); err != nil {
+			return nil, fmt.Errorf("invalid document: %w", err)
+		}
+	}
+
+	return json.Marshal(merge(documentValue, patchValue))
+}
+
+// merge is the MergePatch function of RFC 7396, section 2.
+func merge(target interface{}, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = map[string]interface{}{}
+	}
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+			continue
+		}
+		targetObject[name] = merge(targetObject[name], value)
+	}
+	return targetObject
+}
+
+// decode reads a single JSON value, keeping numbers as written so that large integers survive.
+func decode(data []byte, value *interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(value); err != nil {
+		return err
+	}
+	if decoder.More() {
+		return fmt.Errorf("unexpected data after the JSON value")
+	}
+	return nil
+}
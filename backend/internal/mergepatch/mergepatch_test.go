@@ -0,0 +1,90 @@
+package mergepatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		document string
+		patch    string
+		want     string
+	}{
+		// The examples of RFC 7396, appendix A
+		{document: `{"a":"b"}`, patch: `{"a":"c"}`, want: `{"a":"c"}`},
+		{document: `{"a":"b"}`, patch: `{"b":"c"}`, want: `{"a":"b","b":"c"}`},
+		{document: `{"a":"b"}`, patch: `{"a":null}`, want: `{}`},
+		{document: `{"a":"b","b":"c"}`, patch: `{"a":null}`, want: `{"b":"c"}`},
+		{document: `{"a":["b"]}`, patch: `{"a":"c"}`, want: `{"a":"c"}`},
+		{document: `{"a":"c"}`, patch: `{"a":["b"]}`, want: `{"a":["b"]}`},
+		{document: `{"a":{"b":"c"}}`, patch: `{"a":{"b":"d","c":null}}`, want: `{"a":{"b":"d"}}`},
+		{document: `{"a":[{"b":"c"}]}`, patch: `{"a":[1]}`, want: `{"a":[1]}`},
+		{document: `["a","b"]`, patch: `["c","d"]`, want: `["c","d"]`},
+		{document: `{"a":"b"}`, patch: `["c"]`, want: `["c"]`},
+		{document: `{"a":"foo"}`, patch: `null`, want: `null`},
+		{document: `{"a":"foo"}`, patch: `"bar"`, want: `"bar"`},
+		{document: `{"e":null}`, patch: `{"a":1}`, want: `{"e":null,"a":1}`},
+		{document: `[1,2]`, patch: `{"a":"b","c":null}`, want: `{"a":"b"}`},
+		{document: `{}`, patch: `{"a":{"bb":{"ccc":null}}}`, want: `{"a":{"bb":{}}}`},
+
+		// Nested objects are merged member by member, at any depth
+		{
+			document: `{"name":"Laptop","attributes":{"color":"red","gpu":{"vendor":"nvidia","vram":8}}}`,
+			patch:    `{"attributes":{"gpu":{"vram":16},"color":null,"ports":["usb-c"]}}`,
+			want:     `{"name":"Laptop","attributes":{"gpu":{"vendor":"nvidia","vram":16},"ports":["usb-c"]}}`,
+		},
+		// A null member removes a whole object, and removing a missing member does nothing
+		{document: `{"a":{"b":1},"c":2}`, patch: `{"a":null,"x":null}`, want: `{"c":2}`},
+		// An empty patch leaves the document unchanged
+		{document: `{"a":{"b":[1,2]}}`, patch: `{}`, want: `{"a":{"b":[1,2]}}`},
+		// An empty document is patched like null
+		{document: ``, patch: `{"a":1}`, want: `{"a":1}`},
+		// Numbers are kept as written
+		{document: `{"id":12345678901234567890}`, patch: `{"price":1.10}`, want: `{"id":12345678901234567890,"price":1.10}`},
+	}
+	for _, test := range tests {
+		patched, err := Apply([]byte(test.document), []byte(test.patch))
+		if err != nil {
+			t.Errorf("Apply(%s, %s) error = %v", test.document, test.patch, err)
+			continue
+		}
+		if !sameJSON(t, patched, []byte(test.want)) {
+			t.Errorf("Apply(%s, %s) = %s; want %s", test.document, test.patch, patched, test.want)
+		}
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	tests := []struct {
+		document string
+		patch    string
+	}{
+		{document: `{}`, patch: ``},
+		{document: `{}`, patch: `{"a":`},
+		{document: `{}`, patch: `{"a":1} {"b":2}`},
+		{document: `{"a":`, patch: `{}`},
+		{document: `{} []`, patch: `{}`},
+	}
+	for _, test := range tests {
+		if patched, err := Apply([]byte(test.document), []byte(test.patch)); err == nil {
+			t.Errorf("Apply(%q, %q) = %s; want an error", test.document, test.patch, patched)
+		}
+	}
+}
+
+// sameJSON reports whether two JSON documents hold the same value, comparing numbers as written
+func sameJSON(t *testing.T, a, b []byte) bool {
+	t.Helper()
+	var values [2]interface{}
+	for i, data := range [][]byte{a, b} {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&values[i]); err != nil {
+			t.Fatalf("invalid JSON %s: %v", data, err)
+		}
+	}
+	return reflect.DeepEqual(values[0], values[1])
+}
@@ -48,6 +48,14 @@ var (
 		[]string{"type", "endpoint"},
 	)
 
+	TokenVerificationFailures = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "auth_token_verification_failures_total",
+			Help: "Total number of requests rejected because their token failed verification",
+		},
+		[]string{"reason"},
+	)
+
 	MemoryUsage = promauto.NewGauge(
 		prometheus.GaugeOpts{
 			Name: "memory_usage_bytes",
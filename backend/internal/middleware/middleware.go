@@ -1,42 +1,94 @@
 package middleware
 
 import (
-	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/MicahParks/keyfunc"
 	"log"
 	"net/http"
-	"net/url"
-	"os"
 	"runtime"
 	"strings"
 	"time"
 
+	"vinventory/internal/auth"
+	"vinventory/internal/identity"
 	"vinventory/internal/metrics"
 
-	JWT "github.com/golang-jwt/jwt/v4"
+	"github.com/gorilla/mux"
 )
 
-// AuthMiddleware validates JWT tokens from Azure AD
+// AuthMiddleware has the identity provider in use verify tokens and stores the principal they name in the request context.
+// Rejected tokens are counted in metrics.TokenVerificationFailures by reason
 func AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		tokenString := extractToken(r)
 		if tokenString == "" {
+			metrics.TokenVerificationFailures.WithLabelValues("missing").Inc()
 			http.Error(w, "Unauthorized: no token provided", http.StatusUnauthorized)
 			return
 		}
 
-		token, err := verifyIDToken(r.Context(), tokenString)
+		principal, err := identity.Current().Authenticate(tokenString)
 		if err != nil {
-			http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+			unauthorized(w, err)
 			return
 		}
+		principal = auth.CurrentPolicy().Grant(principal)
 
-		ctx := context.WithValue(r.Context(), "user", token)
-		next.ServeHTTP(w, r.WithContext(ctx))
+		next.ServeHTTP(w, r.WithContext(auth.NewContext(r.Context(), principal)))
 	})
 }
 
+// unauthorized answers a request whose token failed verification and counts the failure by reason
+func unauthorized(w http.ResponseWriter, err error) {
+	reason := auth.ReasonInvalidClaims
+	var verificationErr *auth.VerificationError
+	if errors.As(err, &verificationErr) {
+		reason = verificationErr.Reason
+	}
+	metrics.TokenVerificationFailures.WithLabelValues(reason).Inc()
+	http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+}
+
+// Require authenticates requests like AuthMiddleware and lets through only principals granted permission
+func Require(permission auth.Permission, next http.Handler) http.Handler {
+	return AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, _ := auth.FromContext(r.Context())
+		if !principal.Can(permission) {
+			Forbidden(w, permission)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}))
+}
+
+// RequireSelfOr is Require for routes about a single user: principals may always call them about
+// themselves, as named by the route variable userVar, and need permission for anybody else
+func RequireSelfOr(permission auth.Permission, userVar string, next http.Handler) http.Handler {
+	return AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, _ := auth.FromContext(r.Context())
+		if mux.Vars(r)[userVar] != principal.ID && !principal.Can(permission) {
+			Forbidden(w, permission)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}))
+}
+
+// Forbidden answers a request whose principal lacks permission, naming the permission in the response.
+// Handlers that check a permission themselves answer with it too
+func Forbidden(w http.ResponseWriter, permission auth.Permission) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	err := json.NewEncoder(w).Encode(map[string]string{
+		"error":      fmt.Sprintf("Forbidden: this operation requires the %s permission, which none of your roles grant", permission),
+		"permission": string(permission),
+	})
+	if err != nil {
+		log.Printf("failed to write forbidden response: %v", err)
+	}
+}
+
 // extractToken extracts the token from the Authorization header or cookie
 func extractToken(r *http.Request) string {
 	// Try to extract from Authorization header first
@@ -58,44 +110,6 @@ func extractToken(r *http.Request) string {
 	return ""
 }
 
-// verifyIDToken verifies the ID token using public keys fetched from Azure AD
-func verifyIDToken(ctx context.Context, tokenString string) (*JWT.Token, error) {
-	tenantID := url.QueryEscape(os.Getenv("AZURE_TENANT_ID"))
-	jwksURL := fmt.Sprintf("https://login.microsoftonline.com/%s/discovery/v2.0/keys", tenantID)
-
-	options := keyfunc.Options{
-		Ctx: ctx,
-		RefreshErrorHandler: func(err error) {
-			log.Printf("There was an error with the jwt.Keyfunc\nError: %s", err.Error())
-		},
-		RefreshInterval:   time.Hour,
-		RefreshRateLimit:  time.Minute * 5,
-		RefreshTimeout:    time.Second * 10,
-		RefreshUnknownKID: true,
-	}
-
-	// Create the JWKS from the resource at the given URL.
-	jwks, err := keyfunc.Get(jwksURL, options)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create JWKS from resource at the given URL: %w", err)
-	}
-	defer jwks.EndBackground()
-
-	// Parse the JWT.
-	token, err := JWT.Parse(tokenString, jwks.Keyfunc)
-	if err != nil {
-		return nil, fmt.Errorf("could not parse or verify JWT token: %w", err)
-	}
-
-	// Check if the token is valid.
-	if !token.Valid {
-		return nil, fmt.Errorf("the token is not valid")
-	}
-
-	log.Println("The token is valid.")
-	return token, nil
-}
-
 // Counts HTTP requests
 func RequestCounterMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,77 @@
+package migrate
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+const usage = "usage: vinventory migrate up | down [steps] | status | baseline <version>"
+
+// Run executes a migrate subcommand, writing a human readable report to out.
+func Run(m *Migrator, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf(usage)
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := m.Up()
+		for _, migration := range applied {
+			fmt.Fprintf(out, "applied  %03d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Fprintln(out, "database is up to date")
+		}
+		return nil
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+			steps = n
+		}
+		reverted, err := m.Down(steps)
+		for _, migration := range reverted {
+			fmt.Fprintf(out, "reverted %03d_%s\n", migration.Version, migration.Name)
+		}
+		return err
+
+	case "status":
+		statuses, err := m.Status()
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied  " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(out, "%03d_%-40s %s\n", status.Version, status.Name, state)
+		}
+		return nil
+
+	case "baseline":
+		if len(args) < 2 {
+			return fmt.Errorf(usage)
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		if err := m.Baseline(version); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "marked migrations up to %03d as applied\n", version)
+		return nil
+
+	default:
+		return fmt.Errorf("unknown migrate command %q; %s", args[0], usage)
+	}
+}
@@ -0,0 +1,367 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// advisoryLockKey serialises migration runs between replicas sharing a database.
+const advisoryLockKey = 72_617_001
+
+// MinimumPostgresVersion is the oldest Postgres server_version_num migrations run on. Every
+// migration runs in a transaction, and Postgres only allows ALTER TYPE ... ADD VALUE (005) in
+// one from version 12 on.
+const MinimumPostgresVersion = 120000
+
+// ErrSchemaOutdated is returned by Check when the database is not at the latest version.
+var ErrSchemaOutdated = errors.New("database schema is out of date")
+
+var fileNamePattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is a single versioned schema change.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status describes whether a migration has been applied to the database.
+type Status struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"appliedAt,omitempty"`
+}
+
+// SchemaMigration is a row of the schema_migrations bookkeeping table.
+type SchemaMigration struct {
+	Version   int `gorm:"primaryKey"`
+	Name      string
+	AppliedAt time.Time
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Migrator applies and reverts migrations against a database.
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+// Load reads the NNN_name.up.sql / NNN_name.down.sql pairs from fsys, ordered by version.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := fileNamePattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d is used by both %q and %q", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %03d_%s has no up file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// New returns a Migrator for the migrations found in fsys.
+func New(db *gorm.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Up applies every pending migration in order and returns the ones it applied.
+func (m *Migrator) Up() ([]Migration, error) {
+	if err := m.checkServerVersion(); err != nil {
+		return nil, err
+	}
+	if err := m.ensureTable(); err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	for _, migration := range m.migrations {
+		ran := false
+		err := m.db.Transaction(func(tx *gorm.DB) error {
+			if err := lock(tx); err != nil {
+				return err
+			}
+
+			done, err := isApplied(tx, migration.Version)
+			if err != nil || done {
+				return err
+			}
+
+			if err := tx.Exec(migration.Up).Error; err != nil {
+				return fmt.Errorf("migration %03d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+
+			ran = true
+			return tx.Create(&SchemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now().UTC()}).Error
+		})
+		if err != nil {
+			return applied, err
+		}
+		if ran {
+			applied = append(applied, migration)
+		}
+	}
+
+	return applied, nil
+}
+
+// Down reverts the last steps applied migrations, newest first, and returns the ones it reverted.
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	if err := m.ensureTable(); err != nil {
+		return nil, err
+	}
+
+	var reverted []Migration
+	for i := 0; i < steps; i++ {
+		var migration Migration
+		// The last applied version is read under the lock, so concurrent runs never revert it twice
+		err := m.db.Transaction(func(tx *gorm.DB) error {
+			if err := lock(tx); err != nil {
+				return err
+			}
+
+			var last SchemaMigration
+			if err := tx.Order("version DESC").Limit(1).Find(&last).Error; err != nil {
+				return fmt.Errorf("failed to read applied migrations: %w", err)
+			}
+			if last.Version == 0 {
+				return nil
+			}
+
+			var ok bool
+			if migration, ok = m.find(last.Version); !ok {
+				return fmt.Errorf("applied migration %03d_%s is unknown to this binary", last.Version, last.Name)
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("migration %03d_%s has no down file", migration.Version, migration.Name)
+			}
+
+			if err := tx.Exec(migration.Down).Error; err != nil {
+				return fmt.Errorf("reverting migration %03d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+			return tx.Delete(&SchemaMigration{}, migration.Version).Error
+		})
+		if err != nil {
+			return reverted, err
+		}
+		if migration.Version == 0 {
+			break
+		}
+		reverted = append(reverted, migration)
+	}
+
+	return reverted, nil
+}
+
+// Baseline marks every migration up to and including version as applied without running it.
+// It is meant for databases whose schema was created by hand before migrations were tracked.
+func (m *Migrator) Baseline(version int) error {
+	if _, ok := m.find(version); !ok {
+		return fmt.Errorf("unknown migration version %d", version)
+	}
+	if err := m.ensureTable(); err != nil {
+		return err
+	}
+
+	return m.db.Transaction(func(tx *gorm.DB) error {
+		if err := lock(tx); err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if migration.Version > version {
+				break
+			}
+			done, err := isApplied(tx, migration.Version)
+			if err != nil {
+				return err
+			}
+			if done {
+				continue
+			}
+			if err := tx.Create(&SchemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now().UTC()}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Status lists every known migration together with whether it has been applied.
+func (m *Migrator) Status() ([]Status, error) {
+	if err := m.ensureTable(); err != nil {
+		return nil, err
+	}
+
+	var rows []SchemaMigration
+	if err := m.db.Order("version").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+
+	appliedAt := make(map[int]time.Time, len(rows))
+	for _, row := range rows {
+		appliedAt[row.Version] = row.AppliedAt
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if at, ok := appliedAt[migration.Version]; ok {
+			at := at
+			status.Applied = true
+			status.AppliedAt = &at
+			delete(appliedAt, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+
+	// Versions recorded in the database but unknown to this binary come from a newer release.
+	for _, row := range rows {
+		if _, unknown := appliedAt[row.Version]; unknown {
+			at := row.AppliedAt
+			statuses = append(statuses, Status{Version: row.Version, Name: row.Name, Applied: true, AppliedAt: &at})
+		}
+	}
+
+	return statuses, nil
+}
+
+// Check returns ErrSchemaOutdated if any migration is pending, or an error if the
+// database has been migrated by a newer release than this binary.
+func (m *Migrator) Check() error {
+	statuses, err := m.Status()
+	if err != nil {
+		return err
+	}
+
+	latest := m.Latest()
+	for _, status := range statuses {
+		if !status.Applied {
+			return fmt.Errorf("%w: migration %03d_%s is pending", ErrSchemaOutdated, status.Version, status.Name)
+		}
+		if status.Version > latest {
+			return fmt.Errorf("database is at version %d but this binary only knows up to %d", status.Version, latest)
+		}
+	}
+
+	return nil
+}
+
+// Latest returns the highest known migration version.
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+func (m *Migrator) find(version int) (Migration, bool) {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+	return Migration{}, false
+}
+
+// checkServerVersion refuses Postgres servers older than MinimumPostgresVersion
+func (m *Migrator) checkServerVersion() error {
+	if m.db.Dialector.Name() != "postgres" {
+		return nil
+	}
+	var version int
+	if err := m.db.Raw("SELECT current_setting('server_version_num')::int").Scan(&version).Error; err != nil {
+		return fmt.Errorf("failed to read the Postgres server version: %w", err)
+	}
+	if version < MinimumPostgresVersion {
+		return fmt.Errorf("migrations need Postgres %d or newer, the server runs version_num %d", MinimumPostgresVersion/10000, version)
+	}
+	return nil
+}
+
+func (m *Migrator) ensureTable() error {
+	// The SQLite driver only reads columns declared as DATETIME back as times
+	timestamp := "TIMESTAMP WITH TIME ZONE"
+	if m.db.Dialector.Name() == "sqlite" {
+		timestamp = "DATETIME"
+	}
+
+	err := m.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INT PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at ` + timestamp + ` NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`).Error
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+	return nil
+}
+
+// lock takes a transaction-scoped advisory lock so that concurrent replicas apply migrations one at a time.
+// SQLite has no advisory locks; its write transactions are already exclusive.
+func lock(tx *gorm.DB) error {
+	if tx.Dialector.Name() != "postgres" {
+		return nil
+	}
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", advisoryLockKey).Error; err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	return nil
+}
+
+func isApplied(tx *gorm.DB, version int) (bool, error) {
+	var count int64
+	if err := tx.Model(&SchemaMigration{}).Where("version = ?", version).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	return count > 0, nil
+}
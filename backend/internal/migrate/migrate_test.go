@@ -0,0 +1,278 @@
+package migrate
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"vinventory/migrations"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func openDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func file(content string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(content)}
+}
+
+// testFiles creates two tables and then adds a column, listed out of order
+var testFiles = fstest.MapFS{
+	"010_add_column.up.sql":     file("ALTER TABLE b ADD COLUMN note TEXT;"),
+	"010_add_column.down.sql":   file("ALTER TABLE b DROP COLUMN note;"),
+	"002_create_b.up.sql":       file("CREATE TABLE b (id INT REFERENCES a (id));"),
+	"002_create_b.down.sql":     file("DROP TABLE b;"),
+	"001_create_a.up.sql":       file("CREATE TABLE a (id INT PRIMARY KEY);"),
+	"001_create_a.down.sql":     file("DROP TABLE a;"),
+	"README.md":                 file("not a migration"),
+	"003_notes.txt":             file("not a migration either"),
+	"drafts/004_draft.up.sql":   file("SELECT 1;"),
+	"drafts/004_draft.down.sql": file("SELECT 1;"),
+}
+
+func versions(migrations []Migration) []int {
+	list := []int{}
+	for _, migration := range migrations {
+		list = append(list, migration.Version)
+	}
+	return list
+}
+
+func TestLoad(t *testing.T) {
+	loaded, err := Load(testFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Migration{
+		{Version: 1, Name: "create_a", Up: "CREATE TABLE a (id INT PRIMARY KEY);", Down: "DROP TABLE a;"},
+		{Version: 2, Name: "create_b", Up: "CREATE TABLE b (id INT REFERENCES a (id));", Down: "DROP TABLE b;"},
+		{Version: 10, Name: "add_column", Up: "ALTER TABLE b ADD COLUMN note TEXT;", Down: "ALTER TABLE b DROP COLUMN note;"},
+	}
+	if !reflect.DeepEqual(loaded, want) {
+		t.Errorf("Load() = %+v; want %+v", loaded, want)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+		err   string
+	}{
+		{
+			name:  "no up file",
+			files: fstest.MapFS{"001_a.down.sql": file("SELECT 1;")},
+			err:   "migration 001_a has no up file",
+		},
+		{
+			name:  "version used twice",
+			files: fstest.MapFS{"001_a.up.sql": file("SELECT 1;"), "001_b.up.sql": file("SELECT 1;")},
+			err:   "migration version 1 is used by both",
+		},
+	}
+	for _, test := range tests {
+		if _, err := Load(test.files); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: Load() error = %v; want %q", test.name, err, test.err)
+		}
+	}
+}
+
+func TestUpDown(t *testing.T) {
+	db := openDB(t)
+	migrator, err := New(db, testFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := migrator.Check(); !errors.Is(err, ErrSchemaOutdated) {
+		t.Errorf("Check() before Up = %v; want ErrSchemaOutdated", err)
+	}
+
+	// Migrations are applied in version order, 10 after 2
+	applied, err := migrator.Up()
+	if err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+	if got := versions(applied); !reflect.DeepEqual(got, []int{1, 2, 10}) {
+		t.Errorf("Up() applied %v; want [1 2 10]", got)
+	}
+	if !db.Migrator().HasColumn("b", "note") {
+		t.Error("Up() did not add b.note")
+	}
+	if err := migrator.Check(); err != nil {
+		t.Errorf("Check() after Up = %v", err)
+	}
+
+	// A second run has nothing to do
+	if applied, err := migrator.Up(); err != nil || len(applied) != 0 {
+		t.Errorf("second Up() = %v, %v; want nothing applied", versions(applied), err)
+	}
+
+	// Down steps revert the newest migrations first
+	reverted, err := migrator.Down(2)
+	if err != nil {
+		t.Fatalf("Down(2) error = %v", err)
+	}
+	if got := versions(reverted); !reflect.DeepEqual(got, []int{10, 2}) {
+		t.Errorf("Down(2) reverted %v; want [10 2]", got)
+	}
+	if db.Migrator().HasTable("b") || !db.Migrator().HasTable("a") {
+		t.Error("Down(2) should drop b and keep a")
+	}
+	assertApplied(t, migrator, map[int]bool{1: true, 2: false, 10: false})
+
+	// Reverting more steps than applied stops at the first migration
+	reverted, err = migrator.Down(5)
+	if err != nil {
+		t.Fatalf("Down(5) error = %v", err)
+	}
+	if got := versions(reverted); !reflect.DeepEqual(got, []int{1}) {
+		t.Errorf("Down(5) reverted %v; want [1]", got)
+	}
+	if reverted, err := migrator.Down(1); err != nil || len(reverted) != 0 {
+		t.Errorf("Down(1) on an empty database = %v, %v; want nothing reverted", versions(reverted), err)
+	}
+
+	// Everything can be applied again
+	if applied, err := migrator.Up(); err != nil || len(applied) != 3 {
+		t.Errorf("Up() after Down = %v, %v; want 3 applied", versions(applied), err)
+	}
+}
+
+func assertApplied(t *testing.T, migrator *Migrator, want map[int]bool) {
+	t.Helper()
+	statuses, err := migrator.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[int]bool{}
+	for _, status := range statuses {
+		got[status.Version] = status.Applied
+		if status.Applied != (status.AppliedAt != nil) {
+			t.Errorf("migration %d: applied %v but appliedAt %v", status.Version, status.Applied, status.AppliedAt)
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Status() applied = %v; want %v", got, want)
+	}
+}
+
+func TestUpStopsAtFailure(t *testing.T) {
+	files := fstest.MapFS{
+		"001_a.up.sql": file("CREATE TABLE a (id INT);"),
+		"002_b.up.sql": file("CREATE TABLE a (id INT);"),
+		"003_c.up.sql": file("CREATE TABLE c (id INT);"),
+	}
+	db := openDB(t)
+	migrator, err := New(db, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := migrator.Up()
+	if err == nil || !strings.Contains(err.Error(), "migration 002_b failed") {
+		t.Errorf("Up() error = %v; want migration 002_b to fail", err)
+	}
+	if got := versions(applied); !reflect.DeepEqual(got, []int{1}) {
+		t.Errorf("Up() applied %v; want [1]", got)
+	}
+	if db.Migrator().HasTable("c") {
+		t.Error("Up() ran a migration after the failed one")
+	}
+	assertApplied(t, migrator, map[int]bool{1: true, 2: false, 3: false})
+}
+
+func TestDownWithoutDownFile(t *testing.T) {
+	files := fstest.MapFS{
+		"001_a.up.sql":   file("CREATE TABLE a (id INT);"),
+		"001_a.down.sql": file("DROP TABLE a;"),
+		"002_b.up.sql":   file("CREATE TABLE b (id INT);"),
+	}
+	migrator, err := New(openDB(t), files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	reverted, err := migrator.Down(2)
+	if err == nil || !strings.Contains(err.Error(), "migration 002_b has no down file") {
+		t.Errorf("Down(2) error = %v; want missing down file", err)
+	}
+	if len(reverted) != 0 {
+		t.Errorf("Down(2) reverted %v; want nothing", versions(reverted))
+	}
+	assertApplied(t, migrator, map[int]bool{1: true, 2: true})
+}
+
+func TestBaselineAndNewerDatabase(t *testing.T) {
+	db := openDB(t)
+	migrator, err := New(db, testFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := migrator.Baseline(3); err == nil {
+		t.Error("Baseline(3) of an unknown version succeeded")
+	}
+	if err := migrator.Baseline(2); err != nil {
+		t.Fatalf("Baseline(2) error = %v", err)
+	}
+	assertApplied(t, migrator, map[int]bool{1: true, 2: true, 10: false})
+	if db.Migrator().HasTable("a") {
+		t.Error("Baseline() ran a migration")
+	}
+
+	// A database migrated by a newer release is refused
+	if err := migrator.Baseline(10); err != nil {
+		t.Fatalf("Baseline(10) error = %v", err)
+	}
+	if err := migrator.Check(); err != nil {
+		t.Errorf("Check() after Baseline(10) = %v", err)
+	}
+	if err := db.Create(&SchemaMigration{Version: 11, Name: "future"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := migrator.Check(); err == nil || !strings.Contains(err.Error(), "database is at version 11") {
+		t.Errorf("Check() = %v; want a newer database error", err)
+	}
+}
+
+// TestSQLiteMigrations applies and reverts every embedded SQLite migration.
+func TestSQLiteMigrations(t *testing.T) {
+	files, err := migrations.For("sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrator, err := New(openDB(t), files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := migrator.Up()
+	if err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+	if len(applied) == 0 || applied[len(applied)-1].Version != migrator.Latest() {
+		t.Errorf("Up() applied %v; want up to %d", versions(applied), migrator.Latest())
+	}
+	if reverted, err := migrator.Down(len(applied)); err != nil || len(reverted) != len(applied) {
+		t.Errorf("Down(%d) = %v, %v; want every migration reverted", len(applied), versions(reverted), err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Errorf("Up() after Down error = %v", err)
+	}
+}
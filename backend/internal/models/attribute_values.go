@@ -0,0 +1,29 @@
+package models
+
+import (
+	"database/sql/driver"
+)
+
+// AttributeValues holds the values of the type specific attributes of a component,
+// keyed by the attribute names declared on its ComponentType. It is stored as JSONB.
+type AttributeValues map[string]interface{}
+
+// Value implements driver.Valuer.
+func (a AttributeValues) Value() (driver.Value, error) {
+	if a == nil {
+		return "{}", nil
+	}
+	return marshalJSON(a)
+}
+
+// Scan implements sql.Scanner.
+func (a *AttributeValues) Scan(value interface{}) error {
+	values := AttributeValues{}
+	if value != nil {
+		if err := scanJSON(value, &values); err != nil {
+			return err
+		}
+	}
+	*a = values
+	return nil
+}
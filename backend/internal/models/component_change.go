@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// ComponentChange records how an edit changed one field of a component. Built-in fields are
+// named by their API name (warrantyEndDate), custom attributes by their own name.
+type ComponentChange struct {
+	ID          int       `json:"id" gorm:"primaryKey"`
+	CreatedAt   time.Time `json:"createdAt"`
+	ComponentID int       `json:"componentId"`
+	// Version is the version of the component the edit produced; the changes of one edit share it.
+	Version  int    `json:"version"`
+	UserID   string `json:"userId"`
+	UserName string `json:"userName"`
+	Field    string `json:"field"`
+	// OldValue and NewValue are nil when the field was or became unset. Dates are given as YYYY-MM-DD.
+	OldValue *string `json:"oldValue"`
+	NewValue *string `json:"newValue"`
+}
+
+func (ComponentChange) TableName() string {
+	return "component_changes"
+}
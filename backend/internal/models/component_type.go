@@ -1,12 +1,88 @@
 package models
 
 import (
-	"github.com/lib/pq"
+	"database/sql/driver"
 )
 
+// AttributeType is the data type of a component type attribute.
+type AttributeType string
+
+const (
+	AttributeString  AttributeType = "string"
+	AttributeInt     AttributeType = "int"
+	AttributeDecimal AttributeType = "decimal"
+	AttributeDate    AttributeType = "date"
+	AttributeBool    AttributeType = "bool"
+	AttributeEnum    AttributeType = "enum"
+)
+
+// AttributeDefinition describes one attribute of a component type.
+type AttributeDefinition struct {
+	Name          string        `json:"name"`
+	Type          AttributeType `json:"type"`
+	Required      bool          `json:"required"`
+	AllowedValues []string      `json:"allowedValues,omitempty"`
+	Unit          string        `json:"unit,omitempty"`
+	Pattern       string        `json:"pattern,omitempty"`
+}
+
+// AttributeSchema is the ordered list of attribute definitions of a component type. It is stored as JSONB.
+type AttributeSchema []AttributeDefinition
+
+// Names returns the attribute names in schema order.
+func (s AttributeSchema) Names() []string {
+	names := make([]string, 0, len(s))
+	for _, definition := range s {
+		names = append(names, definition.Name)
+	}
+	return names
+}
+
+// Find returns the definition of the named attribute.
+func (s AttributeSchema) Find(name string) (AttributeDefinition, bool) {
+	for _, definition := range s {
+		if definition.Name == name {
+			return definition, true
+		}
+	}
+	return AttributeDefinition{}, false
+}
+
+// Value implements driver.Valuer.
+func (s AttributeSchema) Value() (driver.Value, error) {
+	if s == nil {
+		return "[]", nil
+	}
+	return marshalJSON(s)
+}
+
+// Scan implements sql.Scanner.
+func (s *AttributeSchema) Scan(value interface{}) error {
+	schema := AttributeSchema{}
+	if value != nil {
+		if err := scanJSON(value, &schema); err != nil {
+			return err
+		}
+	}
+	*s = schema
+	return nil
+}
+
 type ComponentType struct {
-	ID             int            `json:"id" gorm:"primaryKey"`
-	Name           string         `json:"name"`
-	Attributes     pq.StringArray `json:"-" gorm:"type:text[]"`
-	AttributesList []string       `json:"attributes" gorm:"-"`
+	ID            int             `json:"id" gorm:"primaryKey"`
+	Name          string          `json:"name"`
+	ParentID      *int            `json:"parentId"`
+	Schema        AttributeSchema `json:"attributeSchema" gorm:"column:attribute_schema;type:jsonb"`
+	SchemaVersion int             `json:"schemaVersion" gorm:"default:1"`
+	// EffectiveSchema is the schema including the definitions inherited from ancestor types.
+	EffectiveSchema AttributeSchema `json:"effectiveSchema" gorm:"-"`
+	AttributesList  []string        `json:"attributes" gorm:"-"`
+}
+
+// Definitions returns the effective schema if it has been resolved and the type's own schema otherwise.
+func (t ComponentType) Definitions() AttributeSchema {
+	if t.EffectiveSchema != nil {
+		return t.EffectiveSchema
+	}
+	return t.Schema
 }
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"database/sql/driver"
+	"time"
+)
+
+// SchemaChange summarises how a component type schema differs from its previous version.
+type SchemaChange struct {
+	Added   []string          `json:"added"`
+	Removed []string          `json:"removed"`
+	Renamed map[string]string `json:"renamed"`
+	Retyped []string          `json:"retyped"`
+}
+
+// Value implements driver.Valuer.
+func (c SchemaChange) Value() (driver.Value, error) {
+	return marshalJSON(c)
+}
+
+// Scan implements sql.Scanner.
+func (c *SchemaChange) Scan(value interface{}) error {
+	change := SchemaChange{}
+	if value != nil {
+		if err := scanJSON(value, &change); err != nil {
+			return err
+		}
+	}
+	*c = change
+	return nil
+}
+
+// ComponentTypeSchemaVersion records a component type schema as it was at a given version.
+type ComponentTypeSchemaVersion struct {
+	ID        int             `json:"id" gorm:"primaryKey"`
+	TypeID    int             `json:"typeId"`
+	Version   int             `json:"version"`
+	Name      string          `json:"name"`
+	Schema    AttributeSchema `json:"attributeSchema" gorm:"column:attribute_schema;type:jsonb"`
+	Change    SchemaChange    `json:"change" gorm:"column:change;type:jsonb"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+func (ComponentTypeSchemaVersion) TableName() string {
+	return "component_type_schema_versions"
+}
@@ -7,8 +7,12 @@ type InventoryHistory struct {
 	CreatedAt     time.Time `json:"createdAt"`
 	ComponentID   int       `json:"componentId"`
 	UserID        string    `json:"userId"`
-	OperationType string    `json:"operationType" gorm:"type:inventory_operation_type"`
+	OperationType string    `json:"operationType"`
 	UserName      string    `json:"userName"`
+	// RecordedByID and RecordedByName identify the signed-in user who recorded the entry; UserID
+	// names someone else when they acted on that user's behalf.
+	RecordedByID   string `json:"recordedById"`
+	RecordedByName string `json:"recordedByName"`
 }
 
 func (InventoryHistory) TableName() string {
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// marshalJSON encodes a JSONB column value.
+func marshalJSON(value interface{}) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal %T: %w", value, err)
+	}
+	return string(data), nil
+}
+
+// scanJSON decodes a JSONB column value into dest.
+func scanJSON(value interface{}, dest interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into %T", value, dest)
+	}
+
+	if err := json.Unmarshal(data, dest); err != nil {
+		return fmt.Errorf("failed to unmarshal %T: %w", dest, err)
+	}
+	return nil
+}
@@ -58,8 +58,9 @@ func NotifyExpiringWarranties(db *gorm.DB, cfg config.Config) {
 
 	for _, component := range components {
 		bodyBuilder.WriteString(fmt.Sprintf("Component %s (ID: %d) is expiring on %s.\n", component.SerialNumber, component.ID, component.WarrantyEndDate.Format("2006-01-02")))
-		component.EmailNotified = true
-		if err := db.Save(&component).Error; err != nil {
+		// Only the flag is written, so edits made since the components were read are kept
+		err := db.Model(&component).Updates(map[string]interface{}{"email_notified": true, "version": gorm.Expr("version + 1")}).Error
+		if err != nil {
 			log.Printf("Error updating email_notified for component %d: %v", component.ID, err)
 		}
 	}
@@ -0,0 +1,118 @@
+// Package query declares which component fields API clients may sort and filter on and
+// parses the list parameters that refer to them.
+//
+// Clients only ever name fields; the catalogue maps those names to columns, so nothing a
+// client sends reaches SQL as an identifier unless the catalogue declares it.
+package query
+
+import (
+	"fmt"
+	"strings"
+
+	"vinventory/internal/attributes"
+	"vinventory/internal/models"
+	"vinventory/internal/repository"
+)
+
+// Field is a component field exposed to list requests.
+type Field struct {
+	// Name is the API name of the field (modelYear) and Column its column (model_year).
+	Name       string
+	Column     string
+	Type       models.AttributeType
+	Sortable   bool
+	Filterable bool
+	// Facetable fields have few enough distinct values to count components per value.
+	Facetable bool
+}
+
+var fields = catalogue()
+
+func catalogue() []Field {
+	list := []Field{
+		{Name: "id", Column: "id", Type: models.AttributeInt, Sortable: true},
+		{Name: "typeId", Column: "type_id", Type: models.AttributeInt, Sortable: true, Facetable: true},
+	}
+	for _, definition := range attributes.BuiltIns() {
+		column, _ := attributes.Column(definition.Name)
+		list = append(list, Field{
+			Name:   definition.Name,
+			Column: column,
+			Type:   definition.Type,
+			// Notes are free text; ordering by them is of no use
+			Sortable:   definition.Name != "notes",
+			Filterable: true,
+			Facetable:  facetable(definition),
+		})
+	}
+	return list
+}
+
+// facetable excludes the fields whose values are mostly unique.
+func facetable(definition models.AttributeDefinition) bool {
+	switch definition.Name {
+	case "serialNumber", "notes", "warrantyEndDate":
+		return false
+	}
+	return true
+}
+
+// Fields returns the catalogue of component fields.
+func Fields() []Field {
+	return append([]Field(nil), fields...)
+}
+
+// Lookup returns the field with the given API name. The column name is accepted as well,
+// as older clients send model_year instead of modelYear.
+func Lookup(name string) (Field, bool) {
+	for _, field := range fields {
+		if field.Name == name || field.Column == name {
+			return field, true
+		}
+	}
+	return Field{}, false
+}
+
+// ParseSort parses a comma separated list of fields to sort by. A field prefixed with -
+// is sorted in descending order, for example "brand,-warrantyEndDate".
+//
+// order is the legacy order parameter: "desc" reverses a single unprefixed field.
+func ParseSort(value string, order string) ([]repository.SortKey, error) {
+	if order != "" && order != "asc" && order != "desc" {
+		return nil, fmt.Errorf("order must be asc or desc")
+	}
+	if value == "" {
+		return nil, nil
+	}
+
+	names := strings.Split(value, ",")
+	keys := make([]repository.SortKey, 0, len(names))
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		descending := strings.HasPrefix(name, "-")
+		name = strings.TrimPrefix(name, "-")
+		if name == "" {
+			return nil, fmt.Errorf("sort contains an empty field")
+		}
+
+		field, ok := Lookup(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown sort field %q", name)
+		}
+		if !field.Sortable {
+			return nil, fmt.Errorf("cannot sort by %q", name)
+		}
+		if seen[field.Column] {
+			return nil, fmt.Errorf("sort field %q is given twice", name)
+		}
+		seen[field.Column] = true
+
+		keys = append(keys, repository.SortKey{Column: field.Column, Descending: descending})
+	}
+
+	if order == "desc" && len(names) == 1 && !strings.HasPrefix(strings.TrimSpace(value), "-") {
+		keys[0].Descending = true
+	}
+	return keys, nil
+}
@@ -0,0 +1,73 @@
+package query
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"vinventory/internal/repository"
+)
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		name   string
+		column string
+		ok     bool
+	}{
+		{name: "modelYear", column: "model_year", ok: true},
+		{name: "model_year", column: "model_year", ok: true},
+		{name: "warrantyEndDate", column: "warranty_end_date", ok: true},
+		{name: "ModelYear"},
+		{name: "password"},
+		{name: "model_year; DROP TABLE components"},
+		{name: ""},
+	}
+	for _, test := range tests {
+		field, ok := Lookup(test.name)
+		if ok != test.ok || field.Column != test.column {
+			t.Errorf("Lookup(%q) = %q, %v; want %q, %v", test.name, field.Column, ok, test.column, test.ok)
+		}
+	}
+}
+
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		value string
+		order string
+		want  []repository.SortKey
+		err   string
+	}{
+		{value: "", want: nil},
+		{value: "brand", want: []repository.SortKey{{Column: "brand"}}},
+		{value: "brand,-warrantyEndDate", want: []repository.SortKey{{Column: "brand"}, {Column: "warranty_end_date", Descending: true}}},
+		{value: " ram , -model_year ", want: []repository.SortKey{{Column: "ram"}, {Column: "model_year", Descending: true}}},
+		{value: "brand", order: "desc", want: []repository.SortKey{{Column: "brand", Descending: true}}},
+		// The legacy order only reverses a single unprefixed field
+		{value: "-brand", order: "desc", want: []repository.SortKey{{Column: "brand", Descending: true}}},
+		{value: "brand,ram", order: "desc", want: []repository.SortKey{{Column: "brand"}, {Column: "ram"}}},
+		{value: "brand", order: "sideways", err: "order must be asc or desc"},
+		{value: "brand,", err: "empty field"},
+		{value: "password", err: `unknown sort field "password"`},
+		{value: "brand; DROP TABLE components", err: "unknown sort field"},
+		{value: "(SELECT 1)", err: "unknown sort field"},
+		{value: "notes", err: `cannot sort by "notes"`},
+		{value: "brand,-brand", err: "given twice"},
+		{value: "modelYear,model_year", err: "given twice"},
+	}
+	for _, test := range tests {
+		keys, err := ParseSort(test.value, test.order)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("ParseSort(%q, %q) error = %v; want %q", test.value, test.order, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseSort(%q, %q) error = %v", test.value, test.order, err)
+			continue
+		}
+		if !reflect.DeepEqual(keys, test.want) {
+			t.Errorf("ParseSort(%q, %q) = %+v; want %+v", test.value, test.order, keys, test.want)
+		}
+	}
+}
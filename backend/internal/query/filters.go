@@ -0,0 +1,205 @@
+package query
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"vinventory/internal/attributes"
+	"vinventory/internal/models"
+	"vinventory/internal/repository"
+)
+
+// operatorKey matches filter parameters with an operator, such as ram[gte].
+var operatorKey = regexp.MustCompile(`^(\w+)\[(\w+)\]$`)
+
+// ordered lists the field types the range operators apply to.
+var ordered = map[models.AttributeType]bool{
+	models.AttributeInt:     true,
+	models.AttributeDecimal: true,
+	models.AttributeDate:    true,
+}
+
+// ParseFilters turns the filter parameters of a list request into conditions.
+//
+// A parameter named after a filterable field (ram=16) requires equality. An operator can be
+// given in brackets:
+//
+//	ram[gte]=16                         eq, ne, gt, gte, lt and lte compare with one value
+//	status[in]=Ready to Use,In Repair   in matches any of comma separated values
+//	modelYear[between]=2019,2022        between matches a range, both ends included
+//	notes[isnull]                       isnull matches unset fields, isnull=false set ones
+//
+// Dates are given as YYYY-MM-DD, RFC 3339 or relative to today (see ParseDate).
+//
+// Parameters that name no field are left to the caller, except bracketed ones: an unknown
+// field or operator there is reported as an error. The custom attributes[name] parameters
+// are not filters of this kind and are skipped.
+func ParseFilters(values url.Values) ([]repository.Condition, error) {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	// Sorted so that the first error reported does not depend on map order
+	sort.Strings(keys)
+
+	var conditions []repository.Condition
+	for _, key := range keys {
+		if strings.HasPrefix(key, "attributes[") {
+			continue
+		}
+
+		match := operatorKey.FindStringSubmatch(key)
+		if match == nil && strings.Contains(key, "[") {
+			return nil, fmt.Errorf("invalid filter parameter %q", key)
+		}
+		if match == nil {
+			field, ok := Lookup(key)
+			if !ok || !field.Filterable || values.Get(key) == "" {
+				continue
+			}
+			condition, err := parseCondition(field, repository.Equal, values.Get(key))
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			conditions = append(conditions, condition)
+			continue
+		}
+
+		field, ok := Lookup(match[1])
+		if !ok {
+			return nil, fmt.Errorf("unknown filter field %q", match[1])
+		}
+		if !field.Filterable {
+			return nil, fmt.Errorf("cannot filter by %q", match[1])
+		}
+		for _, value := range values[key] {
+			condition, err := parseCondition(field, repository.Operator(match[2]), value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			conditions = append(conditions, condition)
+		}
+	}
+	return conditions, nil
+}
+
+// parseCondition builds the condition of one filter; in and between take comma separated values.
+func parseCondition(field Field, operator repository.Operator, value string) (repository.Condition, error) {
+	condition := repository.Condition{Column: field.Column, Operator: operator}
+
+	var operands []string
+	switch operator {
+	case repository.Equal, repository.NotEqual:
+		operands = []string{value}
+	case repository.Greater, repository.GreaterOrEqual, repository.Less, repository.LessOrEqual:
+		if !ordered[field.Type] {
+			return condition, fmt.Errorf("%s values cannot be compared", field.Type)
+		}
+		operands = []string{value}
+	case repository.In:
+		operands = strings.Split(value, ",")
+	case repository.Between:
+		if !ordered[field.Type] {
+			return condition, fmt.Errorf("%s values cannot be compared", field.Type)
+		}
+		operands = strings.Split(value, ",")
+		if len(operands) != 2 {
+			return condition, fmt.Errorf("between takes two comma separated values")
+		}
+	case repository.IsNull:
+		// A bare notes[isnull] asks for unset values
+		if value == "" {
+			return condition, nil
+		}
+		null, err := strconv.ParseBool(value)
+		if err != nil {
+			return condition, fmt.Errorf("isnull must be true or false")
+		}
+		if !null {
+			condition.Operator = repository.NotNull
+		}
+		return condition, nil
+	default:
+		return condition, fmt.Errorf("unknown operator %q", operator)
+	}
+
+	for _, operand := range operands {
+		parsed, err := parseValue(field, strings.TrimSpace(operand))
+		if err != nil {
+			return condition, err
+		}
+		condition.Values = append(condition.Values, parsed)
+	}
+	return condition, nil
+}
+
+// relativeDate matches dates relative to today: 90d, 2w, 6m, 1y, -30d.
+var relativeDate = regexp.MustCompile(`^([+-]?\d+)([dwmy])$`)
+
+// ParseDate parses a date given as YYYY-MM-DD, RFC 3339, "today" or relative to today
+// in days, weeks, months or years (90d is 90 days from today, -1y a year ago).
+func ParseDate(value string) (time.Time, error) {
+	year, month, day := time.Now().UTC().Date()
+	today := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+
+	if value == "today" {
+		return today, nil
+	}
+	if match := relativeDate.FindStringSubmatch(value); match != nil {
+		count, err := strconv.Atoi(match[1])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%q is not a date", value)
+		}
+		switch match[2] {
+		case "d":
+			return today.AddDate(0, 0, count), nil
+		case "w":
+			return today.AddDate(0, 0, 7*count), nil
+		case "m":
+			return today.AddDate(0, count, 0), nil
+		}
+		return today.AddDate(count, 0, 0), nil
+	}
+
+	parsed, err := attributes.ParseDate(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not a date (YYYY-MM-DD or a relative date like 90d)", value)
+	}
+	return parsed, nil
+}
+
+// parseValue converts a filter operand to the type of the field.
+func parseValue(field Field, value string) (interface{}, error) {
+	switch field.Type {
+	case models.AttributeInt:
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an integer", value)
+		}
+		return parsed, nil
+	case models.AttributeDecimal:
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", value)
+		}
+		return parsed, nil
+	case models.AttributeDate:
+		parsed, err := ParseDate(value)
+		if err != nil {
+			return nil, err
+		}
+		return parsed, nil
+	case models.AttributeBool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not true or false", value)
+		}
+		return parsed, nil
+	}
+	return value, nil
+}
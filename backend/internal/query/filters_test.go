@@ -0,0 +1,140 @@
+package query
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"vinventory/internal/repository"
+)
+
+func TestParseFilters(t *testing.T) {
+	date := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		query string
+		want  []repository.Condition
+	}{
+		{query: "", want: nil},
+		{query: "brand=Dell", want: []repository.Condition{{Column: "brand", Operator: repository.Equal, Values: []interface{}{"Dell"}}}},
+		{query: "model_year=2021", want: []repository.Condition{{Column: "model_year", Operator: repository.Equal, Values: []interface{}{2021}}}},
+		{query: "ram[gte]=16", want: []repository.Condition{{Column: "ram", Operator: repository.GreaterOrEqual, Values: []interface{}{16}}}},
+		{query: "ram[ne]=8", want: []repository.Condition{{Column: "ram", Operator: repository.NotEqual, Values: []interface{}{8}}}},
+		{query: "warrantyEndDate[lt]=2024-03-01", want: []repository.Condition{{Column: "warranty_end_date", Operator: repository.Less, Values: []interface{}{date}}}},
+		{
+			query: "status[in]=Ready to Use, In Repair",
+			want:  []repository.Condition{{Column: "status", Operator: repository.In, Values: []interface{}{"Ready to Use", "In Repair"}}},
+		},
+		{
+			query: "modelYear[between]=2019,2022",
+			want:  []repository.Condition{{Column: "model_year", Operator: repository.Between, Values: []interface{}{2019, 2022}}},
+		},
+		{query: "notes[isnull]", want: []repository.Condition{{Column: "notes", Operator: repository.IsNull}}},
+		{query: "notes[isnull]=true", want: []repository.Condition{{Column: "notes", Operator: repository.IsNull}}},
+		{query: "notes[isnull]=false", want: []repository.Condition{{Column: "notes", Operator: repository.NotNull}}},
+		{
+			// Repeated operators all apply, and conditions come in parameter order
+			query: "ram[lte]=32&ram[gte]=8&brand=HP",
+			want: []repository.Condition{
+				{Column: "brand", Operator: repository.Equal, Values: []interface{}{"HP"}},
+				{Column: "ram", Operator: repository.GreaterOrEqual, Values: []interface{}{8}},
+				{Column: "ram", Operator: repository.LessOrEqual, Values: []interface{}{32}},
+			},
+		},
+		// Parameters that name no field are left to the caller
+		{query: "search=dell&limit=10&sort=brand&type_id=3&attributes[color]=red", want: nil},
+		{query: "brand=", want: nil},
+		{query: "brand%3BDROP%20TABLE%20components=1", want: nil},
+	}
+	for _, test := range tests {
+		values, err := url.ParseQuery(test.query)
+		if err != nil {
+			t.Fatalf("url.ParseQuery(%q): %v", test.query, err)
+		}
+		conditions, err := ParseFilters(values)
+		if err != nil {
+			t.Errorf("ParseFilters(%q) error = %v", test.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(conditions, test.want) {
+			t.Errorf("ParseFilters(%q) = %+v; want %+v", test.query, conditions, test.want)
+		}
+	}
+}
+
+func TestParseFiltersErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{query: "password[eq]=x", err: `unknown filter field "password"`},
+		{query: "id[eq]=1", err: `cannot filter by "id"`},
+		{query: "ram[like]=16", err: `unknown operator "like"`},
+		{query: "ram[gte]=lots", err: `ram[gte]: "lots" is not an integer`},
+		{query: "ram=lots", err: `ram: "lots" is not an integer`},
+		{query: "brand[gt]=Dell", err: "string values cannot be compared"},
+		{query: "status[between]=a,b", err: "enum values cannot be compared"},
+		{query: "modelYear[between]=2019", err: "between takes two comma separated values"},
+		{query: "modelYear[between]=2019,2020,2021", err: "between takes two comma separated values"},
+		{query: "notes[isnull]=maybe", err: "isnull must be true or false"},
+		{query: "warrantyEndDate[lt]=soon", err: `"soon" is not a date`},
+		// Names that try to smuggle SQL never reach a query
+		{query: "brand%3BDROP%20TABLE%20components[eq]=1", err: "invalid filter parameter"},
+		{query: "brand)%20OR%20(1%3D1[eq]=1", err: "invalid filter parameter"},
+		{query: "ram[gte%3B--]=16", err: "invalid filter parameter"},
+		{query: "ram[gte]]=16", err: "invalid filter parameter"},
+		{query: "pg_sleep[eq]=10", err: `unknown filter field "pg_sleep"`},
+	}
+	for _, test := range tests {
+		values, err := url.ParseQuery(test.query)
+		if err != nil {
+			t.Fatalf("url.ParseQuery(%q): %v", test.query, err)
+		}
+		_, err = ParseFilters(values)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("ParseFilters(%q) error = %v; want %q", test.query, err, test.err)
+		}
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	year, month, day := time.Now().UTC().Date()
+	today := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value string
+		want  time.Time
+		err   bool
+	}{
+		{value: "2024-02-29", want: time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{value: "2024-02-29T10:30:00Z", want: time.Date(2024, 2, 29, 10, 30, 0, 0, time.UTC)},
+		{value: "today", want: today},
+		{value: "90d", want: today.AddDate(0, 0, 90)},
+		{value: "+7d", want: today.AddDate(0, 0, 7)},
+		{value: "-30d", want: today.AddDate(0, 0, -30)},
+		{value: "2w", want: today.AddDate(0, 0, 14)},
+		{value: "6m", want: today.AddDate(0, 6, 0)},
+		{value: "-1y", want: today.AddDate(-1, 0, 0)},
+		{value: "0d", want: today},
+		{value: "", err: true},
+		{value: "tomorrow", err: true},
+		{value: "90", err: true},
+		{value: "90h", err: true},
+		{value: "d", err: true},
+		{value: "2024-02-30", err: true},
+		{value: "29.02.2024", err: true},
+	}
+	for _, test := range tests {
+		date, err := ParseDate(test.value)
+		if test.err {
+			if err == nil {
+				t.Errorf("ParseDate(%q) = %v; want an error", test.value, date)
+			}
+			continue
+		}
+		if err != nil || !date.Equal(test.want) {
+			t.Errorf("ParseDate(%q) = %v, %v; want %v", test.value, date, err, test.want)
+		}
+	}
+}
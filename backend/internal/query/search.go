@@ -0,0 +1,224 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+
+	"vinventory/internal/repository"
+)
+
+// Search is a parsed search box query.
+//
+// A query is a list of space separated terms. A term of the form field:value or
+// field<operator>value restricts a field; any other word or "quoted phrase" is free text:
+//
+//	type:Laptop status:"Being Used" ram>=16 holder:ayse warranty<90d dell
+//
+// Fields are those of the catalogue, by API or column name in any case, and
+//
+//	type       a component type by name, including its sub-types
+//	holder     the user a component is in use by, matched on the display name
+//	warranty   short for warrantyEndDate
+//
+// Any other name is a custom attribute, which only supports equality. The operators are
+// : and = for equality, where an unquoted comma separated value matches any of its items,
+// != for inequality and <, <=, > and >= for numbers and dates. Dates can be relative to
+// today as in ParseDate.
+type Search struct {
+	// Text is the free text, matched against brand, model, serial number and holder.
+	Text       string
+	Conditions []repository.Condition
+	// Types and Holders list the names given to type: and holder:; a component matches any of them.
+	Types   []string
+	Holders []string
+	// Attributes maps custom attribute names to the value they must equal.
+	Attributes map[string]string
+}
+
+// SyntaxError reports a search query that cannot be parsed.
+type SyntaxError struct {
+	// Position is the byte offset of the offending term in the query.
+	Position int
+	Message  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s (at position %d)", e.Message, e.Position)
+}
+
+// searchOperators are tried in order, so two-character operators come first.
+var searchOperators = []string{">=", "<=", "!=", ":", "=", ">", "<"}
+
+var searchOperations = map[string]repository.Operator{
+	":":  repository.Equal,
+	"=":  repository.Equal,
+	"!=": repository.NotEqual,
+	">":  repository.Greater,
+	">=": repository.GreaterOrEqual,
+	"<":  repository.Less,
+	"<=": repository.LessOrEqual,
+}
+
+var searchAliases = map[string]string{
+	"warranty": "warrantyEndDate",
+}
+
+// ParseSearch parses a search box query.
+func ParseSearch(input string) (Search, error) {
+	search := Search{Attributes: map[string]string{}}
+	var text []string
+
+	position := 0
+	for {
+		for position < len(input) && isSpace(input[position]) {
+			position++
+		}
+		if position == len(input) {
+			break
+		}
+		start := position
+
+		if input[position] == '"' {
+			phrase, next, err := quoted(input, position)
+			if err != nil {
+				return Search{}, err
+			}
+			if phrase != "" {
+				text = append(text, phrase)
+			}
+			position = next
+			continue
+		}
+
+		for position < len(input) && isNameByte(input[position]) {
+			position++
+		}
+		name := input[start:position]
+		operator := operatorAt(input, position)
+
+		if operator == "" {
+			// Not a field term: free text up to the next space
+			for position < len(input) && !isSpace(input[position]) {
+				position++
+			}
+			text = append(text, input[start:position])
+			continue
+		}
+		if name == "" {
+			return Search{}, &SyntaxError{Position: start, Message: fmt.Sprintf("missing field name before %s", operator)}
+		}
+
+		position += len(operator)
+		var value string
+		isQuoted := position < len(input) && input[position] == '"'
+		if isQuoted {
+			var err error
+			value, position, err = quoted(input, position)
+			if err != nil {
+				return Search{}, err
+			}
+		} else {
+			valueStart := position
+			for position < len(input) && !isSpace(input[position]) {
+				position++
+			}
+			value = input[valueStart:position]
+		}
+		if value == "" {
+			return Search{}, &SyntaxError{Position: start, Message: fmt.Sprintf("missing value after %s%s", name, operator)}
+		}
+
+		if err := search.add(name, operator, value, isQuoted); err != nil {
+			return Search{}, &SyntaxError{Position: start, Message: fmt.Sprintf("%s: %s", input[start:position], err)}
+		}
+	}
+
+	search.Text = strings.Join(text, " ")
+	return search, nil
+}
+
+// add records one field term.
+func (s *Search) add(name string, operator string, value string, isQuoted bool) error {
+	equality := operator == ":" || operator == "="
+	items := []string{value}
+	if !isQuoted {
+		items = strings.Split(value, ",")
+	}
+
+	switch strings.ToLower(name) {
+	case "type":
+		if !equality {
+			return fmt.Errorf("type only supports :")
+		}
+		s.Types = append(s.Types, items...)
+		return nil
+	case "holder":
+		if !equality {
+			return fmt.Errorf("holder only supports :")
+		}
+		s.Holders = append(s.Holders, items...)
+		return nil
+	}
+
+	if alias, ok := searchAliases[strings.ToLower(name)]; ok {
+		name = alias
+	}
+	field, ok := lookupFold(name)
+	if !ok {
+		if !equality {
+			return fmt.Errorf("custom attributes only support :")
+		}
+		s.Attributes[name] = value
+		return nil
+	}
+	if !field.Filterable {
+		return fmt.Errorf("cannot search by %s", field.Name)
+	}
+
+	operation := searchOperations[operator]
+	if equality && len(items) > 1 {
+		operation = repository.In
+	}
+	condition, err := parseCondition(field, operation, value)
+	if err != nil {
+		return err
+	}
+	s.Conditions = append(s.Conditions, condition)
+	return nil
+}
+
+// lookupFold is Lookup ignoring case, as search terms are typed by hand.
+func lookupFold(name string) (Field, bool) {
+	for _, field := range fields {
+		if strings.EqualFold(field.Name, name) || strings.EqualFold(field.Column, name) {
+			return field, true
+		}
+	}
+	return Field{}, false
+}
+
+// quoted reads the phrase of the quote starting at position and returns it with the position after the closing quote.
+func quoted(input string, position int) (string, int, error) {
+	end := strings.IndexByte(input[position+1:], '"')
+	if end < 0 {
+		return "", 0, &SyntaxError{Position: position, Message: "unterminated quote"}
+	}
+	return input[position+1 : position+1+end], position + end + 2, nil
+}
+
+func operatorAt(input string, position int) string {
+	for _, operator := range searchOperators {
+		if strings.HasPrefix(input[position:], operator) {
+			return operator
+		}
+	}
+	return ""
+}
+
+func isNameByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
@@ -0,0 +1,120 @@
+package query
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"vinventory/internal/repository"
+)
+
+func TestParseSearch(t *testing.T) {
+	year, month, day := time.Now().UTC().Date()
+	today := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		input string
+		want  Search
+	}{
+		{input: "", want: Search{}},
+		{input: "  dell   latitude ", want: Search{Text: "dell latitude"}},
+		{input: `"Being Used" dell`, want: Search{Text: "Being Used dell"}},
+		{input: `""`, want: Search{}},
+		{
+			input: `type:Laptop status:"Being Used" ram>=16 holder:ayse warranty<90d dell`,
+			want: Search{
+				Text: "dell",
+				Conditions: []repository.Condition{
+					{Column: "status", Operator: repository.Equal, Values: []interface{}{"Being Used"}},
+					{Column: "ram", Operator: repository.GreaterOrEqual, Values: []interface{}{16}},
+					{Column: "warranty_end_date", Operator: repository.Less, Values: []interface{}{today.AddDate(0, 0, 90)}},
+				},
+				Types:   []string{"Laptop"},
+				Holders: []string{"ayse"},
+			},
+		},
+		{
+			// An unquoted comma separated value matches any of its items
+			input: "brand:Dell,HP type=Laptop,Desktop holder:ayse,mehmet",
+			want: Search{
+				Conditions: []repository.Condition{{Column: "brand", Operator: repository.In, Values: []interface{}{"Dell", "HP"}}},
+				Types:      []string{"Laptop", "Desktop"},
+				Holders:    []string{"ayse", "mehmet"},
+			},
+		},
+		{
+			// A quoted value is taken as a whole
+			input: `brand:"Dell,HP" holder:"Ayşe Yılmaz"`,
+			want: Search{
+				Conditions: []repository.Condition{{Column: "brand", Operator: repository.Equal, Values: []interface{}{"Dell,HP"}}},
+				Holders:    []string{"Ayşe Yılmaz"},
+			},
+		},
+		{
+			// Field names are matched ignoring case, by API or column name
+			input: "RAM>8 model_year!=2020 ModelYear<=2023 Warranty>=2024-01-31",
+			want: Search{
+				Conditions: []repository.Condition{
+					{Column: "ram", Operator: repository.Greater, Values: []interface{}{8}},
+					{Column: "model_year", Operator: repository.NotEqual, Values: []interface{}{2020}},
+					{Column: "model_year", Operator: repository.LessOrEqual, Values: []interface{}{2023}},
+					{Column: "warranty_end_date", Operator: repository.GreaterOrEqual, Values: []interface{}{time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)}},
+				},
+			},
+		},
+		{input: "color:red gpu=rtx", want: Search{Attributes: map[string]string{"color": "red", "gpu": "rtx"}}},
+		{input: `color:"dark blue"`, want: Search{Attributes: map[string]string{"color": "dark blue"}}},
+		{input: "warranty<-1y", want: Search{Conditions: []repository.Condition{
+			{Column: "warranty_end_date", Operator: repository.Less, Values: []interface{}{today.AddDate(-1, 0, 0)}},
+		}}},
+		// Words that only contain operator characters stay free text
+		{input: "usb-c 15.6", want: Search{Text: "usb-c 15.6"}},
+	}
+	for _, test := range tests {
+		search, err := ParseSearch(test.input)
+		if err != nil {
+			t.Errorf("ParseSearch(%q) error = %v", test.input, err)
+			continue
+		}
+		if test.want.Attributes == nil {
+			test.want.Attributes = map[string]string{}
+		}
+		if !reflect.DeepEqual(search, test.want) {
+			t.Errorf("ParseSearch(%q) = %+v; want %+v", test.input, search, test.want)
+		}
+	}
+}
+
+func TestParseSearchErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		position int
+		message  string
+	}{
+		{input: `dell "unterminated`, position: 5, message: "unterminated quote"},
+		{input: `brand:"Dell`, position: 6, message: "unterminated quote"},
+		{input: "dell :laptop", position: 5, message: "missing field name before :"},
+		{input: "dell brand:", position: 5, message: "missing value after brand:"},
+		{input: `brand:""`, position: 0, message: "missing value after brand:"},
+		{input: "type>Laptop", position: 0, message: "type only supports :"},
+		{input: "holder!=ayse", position: 0, message: "holder only supports :"},
+		{input: "color>=3", position: 0, message: "custom attributes only support :"},
+		{input: "brand>Dell", position: 0, message: "string values cannot be compared"},
+		{input: "id:3", position: 0, message: "cannot search by id"},
+		{input: "x ram>=lots", position: 2, message: `"lots" is not an integer`},
+		{input: "warranty<soon", position: 0, message: `"soon" is not a date`},
+	}
+	for _, test := range tests {
+		_, err := ParseSearch(test.input)
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("ParseSearch(%q) error = %v; want a *SyntaxError", test.input, err)
+			continue
+		}
+		if syntaxErr.Position != test.position || !strings.Contains(syntaxErr.Message, test.message) {
+			t.Errorf("ParseSearch(%q) error = %q at %d; want %q at %d", test.input, syntaxErr.Message, syntaxErr.Position, test.message, test.position)
+		}
+	}
+}
@@ -0,0 +1,808 @@
+// Package memory implements the repositories in process memory.
+//
+// It mirrors the behaviour of sqlstore closely enough to exercise handlers and services
+// without a database: IDs are assigned on create, serial numbers and type names are
+// unique and a failed transaction leaves the store untouched.
+package memory
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"vinventory/internal/attributes"
+	"vinventory/internal/lifecycle"
+	"vinventory/internal/models"
+	"vinventory/internal/repository"
+)
+
+type state struct {
+	components map[int]models.Component
+	types      map[int]models.ComponentType
+	versions   []models.ComponentTypeSchemaVersion
+	history    []models.InventoryHistory
+	changes    []models.ComponentChange
+	lastID     map[string]int
+}
+
+func (s *state) clone() *state {
+	clone := &state{
+		components: make(map[int]models.Component, len(s.components)),
+		types:      make(map[int]models.ComponentType, len(s.types)),
+		versions:   append([]models.ComponentTypeSchemaVersion(nil), s.versions...),
+		history:    append([]models.InventoryHistory(nil), s.history...),
+		changes:    append([]models.ComponentChange(nil), s.changes...),
+		lastID:     make(map[string]int, len(s.lastID)),
+	}
+	for id, component := range s.components {
+		clone.components[id] = copyComponent(component)
+	}
+	for id, componentType := range s.types {
+		clone.types[id] = componentType
+	}
+	for table, id := range s.lastID {
+		clone.lastID[table] = id
+	}
+	return clone
+}
+
+func (s *state) nextID(table string) int {
+	s.lastID[table]++
+	return s.lastID[table]
+}
+
+// Store is a repository.Store kept in memory. The zero value is not usable; use New.
+type Store struct {
+	mu   *sync.Mutex
+	data *state
+	// inTransaction is set on the store handed to a transaction, which already holds mu.
+	inTransaction bool
+}
+
+// New returns an empty store.
+func New() *Store {
+	return &Store{
+		mu: &sync.Mutex{},
+		data: &state{
+			components: make(map[int]models.Component),
+			types:      make(map[int]models.ComponentType),
+			lastID:     make(map[string]int),
+		},
+	}
+}
+
+// Components returns the component repository.
+func (s *Store) Components() repository.Components { return components{s} }
+
+// Types returns the component type repository.
+func (s *Store) Types() repository.Types { return types{s} }
+
+// History returns the inventory history repository.
+func (s *Store) History() repository.History { return history{s} }
+
+// Transaction runs fn while holding the store exclusively and restores the previous
+// contents if fn returns an error. Nested transactions join the outer one.
+func (s *Store) Transaction(fn func(tx repository.Store) error) error {
+	if s.inTransaction {
+		return fn(s)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := s.data.clone()
+	if err := fn(&Store{mu: s.mu, data: s.data, inTransaction: true}); err != nil {
+		*s.data = *snapshot
+		return err
+	}
+	return nil
+}
+
+// with runs fn on the data, locking the store unless a transaction already holds it.
+func (s *Store) with(fn func(data *state) error) error {
+	if !s.inTransaction {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+	}
+	return fn(s.data)
+}
+
+type components struct{ store *Store }
+
+func (r components) List(filter repository.ComponentFilter) ([]models.Component, error) {
+	result, err := r.filtered(filter)
+
+	sort.SliceStable(result, func(i, j int) bool {
+		for _, key := range filter.Sort {
+			order := compare(columnValue(result[i], key.Column), columnValue(result[j], key.Column))
+			if key.Descending {
+				order = -order
+			}
+			if order != 0 {
+				return order < 0
+			}
+		}
+		return result[i].ID < result[j].ID
+	})
+
+	if filter.Offset > 0 {
+		result = result[min(filter.Offset, len(result)):]
+	}
+	if filter.Limit > 0 {
+		result = result[:min(filter.Limit, len(result))]
+	}
+	return result, err
+}
+
+func (r components) Count(filter repository.ComponentFilter) (int64, error) {
+	result, err := r.filtered(filter)
+	return int64(len(result)), err
+}
+
+// filtered returns copies of the components that match filter, in no particular order.
+func (r components) filtered(filter repository.ComponentFilter) ([]models.Component, error) {
+	var result []models.Component
+	err := r.store.with(func(data *state) error {
+		holders := latestHolders(data)
+		for _, component := range data.components {
+			if matches(data, component, filter, holders) {
+				result = append(result, copyComponent(component))
+			}
+		}
+		return nil
+	})
+	return result, err
+}
+
+func (r components) SerialNumbers(prefix string, limit int) ([]string, error) {
+	var values []string
+	err := r.store.with(func(data *state) error {
+		for _, component := range data.components {
+			if hasPrefixFold(component.SerialNumber, prefix) {
+				values = append(values, component.SerialNumber)
+			}
+		}
+		return nil
+	})
+	sort.Strings(values)
+	return values[:min(limit, len(values))], err
+}
+
+func (r components) BrandModels(prefix string, limit int) ([]repository.BrandModel, error) {
+	seen := make(map[repository.BrandModel]bool)
+	var pairs []repository.BrandModel
+	err := r.store.with(func(data *state) error {
+		for _, component := range data.components {
+			pair := repository.BrandModel{Brand: component.Brand, Model: component.Model}
+			matched := hasPrefixFold(pair.Brand, prefix) || hasPrefixFold(pair.Model, prefix) ||
+				hasPrefixFold(pair.Brand+" "+pair.Model, prefix)
+			if matched && !seen[pair] {
+				seen[pair] = true
+				pairs = append(pairs, pair)
+			}
+		}
+		return nil
+	})
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Brand != pairs[j].Brand {
+			return pairs[i].Brand < pairs[j].Brand
+		}
+		return pairs[i].Model < pairs[j].Model
+	})
+	return pairs[:min(limit, len(pairs))], err
+}
+
+func (r components) Facets(filter repository.ComponentFilter, columns []string) (repository.Facets, error) {
+	matching, err := r.filtered(filter)
+	if err != nil {
+		return repository.Facets{}, err
+	}
+
+	facets := repository.Facets{Total: int64(len(matching)), Counts: make(map[string][]repository.FacetCount, len(columns))}
+	for _, column := range columns {
+		components, err := r.filtered(filter.Without(column))
+		if err != nil {
+			return repository.Facets{}, err
+		}
+
+		var unset int64
+		counts := make(map[string]int64)
+		for _, component := range components {
+			if value := columnValue(component, column); value != nil {
+				counts[fmt.Sprint(value)]++
+			} else {
+				unset++
+			}
+		}
+		for value, count := range counts {
+			facets.Counts[column] = append(facets.Counts[column], repository.FacetCount{Value: &value, Count: count})
+		}
+		if unset > 0 {
+			facets.Counts[column] = append(facets.Counts[column], repository.FacetCount{Count: unset})
+		}
+	}
+	return facets, nil
+}
+
+// Highlights returns none; the memory store has no full-text search.
+func (r components) Highlights(ids []int, search string) (map[int]string, error) {
+	return nil, nil
+}
+
+func (r components) ListByType(typeID int) ([]models.Component, error) {
+	return r.List(repository.ComponentFilter{TypeIDs: []int{typeID}})
+}
+
+// ListByTypeForUpdate is ListByType; transactions already hold the whole store.
+func (r components) ListByTypeForUpdate(typeID int) ([]models.Component, error) {
+	return r.ListByType(typeID)
+}
+
+func (r components) CountByType(typeID int) (int64, error) {
+	list, err := r.ListByType(typeID)
+	return int64(len(list)), err
+}
+
+func (r components) Get(id int) (models.Component, error) {
+	var component models.Component
+	err := r.store.with(func(data *state) error {
+		stored, ok := data.components[id]
+		if !ok {
+			return repository.ErrNotFound
+		}
+		component = copyComponent(stored)
+		return nil
+	})
+	return component, err
+}
+
+// GetForUpdate is Get; transactions already hold the whole store.
+func (r components) GetForUpdate(id int) (models.Component, error) {
+	return r.Get(id)
+}
+
+func (r components) Create(component *models.Component) error {
+	return r.store.with(func(data *state) error {
+		if err := checkComponent(data, *component); err != nil {
+			return err
+		}
+		if component.Status == "" {
+			component.Status = lifecycle.Current().Initial
+		}
+		if component.Attributes == nil {
+			component.Attributes = models.AttributeValues{}
+		}
+		component.ID = data.nextID("components")
+		component.Version = 1
+		data.components[component.ID] = copyComponent(*component)
+		return nil
+	})
+}
+
+func (r components) Save(component *models.Component) error {
+	return r.store.with(func(data *state) error {
+		if err := checkComponent(data, *component); err != nil {
+			return err
+		}
+		stored, ok := data.components[component.ID]
+		if !ok || stored.Version != component.Version {
+			return repository.ErrVersionConflict
+		}
+		component.Version++
+		data.components[component.ID] = copyComponent(*component)
+		return nil
+	})
+}
+
+func (r components) UpdateStatus(id int, status string) error {
+	return r.update(id, func(component *models.Component) {
+		component.Status = status
+	})
+}
+
+func (r components) UpdateAttributes(id int, values models.AttributeValues) error {
+	return r.update(id, func(component *models.Component) {
+		component.Attributes = copyValues(values)
+	})
+}
+
+func (r components) ChangeType(id int, typeID int, values models.AttributeValues) error {
+	return r.update(id, func(component *models.Component) {
+		component.TypeID = typeID
+		component.Attributes = copyValues(values)
+	})
+}
+
+// update changes a stored component; like an SQL UPDATE it does nothing if the component does not exist.
+func (r components) update(id int, change func(component *models.Component)) error {
+	return r.store.with(func(data *state) error {
+		if component, ok := data.components[id]; ok {
+			change(&component)
+			component.Version++
+			data.components[id] = component
+		}
+		return nil
+	})
+}
+
+func (r components) DistinctColumnValues(column string) ([]interface{}, error) {
+	var values []interface{}
+	err := r.store.with(func(data *state) error {
+		seen := make(map[string]bool)
+		for _, component := range data.components {
+			value := attributes.Value(component, column)
+			key := fmt.Sprint(value)
+			if value != nil && !seen[key] {
+				seen[key] = true
+				values = append(values, value)
+			}
+		}
+		return nil
+	})
+	sort.Slice(values, func(i, j int) bool { return compare(values[i], values[j]) < 0 })
+	return values, err
+}
+
+func (r components) DistinctAttributeValues(name string) ([]string, error) {
+	var values []string
+	err := r.store.with(func(data *state) error {
+		seen := make(map[string]bool)
+		for _, component := range data.components {
+			value, ok := component.Attributes[name]
+			if !ok || value == nil {
+				continue
+			}
+			text := fmt.Sprint(value)
+			if !seen[text] {
+				seen[text] = true
+				values = append(values, text)
+			}
+		}
+		return nil
+	})
+	sort.Strings(values)
+	return values, err
+}
+
+type types struct{ store *Store }
+
+func (r types) List() ([]models.ComponentType, error) {
+	var result []models.ComponentType
+	err := r.store.with(func(data *state) error {
+		for _, componentType := range data.types {
+			result = append(result, componentType)
+		}
+		return nil
+	})
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result, err
+}
+
+func (r types) Get(id int) (models.ComponentType, error) {
+	var componentType models.ComponentType
+	err := r.store.with(func(data *state) error {
+		stored, ok := data.types[id]
+		if !ok {
+			return repository.ErrNotFound
+		}
+		componentType = stored
+		return nil
+	})
+	return componentType, err
+}
+
+// GetForUpdate returns the types; transactions already hold the whole store.
+func (r types) GetForUpdate(ids ...int) ([]models.ComponentType, error) {
+	var result []models.ComponentType
+	err := r.store.with(func(data *state) error {
+		seen := make(map[int]bool)
+		for _, id := range ids {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			componentType, ok := data.types[id]
+			if !ok {
+				return repository.ErrNotFound
+			}
+			result = append(result, componentType)
+		}
+		return nil
+	})
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result, err
+}
+
+func (r types) Create(componentType *models.ComponentType) error {
+	return r.store.with(func(data *state) error {
+		if err := checkType(data, *componentType); err != nil {
+			return err
+		}
+		if componentType.SchemaVersion == 0 {
+			componentType.SchemaVersion = 1
+		}
+		componentType.ID = data.nextID("component_types")
+		data.types[componentType.ID] = *componentType
+		return nil
+	})
+}
+
+func (r types) Save(componentType *models.ComponentType) error {
+	return r.store.with(func(data *state) error {
+		if err := checkType(data, *componentType); err != nil {
+			return err
+		}
+		if componentType.ID == 0 {
+			componentType.ID = data.nextID("component_types")
+		}
+		data.types[componentType.ID] = *componentType
+		return nil
+	})
+}
+
+func (r types) Delete(id int) error {
+	return r.store.with(func(data *state) error {
+		for _, component := range data.components {
+			if component.TypeID == id {
+				return fmt.Errorf("component type %d is referenced by component %d", id, component.ID)
+			}
+		}
+		for _, componentType := range data.types {
+			if componentType.ParentID != nil && *componentType.ParentID == id {
+				return fmt.Errorf("component type %d is the parent of component type %d", id, componentType.ID)
+			}
+		}
+		delete(data.types, id)
+
+		// Schema versions are removed along with their type
+		versions := data.versions[:0]
+		for _, version := range data.versions {
+			if version.TypeID != id {
+				versions = append(versions, version)
+			}
+		}
+		data.versions = versions
+		return nil
+	})
+}
+
+func (r types) CountChildren(id int) (int64, error) {
+	var count int64
+	err := r.store.with(func(data *state) error {
+		for _, componentType := range data.types {
+			if componentType.ParentID != nil && *componentType.ParentID == id {
+				count++
+			}
+		}
+		return nil
+	})
+	return count, err
+}
+
+func (r types) AddSchemaVersion(version *models.ComponentTypeSchemaVersion) error {
+	return r.store.with(func(data *state) error {
+		version.ID = data.nextID("component_type_schema_versions")
+		if version.CreatedAt.IsZero() {
+			version.CreatedAt = time.Now()
+		}
+		data.versions = append(data.versions, *version)
+		return nil
+	})
+}
+
+func (r types) SchemaVersions(typeID int) ([]models.ComponentTypeSchemaVersion, error) {
+	var versions []models.ComponentTypeSchemaVersion
+	err := r.store.with(func(data *state) error {
+		for _, version := range data.versions {
+			if version.TypeID == typeID {
+				versions = append(versions, version)
+			}
+		}
+		return nil
+	})
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Version > versions[j].Version })
+	return versions, err
+}
+
+type history struct{ store *Store }
+
+func (r history) Create(entry *models.InventoryHistory) error {
+	return r.store.with(func(data *state) error {
+		if _, ok := data.components[entry.ComponentID]; !ok {
+			return fmt.Errorf("component %d does not exist", entry.ComponentID)
+		}
+		entry.ID = data.nextID("inventory_history")
+		if entry.CreatedAt.IsZero() {
+			entry.CreatedAt = time.Now()
+		}
+		data.history = append(data.history, *entry)
+		return nil
+	})
+}
+
+func (r history) ListByComponent(componentID int) ([]models.InventoryHistory, error) {
+	return r.list(func(entry models.InventoryHistory) bool { return entry.ComponentID == componentID })
+}
+
+func (r history) ListByUser(userID string) ([]models.InventoryHistory, error) {
+	return r.list(func(entry models.InventoryHistory) bool { return entry.UserID == userID })
+}
+
+func (r history) Latest(componentID int) (models.InventoryHistory, error) {
+	entries, err := r.ListByComponent(componentID)
+	if err != nil {
+		return models.InventoryHistory{}, err
+	}
+	if len(entries) == 0 {
+		return models.InventoryHistory{}, repository.ErrNotFound
+	}
+	return latest(entries), nil
+}
+
+func (r history) CreateChanges(changes []models.ComponentChange) error {
+	return r.store.with(func(data *state) error {
+		for i := range changes {
+			if _, ok := data.components[changes[i].ComponentID]; !ok {
+				return fmt.Errorf("component %d does not exist", changes[i].ComponentID)
+			}
+			changes[i].ID = data.nextID("component_changes")
+			if changes[i].CreatedAt.IsZero() {
+				changes[i].CreatedAt = time.Now()
+			}
+			data.changes = append(data.changes, changes[i])
+		}
+		return nil
+	})
+}
+
+func (r history) ListChanges(componentID int) ([]models.ComponentChange, error) {
+	var changes []models.ComponentChange
+	err := r.store.with(func(data *state) error {
+		for _, change := range data.changes {
+			if change.ComponentID == componentID {
+				changes = append(changes, change)
+			}
+		}
+		return nil
+	})
+	return changes, err
+}
+
+func (r history) list(keep func(entry models.InventoryHistory) bool) ([]models.InventoryHistory, error) {
+	var entries []models.InventoryHistory
+	err := r.store.with(func(data *state) error {
+		for _, entry := range data.history {
+			if keep(entry) {
+				entries = append(entries, entry)
+			}
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// latest returns the entry created last, preferring the later insert on equal timestamps.
+func latest(entries []models.InventoryHistory) models.InventoryHistory {
+	result := entries[0]
+	for _, entry := range entries[1:] {
+		if !entry.CreatedAt.Before(result.CreatedAt) {
+			result = entry
+		}
+	}
+	return result
+}
+
+// latestHolders maps each component to its latest history entry.
+func latestHolders(data *state) map[int]models.InventoryHistory {
+	byComponent := make(map[int][]models.InventoryHistory)
+	for _, entry := range data.history {
+		byComponent[entry.ComponentID] = append(byComponent[entry.ComponentID], entry)
+	}
+	holders := make(map[int]models.InventoryHistory, len(byComponent))
+	for componentID, entries := range byComponent {
+		holders[componentID] = latest(entries)
+	}
+	return holders
+}
+
+func matches(data *state, component models.Component, filter repository.ComponentFilter, holders map[int]models.InventoryHistory) bool {
+	for _, condition := range filter.Conditions {
+		if !holds(columnValue(component, condition.Column), condition) {
+			return false
+		}
+	}
+	if filter.TypeIDs != nil && !containsInt(filter.TypeIDs, component.TypeID) {
+		return false
+	}
+	for name, want := range filter.Attributes {
+		value, ok := component.Attributes[name]
+		if !ok || value == nil || fmt.Sprint(value) != want {
+			return false
+		}
+	}
+
+	if filter.Holders != nil {
+		holder, ok := holders[component.ID]
+		if !lifecycle.Current().IsInUse(component.Status) || !ok || !containsString(filter.Holders, holder.UserID) {
+			return false
+		}
+	}
+
+	if filter.Search != "" {
+		search := strings.ToLower(filter.Search)
+		found := false
+		for _, text := range []string{component.Brand, component.Model, component.SerialNumber, component.Notes, data.types[component.TypeID].Name} {
+			found = found || strings.Contains(strings.ToLower(text), search)
+		}
+		if !found && lifecycle.Current().IsInUse(component.Status) {
+			holder, ok := holders[component.ID]
+			found = ok && strings.Contains(strings.ToLower(holder.UserName), search)
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// holds reports whether a column value satisfies a condition; unset values only satisfy IsNull.
+func holds(value interface{}, condition repository.Condition) bool {
+	unset := value == nil || value == ""
+	switch condition.Operator {
+	case repository.IsNull:
+		return unset
+	case repository.NotNull:
+		return !unset
+	}
+	if value == nil {
+		return false
+	}
+
+	values := condition.Values
+	switch condition.Operator {
+	case repository.NotEqual:
+		return compare(value, values[0]) != 0
+	case repository.Greater:
+		return compare(value, values[0]) > 0
+	case repository.GreaterOrEqual:
+		return compare(value, values[0]) >= 0
+	case repository.Less:
+		return compare(value, values[0]) < 0
+	case repository.LessOrEqual:
+		return compare(value, values[0]) <= 0
+	case repository.In:
+		for _, want := range values {
+			if compare(value, want) == 0 {
+				return true
+			}
+		}
+		return false
+	case repository.Between:
+		return compare(value, values[0]) >= 0 && compare(value, values[1]) <= 0
+	}
+	return compare(value, values[0]) == 0
+}
+
+func hasPrefixFold(s string, prefix string) bool {
+	return strings.HasPrefix(strings.ToLower(s), strings.ToLower(prefix))
+}
+
+// columnValue returns the value of a column of a component, or nil when it is unset.
+func columnValue(component models.Component, column string) interface{} {
+	switch column {
+	case "id":
+		return component.ID
+	case "type_id":
+		return component.TypeID
+	}
+	return attributes.Value(component, column)
+}
+
+// compare orders two attribute values of the same kind; unset values sort last as in Postgres.
+func compare(a, b interface{}) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+
+	switch x := a.(type) {
+	case int:
+		if y, ok := b.(int); ok {
+			return x - y
+		}
+	case float64:
+		if y, ok := b.(float64); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	case time.Time:
+		if y, ok := b.(time.Time); ok {
+			return x.Compare(y)
+		}
+	case bool:
+		if y, ok := b.(bool); ok && x != y {
+			if !x {
+				return -1
+			}
+			return 1
+		}
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+// checkComponent enforces the constraints the database declares on components.
+func checkComponent(data *state, component models.Component) error {
+	if _, ok := data.types[component.TypeID]; !ok {
+		return fmt.Errorf("component type %d does not exist", component.TypeID)
+	}
+	if component.SerialNumber == "" {
+		return nil
+	}
+	for id, existing := range data.components {
+		if id != component.ID && existing.SerialNumber == component.SerialNumber {
+			return fmt.Errorf("serial number %q is already in use", component.SerialNumber)
+		}
+	}
+	return nil
+}
+
+// checkType enforces the constraints the database declares on component types.
+func checkType(data *state, componentType models.ComponentType) error {
+	for id, existing := range data.types {
+		if id != componentType.ID && existing.Name == componentType.Name {
+			return fmt.Errorf("component type name %q is already in use", componentType.Name)
+		}
+	}
+	if componentType.ParentID != nil {
+		if _, ok := data.types[*componentType.ParentID]; !ok {
+			return fmt.Errorf("parent component type %d does not exist", *componentType.ParentID)
+		}
+	}
+	return nil
+}
+
+func copyComponent(component models.Component) models.Component {
+	component.Attributes = copyValues(component.Attributes)
+	return component
+}
+
+func copyValues(values models.AttributeValues) models.AttributeValues {
+	if values == nil {
+		return nil
+	}
+	copied := make(models.AttributeValues, len(values))
+	for name, value := range values {
+		copied[name] = value
+	}
+	return copied
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
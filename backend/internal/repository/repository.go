@@ -0,0 +1,194 @@
+// Package repository declares the storage interfaces the API works against.
+//
+// Handlers and services only see these interfaces; sqlstore implements them on a
+// gorm database and memory keeps everything in process, which lets handler behaviour
+// be exercised without a database.
+package repository
+
+import (
+	"errors"
+
+	"vinventory/internal/models"
+)
+
+// ErrNotFound is returned when a requested record does not exist.
+var ErrNotFound = errors.New("record not found")
+
+// ErrVersionConflict is returned when a record has been changed since the version being saved was read.
+var ErrVersionConflict = errors.New("record has been changed by someone else")
+
+// ComponentFilter selects components for Components.List. Empty fields do not filter.
+type ComponentFilter struct {
+	// Conditions restrict built-in columns (brand, model_year...); all of them must hold.
+	Conditions []Condition
+	// TypeIDs limits the result to components of the given types.
+	TypeIDs []int
+	// Attributes maps custom attribute names to the value they must equal.
+	Attributes map[string]string
+	// Search matches the words of a free text against the serial number, brand, model, type
+	// name, notes and the name of the current holder. On Postgres words match as prefixes and,
+	// unless Sort is given, the most relevant components come first; other stores match the
+	// whole text as a case-insensitive substring.
+	Search string
+	// Holders limits the result to components in use by one of the given users. Nil does
+	// not filter, while an empty list matches nothing.
+	Holders []string
+	// Sort lists the columns to order by, most significant first. Components with equal
+	// sort values are ordered by ID, so pages of the same filter never overlap.
+	Sort []SortKey
+	// Limit caps the number of components returned, 0 meaning no limit; Offset skips the first ones.
+	Limit  int
+	Offset int
+}
+
+// Without returns a copy of the filter that no longer restricts column.
+func (f ComponentFilter) Without(column string) ComponentFilter {
+	conditions := make([]Condition, 0, len(f.Conditions))
+	for _, condition := range f.Conditions {
+		if condition.Column != column {
+			conditions = append(conditions, condition)
+		}
+	}
+	f.Conditions = conditions
+	if column == "type_id" {
+		f.TypeIDs = nil
+	}
+	return f
+}
+
+// Operator compares a column with the values of a Condition.
+type Operator string
+
+const (
+	Equal          Operator = "eq"
+	NotEqual       Operator = "ne"
+	Greater        Operator = "gt"
+	GreaterOrEqual Operator = "gte"
+	Less           Operator = "lt"
+	LessOrEqual    Operator = "lte"
+	// In matches any of the values.
+	In Operator = "in"
+	// Between matches the range from the first to the second value, both included.
+	Between Operator = "between"
+	// IsNull matches unset columns, which for text columns includes the empty string; NotNull is its opposite.
+	IsNull  Operator = "isnull"
+	NotNull Operator = "notnull"
+)
+
+// Condition restricts one column. Values hold the operands typed like the column
+// (int, float64, time.Time, bool or string); IsNull and NotNull take none.
+type Condition struct {
+	Column   string
+	Operator Operator
+	Values   []interface{}
+}
+
+// SortKey orders components by one column.
+type SortKey struct {
+	Column     string
+	Descending bool
+}
+
+// FacetCount is the number of components having one value of a column; Value is nil
+// for components where the column is unset.
+type FacetCount struct {
+	Value *string
+	Count int64
+}
+
+// Facets holds the result of Components.Facets.
+type Facets struct {
+	// Total is the number of components matching the whole filter.
+	Total int64
+	// Counts maps each requested column to the counts of its values.
+	Counts map[string][]FacetCount
+}
+
+// BrandModel is a brand and model pair of components.
+type BrandModel struct {
+	Brand string
+	Model string
+}
+
+// Components stores components.
+type Components interface {
+	List(filter ComponentFilter) ([]models.Component, error)
+	// Count returns the number of components matching filter, ignoring its limit and offset.
+	Count(filter ComponentFilter) (int64, error)
+	// ListByType returns the components of a type ordered by ID.
+	ListByType(typeID int) ([]models.Component, error)
+	// ListByTypeForUpdate is ListByType, locking the components until the surrounding transaction ends.
+	ListByTypeForUpdate(typeID int) ([]models.Component, error)
+	CountByType(typeID int) (int64, error)
+	Get(id int) (models.Component, error)
+	// GetForUpdate returns a component and locks it until the surrounding transaction ends.
+	GetForUpdate(id int) (models.Component, error)
+	Create(component *models.Component) error
+	// Save stores a component read at component.Version and increments the version. ErrVersionConflict is
+	// returned if the stored component has another version by now.
+	Save(component *models.Component) error
+	// UpdateStatus, UpdateAttributes and ChangeType increment the version of the component as well.
+	UpdateStatus(id int, status string) error
+	// UpdateAttributes replaces the custom attribute values of a component.
+	UpdateAttributes(id int, values models.AttributeValues) error
+	// ChangeType moves a component to another type together with its converted attribute values.
+	ChangeType(id int, typeID int, values models.AttributeValues) error
+	// DistinctColumnValues returns the distinct non-null values of a built-in column in ascending order.
+	DistinctColumnValues(column string) ([]interface{}, error)
+	// DistinctAttributeValues returns the distinct values of a custom attribute in ascending order.
+	DistinctAttributeValues(name string) ([]string, error)
+	// SerialNumbers returns up to limit serial numbers starting with prefix, ignoring case, in ascending order.
+	SerialNumbers(prefix string, limit int) ([]string, error)
+	// BrandModels returns up to limit distinct brand and model pairs in ascending order whose brand,
+	// model or "brand model" starts with prefix, ignoring case.
+	BrandModels(prefix string, limit int) ([]BrandModel, error)
+	// Facets counts the components matching filter per value of each of columns, ignoring the
+	// limit, offset and sort of filter. The conditions on a column, and TypeIDs for type_id,
+	// do not apply when counting that column, so the counts show what choosing another value
+	// would match. Values are returned as text.
+	Facets(filter ComponentFilter, columns []string) (Facets, error)
+	// Highlights returns, for the given components, a snippet of their searchable text with
+	// the matches of search wrapped in <mark> tags. The snippets are HTML with the stored text
+	// escaped. Stores without full-text search return none.
+	Highlights(ids []int, search string) (map[int]string, error)
+}
+
+// Types stores component types and their schema versions.
+type Types interface {
+	// List returns every type ordered by ID.
+	List() ([]models.ComponentType, error)
+	Get(id int) (models.ComponentType, error)
+	// GetForUpdate returns the types with the given IDs ordered by ID and locks them until
+	// the surrounding transaction ends. ErrNotFound is returned if any of them is missing.
+	GetForUpdate(ids ...int) ([]models.ComponentType, error)
+	Create(componentType *models.ComponentType) error
+	Save(componentType *models.ComponentType) error
+	Delete(id int) error
+	CountChildren(id int) (int64, error)
+	AddSchemaVersion(version *models.ComponentTypeSchemaVersion) error
+	// SchemaVersions returns the schema versions of a type, newest first.
+	SchemaVersions(typeID int) ([]models.ComponentTypeSchemaVersion, error)
+}
+
+// History stores inventory history entries.
+type History interface {
+	Create(entry *models.InventoryHistory) error
+	ListByComponent(componentID int) ([]models.InventoryHistory, error)
+	ListByUser(userID string) ([]models.InventoryHistory, error)
+	// Latest returns the most recent entry of a component.
+	Latest(componentID int) (models.InventoryHistory, error)
+	// CreateChanges records the field changes of a component edit.
+	CreateChanges(changes []models.ComponentChange) error
+	// ListChanges returns the field changes of a component in the order they were made.
+	ListChanges(componentID int) ([]models.ComponentChange, error)
+}
+
+// Store gives access to every repository.
+type Store interface {
+	Components() Components
+	Types() Types
+	History() History
+	// Transaction runs fn with a store whose operations form a single transaction.
+	// The transaction is rolled back if fn returns an error.
+	Transaction(fn func(tx Store) error) error
+}
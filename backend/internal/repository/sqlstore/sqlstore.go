@@ -0,0 +1,531 @@
+// Package sqlstore implements the repositories on a gorm database.
+//
+// Queries are written to run on both Postgres and SQLite: JSON values are read with ->>
+// and cast to text, case-insensitive matching uses LOWER(...) LIKE and the latest history
+// entry of a component is found with a correlated subquery instead of DISTINCT ON.
+// Free text search uses the search_vector column on Postgres and LIKE matching elsewhere.
+package sqlstore
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+
+	"vinventory/internal/lifecycle"
+	"vinventory/internal/models"
+	"vinventory/internal/repository"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Store is a repository.Store backed by a gorm database.
+type Store struct {
+	db *gorm.DB
+}
+
+// New returns a store working on db.
+func New(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+// Components returns the component repository.
+func (s *Store) Components() repository.Components { return components{s.db} }
+
+// Types returns the component type repository.
+func (s *Store) Types() repository.Types { return types{s.db} }
+
+// History returns the inventory history repository.
+func (s *Store) History() repository.History { return history{s.db} }
+
+// Transaction runs fn in a database transaction.
+func (s *Store) Transaction(fn func(tx repository.Store) error) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&Store{db: tx})
+	})
+}
+
+// SyncInUseStatuses stores the statuses in which components are in use, and rebuilds the search_vector
+// column when they change, since on Postgres it only holds the name of the holder of those components.
+// Other databases read the statuses from the lifecycle when searching.
+func (s *Store) SyncInUseStatuses(statuses []string) error {
+	if !(components{s.db}).fullText() {
+		return nil
+	}
+
+	wanted := append([]string{}, statuses...)
+	sort.Strings(wanted)
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var stored []string
+		if err := tx.Raw("SELECT status FROM component_in_use_statuses ORDER BY status FOR UPDATE").Scan(&stored).Error; err != nil {
+			return fmt.Errorf("failed to read the in-use statuses: %w", err)
+		}
+		if strings.Join(stored, "\x00") == strings.Join(wanted, "\x00") {
+			return nil
+		}
+
+		if err := tx.Exec("DELETE FROM component_in_use_statuses").Error; err != nil {
+			return err
+		}
+		for _, status := range wanted {
+			if err := tx.Exec("INSERT INTO component_in_use_statuses (status) VALUES (?) ON CONFLICT DO NOTHING", status).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Exec("UPDATE components SET search_vector = component_search_document(components)").Error
+	})
+}
+
+// translate maps gorm errors to repository errors.
+func translate(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return repository.ErrNotFound
+	}
+	return err
+}
+
+func forUpdate(db *gorm.DB) *gorm.DB {
+	return db.Clauses(clause.Locking{Strength: "UPDATE"})
+}
+
+type components struct{ db *gorm.DB }
+
+func (r components) List(filter repository.ComponentFilter) ([]models.Component, error) {
+	query := r.filtered(filter)
+
+	if words := prefixQuery(filter.Search); words != "" && len(filter.Sort) == 0 && r.fullText() {
+		// Most relevant first; a single clause, as gorm drops expressions when merging ORDER BY
+		query = query.Clauses(clause.OrderBy{Expression: clause.Expr{
+			SQL:  "ts_rank_cd(components.search_vector, to_tsquery('simple', ?)) DESC, components.id",
+			Vars: []interface{}{words},
+		}})
+	} else {
+		for _, key := range filter.Sort {
+			query = query.Order(clause.OrderByColumn{Column: clause.Column{Table: "components", Name: key.Column}, Desc: key.Descending})
+		}
+		query = query.Order("components.id")
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+	if filter.Offset > 0 {
+		query = query.Offset(filter.Offset)
+	}
+
+	var result []models.Component
+	if err := query.Select("components.*").Find(&result).Error; err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (r components) Count(filter repository.ComponentFilter) (int64, error) {
+	var count int64
+	err := r.filtered(filter).Count(&count).Error
+	return count, err
+}
+
+// filtered returns the query selecting the components that match filter.
+func (r components) filtered(filter repository.ComponentFilter) *gorm.DB {
+	query := r.db.Model(&models.Component{})
+
+	for _, condition := range filter.Conditions {
+		query = query.Where(expression(condition))
+	}
+	if filter.TypeIDs != nil {
+		query = query.Where("components.type_id IN ?", filter.TypeIDs)
+	}
+	for name, value := range filter.Attributes {
+		query = query.Where(attributeText("components.attributes")+" = ?", name, value)
+	}
+
+	fullText := r.fullText()
+	if filter.Holders != nil || filter.Search != "" && !fullText {
+		// Each component joins at most its latest history entry
+		query = query.Joins("LEFT JOIN inventory_history last_ih ON last_ih.id = (" +
+			"SELECT ih.id FROM inventory_history ih WHERE ih.component_id = components.id " +
+			"ORDER BY ih.created_at DESC, ih.id DESC LIMIT 1)")
+	}
+	if filter.Holders != nil {
+		query = query.Where("components.status IN (?) AND last_ih.user_id IN (?)", lifecycle.Current().InUse, filter.Holders)
+	}
+
+	switch {
+	case filter.Search == "":
+	case fullText:
+		// Text without any word, such as punctuation only, does not restrict the result
+		if words := prefixQuery(filter.Search); words != "" {
+			query = query.Where("components.search_vector @@ to_tsquery('simple', ?)", words)
+		}
+	default:
+		searchPattern := "%" + strings.ToLower(filter.Search) + "%"
+		query = query.Where("LOWER(components.brand) LIKE ? OR LOWER(components.model) LIKE ? OR LOWER(components.serial_number) LIKE ? OR LOWER(components.notes) LIKE ? "+
+			"OR EXISTS (SELECT 1 FROM component_types t WHERE t.id = components.type_id AND LOWER(t.name) LIKE ?) "+
+			"OR (components.status IN (?) AND LOWER(last_ih.user_name) LIKE ?)",
+			searchPattern, searchPattern, searchPattern, searchPattern, searchPattern, lifecycle.Current().InUse, searchPattern)
+	}
+
+	return query
+}
+
+// fullText reports whether the database has the search_vector column, which only Postgres migrations create.
+func (r components) fullText() bool {
+	return r.db.Dialector.Name() == "postgres"
+}
+
+// prefixQuery turns free text into a tsquery matching documents that contain every word of
+// the text as a prefix. Anything but letters and digits separates words, so the result
+// never holds tsquery operators typed by the user.
+func prefixQuery(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, word := range words {
+		words[i] = word + ":*"
+	}
+	return strings.Join(words, " & ")
+}
+
+func (r components) ListByType(typeID int) ([]models.Component, error) {
+	var result []models.Component
+	if err := r.db.Where("type_id = ?", typeID).Order("id").Find(&result).Error; err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (r components) ListByTypeForUpdate(typeID int) ([]models.Component, error) {
+	var result []models.Component
+	if err := forUpdate(r.db).Where("type_id = ?", typeID).Order("id").Find(&result).Error; err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (r components) CountByType(typeID int) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Component{}).Where("type_id = ?", typeID).Count(&count).Error
+	return count, err
+}
+
+func (r components) Get(id int) (models.Component, error) {
+	var component models.Component
+	err := r.db.First(&component, id).Error
+	return component, translate(err)
+}
+
+func (r components) GetForUpdate(id int) (models.Component, error) {
+	var component models.Component
+	err := forUpdate(r.db).First(&component, id).Error
+	return component, translate(err)
+}
+
+func (r components) Create(component *models.Component) error {
+	return r.db.Create(component).Error
+}
+
+func (r components) Save(component *models.Component) error {
+	read := component.Version
+	component.Version++
+	// Select("*") writes zero values too, as Save does
+	result := r.db.Model(component).Where("version = ?", read).Select("*").Updates(component)
+	if result.Error == nil && result.RowsAffected == 0 {
+		result.Error = repository.ErrVersionConflict
+	}
+	if result.Error != nil {
+		component.Version = read
+	}
+	return result.Error
+}
+
+func (r components) UpdateStatus(id int, status string) error {
+	return r.update(id, map[string]interface{}{"status": status})
+}
+
+func (r components) UpdateAttributes(id int, values models.AttributeValues) error {
+	return r.update(id, map[string]interface{}{"attributes": values})
+}
+
+func (r components) ChangeType(id int, typeID int, values models.AttributeValues) error {
+	return r.update(id, map[string]interface{}{"type_id": typeID, "attributes": values})
+}
+
+// update sets columns of a component and increments its version.
+func (r components) update(id int, columns map[string]interface{}) error {
+	columns["version"] = gorm.Expr("version + 1")
+	return r.db.Model(&models.Component{}).Where("id = ?", id).Updates(columns).Error
+}
+
+func (r components) DistinctColumnValues(column string) ([]interface{}, error) {
+	var values []interface{}
+	err := r.db.Model(&models.Component{}).
+		Distinct(column).
+		Where(column+" IS NOT NULL").
+		Order(column+" ASC").
+		Pluck(column, &values).Error
+	return values, err
+}
+
+func (r components) DistinctAttributeValues(name string) ([]string, error) {
+	var values []string
+	err := r.db.Model(&models.Component{}).
+		Select("DISTINCT "+attributeText("attributes")+" AS value", name).
+		Where(attributeText("attributes")+" IS NOT NULL", name).
+		Order("value ASC").
+		Scan(&values).Error
+	return values, err
+}
+
+func (r components) SerialNumbers(prefix string, limit int) ([]string, error) {
+	var values []string
+	err := r.db.Model(&models.Component{}).
+		Where("LOWER(serial_number) LIKE ? ESCAPE '\\'", likePrefix(prefix)).
+		Order("serial_number").
+		Limit(limit).
+		Pluck("serial_number", &values).Error
+	return values, err
+}
+
+func (r components) BrandModels(prefix string, limit int) ([]repository.BrandModel, error) {
+	pattern := likePrefix(prefix)
+	var pairs []repository.BrandModel
+	err := r.db.Model(&models.Component{}).
+		Select("DISTINCT COALESCE(brand, '') AS brand, COALESCE(model, '') AS model").
+		Where("LOWER(brand) LIKE ? ESCAPE '\\' OR LOWER(model) LIKE ? ESCAPE '\\' OR LOWER(brand || ' ' || model) LIKE ? ESCAPE '\\'",
+			pattern, pattern, pattern).
+		Order("brand, model").
+		Limit(limit).
+		Scan(&pairs).Error
+	return pairs, err
+}
+
+// likePrefix returns the LIKE pattern, with \ as escape character, matching lower-case text starting with prefix.
+func likePrefix(prefix string) string {
+	escaped := strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_").Replace(strings.ToLower(prefix))
+	return escaped + "%"
+}
+
+func (r components) Facets(filter repository.ComponentFilter, columns []string) (repository.Facets, error) {
+	// A single query: the total and each column are counted by one branch of a UNION ALL.
+	// Branches are wrapped in SELECT * FROM (...) as SQLite rejects parenthesized ones.
+	branches := []string{"SELECT * FROM (?) AS total"}
+	queries := []interface{}{
+		r.filtered(filter).Select("CAST('' AS TEXT) AS facet, CAST(NULL AS TEXT) AS value, COUNT(*) AS count"),
+	}
+	for i, column := range columns {
+		target := clause.Column{Table: "components", Name: column}
+		branches = append(branches, fmt.Sprintf("SELECT * FROM (?) AS facet%d", i))
+		queries = append(queries, r.filtered(filter.Without(column)).
+			Select("CAST(? AS TEXT) AS facet, CAST(? AS TEXT) AS value, COUNT(*) AS count", column, target).
+			Clauses(clause.GroupBy{Columns: []clause.Column{target}}))
+	}
+
+	var rows []struct {
+		Facet string
+		Value *string
+		Count int64
+	}
+	if err := r.db.Raw(strings.Join(branches, " UNION ALL "), queries...).Scan(&rows).Error; err != nil {
+		return repository.Facets{}, err
+	}
+
+	facets := repository.Facets{Counts: make(map[string][]repository.FacetCount, len(columns))}
+	for _, row := range rows {
+		if row.Facet == "" {
+			facets.Total = row.Count
+			continue
+		}
+		facets.Counts[row.Facet] = append(facets.Counts[row.Facet], repository.FacetCount{Value: row.Value, Count: row.Count})
+	}
+	return facets, nil
+}
+
+func (r components) Highlights(ids []int, search string) (map[int]string, error) {
+	words := prefixQuery(search)
+	if !r.fullText() || words == "" || len(ids) == 0 {
+		return nil, nil
+	}
+
+	var rows []struct {
+		ID        int
+		Highlight string
+	}
+	// Matches are delimited by control characters, removed from the text beforehand, so the
+	// text can be escaped before the <mark> tags are put in
+	err := r.db.Model(&models.Component{}).
+		Select("components.id, ts_headline('simple', translate("+
+			"concat_ws(' ', components.serial_number, components.brand, components.model, component_types.name, component_holder_name(components), components.notes), ?, ''), "+
+			"to_tsquery('simple', ?), ?) AS highlight",
+			highlightStart+highlightStop, words, "StartSel="+highlightStart+", StopSel="+highlightStop+", MaxWords=20, MinWords=5").
+		Joins("JOIN component_types ON component_types.id = components.type_id").
+		Where("components.id IN ?", ids).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	highlights := make(map[int]string, len(rows))
+	for _, row := range rows {
+		highlights[row.ID] = highlightHTML(row.Highlight)
+	}
+	return highlights, nil
+}
+
+// Delimiters of the matches in the snippets returned by ts_headline.
+const (
+	highlightStart = "\x02"
+	highlightStop  = "\x03"
+)
+
+// highlightHTML escapes a ts_headline snippet as HTML and marks its matches with <mark> tags,
+// so stored text can never inject markup into the page showing it
+func highlightHTML(snippet string) string {
+	return strings.NewReplacer(highlightStart, "<mark>", highlightStop, "</mark>").Replace(html.EscapeString(snippet))
+}
+
+type types struct{ db *gorm.DB }
+
+func (r types) List() ([]models.ComponentType, error) {
+	var result []models.ComponentType
+	if err := r.db.Order("id").Find(&result).Error; err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (r types) Get(id int) (models.ComponentType, error) {
+	var componentType models.ComponentType
+	err := r.db.First(&componentType, id).Error
+	return componentType, translate(err)
+}
+
+func (r types) GetForUpdate(ids ...int) ([]models.ComponentType, error) {
+	// Locking in ID order keeps concurrent transactions from deadlocking
+	var result []models.ComponentType
+	if err := forUpdate(r.db).Where("id IN ?", ids).Order("id").Find(&result).Error; err != nil {
+		return nil, err
+	}
+	if len(result) != len(distinct(ids)) {
+		return nil, repository.ErrNotFound
+	}
+	return result, nil
+}
+
+func (r types) Create(componentType *models.ComponentType) error {
+	return r.db.Create(componentType).Error
+}
+
+func (r types) Save(componentType *models.ComponentType) error {
+	return r.db.Save(componentType).Error
+}
+
+func (r types) Delete(id int) error {
+	return r.db.Delete(&models.ComponentType{}, id).Error
+}
+
+func (r types) CountChildren(id int) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.ComponentType{}).Where("parent_id = ?", id).Count(&count).Error
+	return count, err
+}
+
+func (r types) AddSchemaVersion(version *models.ComponentTypeSchemaVersion) error {
+	return r.db.Create(version).Error
+}
+
+func (r types) SchemaVersions(typeID int) ([]models.ComponentTypeSchemaVersion, error) {
+	var versions []models.ComponentTypeSchemaVersion
+	if err := r.db.Where("type_id = ?", typeID).Order("version DESC").Find(&versions).Error; err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+type history struct{ db *gorm.DB }
+
+func (r history) Create(entry *models.InventoryHistory) error {
+	return r.db.Create(entry).Error
+}
+
+func (r history) ListByComponent(componentID int) ([]models.InventoryHistory, error) {
+	var entries []models.InventoryHistory
+	if err := r.db.Where("component_id = ?", componentID).Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (r history) ListByUser(userID string) ([]models.InventoryHistory, error) {
+	var entries []models.InventoryHistory
+	if err := r.db.Where("user_id = ?", userID).Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (r history) Latest(componentID int) (models.InventoryHistory, error) {
+	var entry models.InventoryHistory
+	err := r.db.Where("component_id = ?", componentID).Order("created_at DESC, id DESC").First(&entry).Error
+	return entry, translate(err)
+}
+
+func (r history) CreateChanges(changes []models.ComponentChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	return r.db.Create(&changes).Error
+}
+
+func (r history) ListChanges(componentID int) ([]models.ComponentChange, error) {
+	var changes []models.ComponentChange
+	if err := r.db.Where("component_id = ?", componentID).Order("created_at, id").Find(&changes).Error; err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// expression returns the SQL of a condition on a components column.
+func expression(condition repository.Condition) clause.Expression {
+	column := clause.Column{Table: "components", Name: condition.Column}
+	values := condition.Values
+
+	switch condition.Operator {
+	case repository.NotEqual:
+		return clause.Neq{Column: column, Value: values[0]}
+	case repository.Greater:
+		return clause.Gt{Column: column, Value: values[0]}
+	case repository.GreaterOrEqual:
+		return clause.Gte{Column: column, Value: values[0]}
+	case repository.Less:
+		return clause.Lt{Column: column, Value: values[0]}
+	case repository.LessOrEqual:
+		return clause.Lte{Column: column, Value: values[0]}
+	case repository.In:
+		return clause.IN{Column: column, Values: values}
+	case repository.Between:
+		return clause.Expr{SQL: "? BETWEEN ? AND ?", Vars: []interface{}{column, values[0], values[1]}}
+	case repository.IsNull:
+		// Text columns hold empty strings for unset values; casting covers every column type
+		return clause.Expr{SQL: "COALESCE(CAST(? AS TEXT), '') = ''", Vars: []interface{}{column}}
+	case repository.NotNull:
+		return clause.Expr{SQL: "COALESCE(CAST(? AS TEXT), '') <> ''", Vars: []interface{}{column}}
+	}
+	return clause.Eq{Column: column, Value: values[0]}
+}
+
+// attributeText returns the SQL reading one key of a JSON column as text; the key is
+// the next query parameter. SQLite returns JSON numbers as numbers, hence the cast.
+func attributeText(column string) string {
+	return "CAST(" + column + " ->> ? AS TEXT)"
+}
+
+func distinct(ids []int) map[int]bool {
+	set := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
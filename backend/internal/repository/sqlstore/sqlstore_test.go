@@ -0,0 +1,25 @@
+package sqlstore
+
+import "testing"
+
+func TestHighlightHTML(t *testing.T) {
+	tests := []struct {
+		snippet string
+		want    string
+	}{
+		{snippet: "SN-1 \x02Dell\x03 Latitude", want: "SN-1 <mark>Dell</mark> Latitude"},
+		{
+			// Markup stored in notes is shown as text
+			snippet: "\x02Dell\x03 Laptop <script>alert(1)</script> & <img src=x onerror=alert(1)>",
+			want:    "<mark>Dell</mark> Laptop &lt;script&gt;alert(1)&lt;/script&gt; &amp; &lt;img src=x onerror=alert(1)&gt;",
+		},
+		{snippet: "R&D \"spare\" \x02dock\x03's", want: "R&amp;D &#34;spare&#34; <mark>dock</mark>&#39;s"},
+		{snippet: "\x02<b>\x03", want: "<mark>&lt;b&gt;</mark>"},
+		{snippet: "", want: ""},
+	}
+	for _, test := range tests {
+		if got := highlightHTML(test.snippet); got != test.want {
+			t.Errorf("highlightHTML(%q) = %q; want %q", test.snippet, got, test.want)
+		}
+	}
+}
@@ -2,8 +2,10 @@ package routes
 
 import (
 	"net/http"
+	"vinventory/internal/auth"
 	"vinventory/internal/handlers"
 	"vinventory/internal/middleware"
+	"vinventory/internal/repository/sqlstore"
 
 	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -11,9 +13,11 @@ import (
 	"gorm.io/gorm"
 )
 
-// SetupRouter initializes the API routes and returns the router
+// SetupRouter initializes the API routes and returns the router. Protected routes declare
+// the permission they require; see auth.Policy for the roles that grant them
 func SetupRouter(db *gorm.DB) *mux.Router {
 	router := mux.NewRouter()
+	store := sqlstore.New(db)
 
 	// Swagger endpoint
 	router.PathPrefix("/swagger/").Handler(httpSwagger.WrapHandler)
@@ -29,35 +33,53 @@ func SetupRouter(db *gorm.DB) *mux.Router {
 	apiV1.Handle("/config", handlers.GetConfigHandler()).Methods(http.MethodGet)
 
 	// Components routes (Protected)
-	apiV1.Handle("/components", middleware.AuthMiddleware(handlers.GetComponents(db))).Methods(http.MethodGet)
-	apiV1.Handle("/components/{id}", middleware.AuthMiddleware(handlers.GetComponentByID(db))).Methods(http.MethodGet)
-	apiV1.Handle("/components", middleware.AuthMiddleware(handlers.CreateComponent(db))).Methods(http.MethodPost)
-	apiV1.Handle("/components/{id}", middleware.AuthMiddleware(handlers.UpdateComponent(db))).Methods(http.MethodPut)
-	apiV1.Handle("/components/{id}/deactivate/{userID}", middleware.AuthMiddleware(handlers.DeactivateComponent(db))).Methods(http.MethodPut)
-	apiV1.Handle("/components/{id}/activate/{userID}", middleware.AuthMiddleware(handlers.ActivateComponent(db))).Methods(http.MethodPut)
-	apiV1.Handle("/components/{id}/last-interactant", middleware.AuthMiddleware(handlers.GetLastInteractant(db))).Methods(http.MethodGet)
-	apiV1.Handle("/components/{id}/inventory-history", middleware.AuthMiddleware(handlers.GetInventoryHistoryByComponentID(db))).Methods(http.MethodGet)
-	apiV1.Handle("/components/{attribute}/uniquevalue", middleware.AuthMiddleware(handlers.GetAttributeValues(db))).Methods(http.MethodGet)
-	apiV1.Handle("/components/{id}/image", middleware.AuthMiddleware(handlers.GetComponentImages())).Methods(http.MethodGet)
-	apiV1.Handle("/components/{id}/image", middleware.AuthMiddleware(handlers.AddComponentImages())).Methods(http.MethodPost)
+	apiV1.Handle("/components", middleware.Require(auth.ReadComponents, handlers.GetComponents(store))).Methods(http.MethodGet)
+	apiV1.Handle("/components/facets", middleware.Require(auth.ReadComponents, handlers.GetComponentFacets(store))).Methods(http.MethodGet)
+	apiV1.Handle("/components/{id}", middleware.Require(auth.ReadComponents, handlers.GetComponentByID(store))).Methods(http.MethodGet)
+	apiV1.Handle("/components", middleware.Require(auth.WriteComponents, handlers.CreateComponent(store))).Methods(http.MethodPost)
+	apiV1.Handle("/components/{id}", middleware.Require(auth.WriteComponents, handlers.UpdateComponent(store))).Methods(http.MethodPut)
+	apiV1.Handle("/components/{id}", middleware.Require(auth.WriteComponents, handlers.PatchComponent(store))).Methods(http.MethodPatch)
+	apiV1.Handle("/components/{id}/deactivate", middleware.Require(auth.OperateComponents, handlers.DeactivateComponent(store))).Methods(http.MethodPut)
+	apiV1.Handle("/components/{id}/activate", middleware.Require(auth.OperateComponents, handlers.ActivateComponent(store))).Methods(http.MethodPut)
+	apiV1.Handle("/components/{id}/deactivate/{userID}", middleware.Require(auth.OperateComponents, handlers.DeactivateComponent(store))).Methods(http.MethodPut)
+	apiV1.Handle("/components/{id}/activate/{userID}", middleware.Require(auth.OperateComponents, handlers.ActivateComponent(store))).Methods(http.MethodPut)
+	apiV1.Handle("/components/{id}/transitions", middleware.Require(auth.OperateComponents, handlers.TransitionComponent(store))).Methods(http.MethodPost)
+	apiV1.Handle("/components/{id}/last-interactant", middleware.Require(auth.ReadComponents, handlers.GetLastInteractant(store))).Methods(http.MethodGet)
+	apiV1.Handle("/components/{id}/inventory-history", middleware.Require(auth.ReadComponents, handlers.GetInventoryHistoryByComponentID(store))).Methods(http.MethodGet)
+	apiV1.Handle("/components/{id}/changes", middleware.Require(auth.ReadComponents, handlers.GetComponentChanges(store))).Methods(http.MethodGet)
+	apiV1.Handle("/components/{attribute}/uniquevalue", middleware.Require(auth.ReadComponents, handlers.GetAttributeValues(store))).Methods(http.MethodGet)
+	apiV1.Handle("/components/{id}/image", middleware.Require(auth.ReadComponents, handlers.GetComponentImages())).Methods(http.MethodGet)
+	apiV1.Handle("/components/{id}/image", middleware.Require(auth.WriteComponents, handlers.AddComponentImages())).Methods(http.MethodPost)
+
+	// Suggestions route (Protected)
+	apiV1.Handle("/suggestions", middleware.Require(auth.ReadComponents, handlers.GetSuggestions(store))).Methods(http.MethodGet)
+
+	// Lifecycle route (Protected)
+	apiV1.Handle("/lifecycle", middleware.Require(auth.ReadComponents, handlers.GetLifecycle())).Methods(http.MethodGet)
 
 	// Component Types routes (Protected)
-	apiV1.Handle("/types", middleware.AuthMiddleware(handlers.GetComponentTypes(db))).Methods(http.MethodGet)
-	apiV1.Handle("/types/{id}", middleware.AuthMiddleware(handlers.GetComponentTypeByID(db))).Methods(http.MethodGet)
-	apiV1.Handle("/types", middleware.AuthMiddleware(handlers.CreateComponentType(db))).Methods(http.MethodPost)
-	apiV1.Handle("/types/{id}", middleware.AuthMiddleware(handlers.UpdateComponentType(db))).Methods(http.MethodPut)
-	apiV1.Handle("/types/{id}", middleware.AuthMiddleware(handlers.DeleteComponentType(db))).Methods(http.MethodDelete)
+	apiV1.Handle("/types", middleware.Require(auth.ReadTypes, handlers.GetComponentTypes(store))).Methods(http.MethodGet)
+	apiV1.Handle("/types/attributes", middleware.Require(auth.ReadTypes, handlers.GetBuiltInAttributes())).Methods(http.MethodGet)
+	apiV1.Handle("/types/{id}", middleware.Require(auth.ReadTypes, handlers.GetComponentTypeByID(store))).Methods(http.MethodGet)
+	apiV1.Handle("/types", middleware.Require(auth.WriteTypes, handlers.CreateComponentType(store))).Methods(http.MethodPost)
+	apiV1.Handle("/types/{id}", middleware.Require(auth.WriteTypes, handlers.UpdateComponentType(store))).Methods(http.MethodPut)
+	apiV1.Handle("/types/{id}", middleware.Require(auth.WriteTypes, handlers.PatchComponentType(store))).Methods(http.MethodPatch)
+	apiV1.Handle("/types/{id}", middleware.Require(auth.WriteTypes, handlers.DeleteComponentType(store))).Methods(http.MethodDelete)
+	apiV1.Handle("/types/{id}/preview", middleware.Require(auth.WriteTypes, handlers.PreviewComponentTypeUpdate(store))).Methods(http.MethodPost)
+	apiV1.Handle("/types/{id}/merge", middleware.Require(auth.WriteTypes, handlers.MergeComponentType(store))).Methods(http.MethodPost)
+	apiV1.Handle("/types/{id}/schema-versions", middleware.Require(auth.ReadTypes, handlers.GetComponentTypeSchemaVersions(store))).Methods(http.MethodGet)
 
 	// User routes (Protected)
-	apiV1.Handle("/users/{id}/inventory-history", middleware.AuthMiddleware(handlers.GetUserInventoryHistory(db))).Methods(http.MethodGet)
+	apiV1.Handle("/users/{id}/inventory-history", middleware.RequireSelfOr(auth.ReadUsers, "id", handlers.GetUserInventoryHistory(store))).Methods(http.MethodGet)
 
 	// Auth routes (Protected)
-	apiV1.Handle("/auth/users", middleware.AuthMiddleware(handlers.GetAllUsers())).Methods(http.MethodPost)
-	apiV1.Handle("/auth/users/{id}", middleware.AuthMiddleware(handlers.GetUserByIDHandler())).Methods(http.MethodGet)
-	apiV1.Handle("/auth/users/{id}/photo", middleware.AuthMiddleware(handlers.GetUserPhotoHandler())).Methods(http.MethodGet)
+	apiV1.Handle("/auth/me", middleware.AuthMiddleware(handlers.GetCurrentUser())).Methods(http.MethodGet)
+	apiV1.Handle("/auth/users", middleware.Require(auth.ListUsers, handlers.GetAllUsers())).Methods(http.MethodPost)
+	apiV1.Handle("/auth/users/{id}", middleware.RequireSelfOr(auth.ReadUsers, "id", handlers.GetUserByIDHandler())).Methods(http.MethodGet)
+	apiV1.Handle("/auth/users/{id}/photo", middleware.RequireSelfOr(auth.ReadUsers, "id", handlers.GetUserPhotoHandler())).Methods(http.MethodGet)
 
 	// Inventory History routes (Protected)
-	apiV1.Handle("/inventory-history", middleware.AuthMiddleware(handlers.CreateInventoryHistory(db))).Methods(http.MethodPost)
+	apiV1.Handle("/inventory-history", middleware.Require(auth.OperateComponents, handlers.CreateInventoryHistory(store))).Methods(http.MethodPost)
 
 	//Prometheus
 	apiV1.Handle("/metrics", promhttp.Handler()).Methods(http.MethodGet)
@@ -0,0 +1,164 @@
+// Package typetree resolves the category hierarchy of component types.
+//
+// A type may have a parent; it inherits the attribute definitions of all its
+// ancestors and is included whenever one of its ancestors is used as a filter.
+package typetree
+
+import (
+	"fmt"
+	"sort"
+
+	"vinventory/internal/models"
+	"vinventory/internal/repository"
+)
+
+// Tree is an in-memory view of every component type and its children.
+type Tree struct {
+	byID     map[int]models.ComponentType
+	children map[int][]int
+}
+
+// New builds a tree from a flat list of types.
+func New(types []models.ComponentType) *Tree {
+	tree := &Tree{
+		byID:     make(map[int]models.ComponentType, len(types)),
+		children: make(map[int][]int),
+	}
+	for _, componentType := range types {
+		tree.byID[componentType.ID] = componentType
+		if componentType.ParentID != nil {
+			tree.children[*componentType.ParentID] = append(tree.children[*componentType.ParentID], componentType.ID)
+		}
+	}
+	for _, ids := range tree.children {
+		sort.Ints(ids)
+	}
+	return tree
+}
+
+// Load reads every component type from the repository.
+func Load(repo repository.Types) (*Tree, error) {
+	types, err := repo.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load component types: %w", err)
+	}
+	return New(types), nil
+}
+
+// Get returns the type with the given ID, with its effective schema resolved.
+func (t *Tree) Get(id int) (models.ComponentType, bool) {
+	componentType, ok := t.byID[id]
+	if !ok {
+		return models.ComponentType{}, false
+	}
+	componentType.EffectiveSchema = t.EffectiveSchema(id)
+	componentType.AttributesList = componentType.EffectiveSchema.Names()
+	return componentType, true
+}
+
+// All returns every type ordered by ID, with effective schemas resolved.
+func (t *Tree) All() []models.ComponentType {
+	ids := make([]int, 0, len(t.byID))
+	for id := range t.byID {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	types := make([]models.ComponentType, 0, len(ids))
+	for _, id := range ids {
+		componentType, _ := t.Get(id)
+		types = append(types, componentType)
+	}
+	return types
+}
+
+// With returns a copy of the tree in which componentType replaces the stored type with the same ID.
+func (t *Tree) With(componentType models.ComponentType) *Tree {
+	types := make([]models.ComponentType, 0, len(t.byID)+1)
+	for id, existing := range t.byID {
+		if id != componentType.ID {
+			types = append(types, existing)
+		}
+	}
+	return New(append(types, componentType))
+}
+
+// Ancestors returns the ancestors of a type, root first, excluding the type itself.
+func (t *Tree) Ancestors(id int) []models.ComponentType {
+	var ancestors []models.ComponentType
+	seen := map[int]bool{id: true}
+	current, ok := t.byID[id]
+	for ok && current.ParentID != nil && !seen[*current.ParentID] {
+		seen[*current.ParentID] = true
+		current, ok = t.byID[*current.ParentID]
+		if ok {
+			ancestors = append([]models.ComponentType{current}, ancestors...)
+		}
+	}
+	return ancestors
+}
+
+// Children returns the IDs of the direct children of a type.
+func (t *Tree) Children(id int) []int {
+	return t.children[id]
+}
+
+// Descendants returns the ID of a type followed by the IDs of all types below it.
+func (t *Tree) Descendants(id int) []int {
+	ids := []int{id}
+	seen := map[int]bool{id: true}
+	for i := 0; i < len(ids); i++ {
+		for _, child := range t.children[ids[i]] {
+			if !seen[child] {
+				seen[child] = true
+				ids = append(ids, child)
+			}
+		}
+	}
+	return ids
+}
+
+// EffectiveSchema returns the attribute definitions of a type including those inherited
+// from its ancestors. A definition on a descendant overrides the inherited one with the same name.
+func (t *Tree) EffectiveSchema(id int) models.AttributeSchema {
+	schema := models.AttributeSchema{}
+	for _, componentType := range append(t.Ancestors(id), t.byID[id]) {
+		for _, definition := range componentType.Schema {
+			replaced := false
+			for i := range schema {
+				if schema[i].Name == definition.Name {
+					schema[i] = definition
+					replaced = true
+					break
+				}
+			}
+			if !replaced {
+				schema = append(schema, definition)
+			}
+		}
+	}
+	return schema
+}
+
+// Inherited reports whether a type inherits the named attribute from one of its ancestors.
+func (t *Tree) Inherited(id int, name string) bool {
+	for _, ancestor := range t.Ancestors(id) {
+		if _, ok := ancestor.Schema.Find(name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckParent verifies that parentID exists and that making it the parent of id does not create a cycle.
+func (t *Tree) CheckParent(id int, parentID int) error {
+	if _, ok := t.byID[parentID]; !ok {
+		return fmt.Errorf("parent component type %d not found", parentID)
+	}
+	for _, descendant := range t.Descendants(id) {
+		if descendant == parentID {
+			return fmt.Errorf("component type %d cannot be placed under itself or one of its sub-types", id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+// Package migrations embeds the versioned SQL files that define the database schema.
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+)
+
+// files holds the NNN_name.up.sql and NNN_name.down.sql files of every supported database,
+// one directory per gorm dialect name.
+//
+//go:embed postgres/*.sql sqlite/*.sql
+var files embed.FS
+
+// For returns the migrations of a database dialect ("postgres" or "sqlite").
+func For(dialect string) (fs.FS, error) {
+	switch dialect {
+	case "postgres", "sqlite":
+		return fs.Sub(files, dialect)
+	default:
+		return nil, fmt.Errorf("no migrations for database dialect %q", dialect)
+	}
+}
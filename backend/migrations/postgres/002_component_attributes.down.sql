@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_component_attributes;
+
+ALTER TABLE components DROP COLUMN IF EXISTS attributes;
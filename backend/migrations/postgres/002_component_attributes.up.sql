@@ -0,0 +1,4 @@
+-- Values of the custom attributes a component type declares, keyed by attribute name
+ALTER TABLE components ADD COLUMN attributes JSONB NOT NULL DEFAULT '{}'::jsonb;
+
+CREATE INDEX idx_component_attributes ON components USING GIN (attributes);
@@ -0,0 +1,9 @@
+ALTER TABLE component_types ADD COLUMN attributes TEXT[] NOT NULL DEFAULT ARRAY['status', 'condition'];
+
+UPDATE component_types SET attributes = ARRAY(
+    SELECT definition ->> 'name'
+    FROM jsonb_array_elements(attribute_schema) WITH ORDINALITY AS e(definition, position)
+    ORDER BY position
+);
+
+ALTER TABLE component_types DROP COLUMN attribute_schema;
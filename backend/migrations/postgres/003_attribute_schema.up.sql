@@ -0,0 +1,31 @@
+-- Replace the bare attribute names of component types with typed attribute definitions
+ALTER TABLE component_types ADD COLUMN attribute_schema JSONB NOT NULL DEFAULT '[]'::jsonb;
+
+UPDATE component_types SET attribute_schema = COALESCE((
+    SELECT jsonb_agg(
+        CASE a.name
+            WHEN 'serialNumber' THEN '{"name": "serialNumber", "type": "string", "required": true}'::jsonb
+            WHEN 'status' THEN '{"name": "status", "type": "enum", "required": false, "allowedValues": ["Ready to Use", "Being Used", "Out of Inventory"]}'::jsonb
+            WHEN 'brand' THEN '{"name": "brand", "type": "string", "required": false}'::jsonb
+            WHEN 'model' THEN '{"name": "model", "type": "string", "required": false}'::jsonb
+            WHEN 'modelYear' THEN '{"name": "modelYear", "type": "int", "required": false}'::jsonb
+            WHEN 'condition' THEN '{"name": "condition", "type": "enum", "required": false, "allowedValues": ["Functioning", "Slightly Damaged", "Broken"]}'::jsonb
+            WHEN 'screenSize' THEN '{"name": "screenSize", "type": "string", "required": false, "unit": "inch"}'::jsonb
+            WHEN 'resolution' THEN '{"name": "resolution", "type": "string", "required": false}'::jsonb
+            WHEN 'processorType' THEN '{"name": "processorType", "type": "string", "required": false}'::jsonb
+            WHEN 'processorCores' THEN '{"name": "processorCores", "type": "int", "required": false}'::jsonb
+            WHEN 'ram' THEN '{"name": "ram", "type": "int", "required": false, "unit": "GB"}'::jsonb
+            WHEN 'warrantyEndDate' THEN '{"name": "warrantyEndDate", "type": "date", "required": true}'::jsonb
+            WHEN 'notes' THEN '{"name": "notes", "type": "string", "required": false}'::jsonb
+            -- Custom attributes were all required before they had a schema
+            ELSE jsonb_build_object('name', a.name, 'type', 'string', 'required', true)
+        END
+        ORDER BY a.position)
+    FROM (
+        SELECT DISTINCT ON (name) name, position
+        FROM unnest(component_types.attributes) WITH ORDINALITY AS u(name, position)
+        ORDER BY name, position
+    ) AS a
+), '[]'::jsonb);
+
+ALTER TABLE component_types DROP COLUMN attributes;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS component_type_schema_versions;
+
+ALTER TABLE component_types DROP COLUMN IF EXISTS schema_version;
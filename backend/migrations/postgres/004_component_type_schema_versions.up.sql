@@ -0,0 +1,16 @@
+ALTER TABLE component_types ADD COLUMN schema_version INT NOT NULL DEFAULT 1;
+
+CREATE TABLE component_type_schema_versions (
+    id INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    type_id INT NOT NULL REFERENCES component_types(id) ON DELETE CASCADE,
+    version INT NOT NULL,
+    name TEXT NOT NULL,
+    attribute_schema JSONB NOT NULL,
+    change JSONB NOT NULL DEFAULT '{}'::jsonb,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (type_id, version)
+);
+
+-- Existing types start their history at version 1
+INSERT INTO component_type_schema_versions (type_id, version, name, attribute_schema)
+SELECT id, 1, name, attribute_schema FROM component_types;
@@ -0,0 +1,8 @@
+-- Postgres cannot drop a single enum value, so the type is rebuilt without it
+DELETE FROM inventory_history WHERE operation_type = 'Type Changed';
+
+ALTER TYPE inventory_operation_type RENAME TO inventory_operation_type_old;
+CREATE TYPE inventory_operation_type AS ENUM ('Assigned', 'Returned', 'Added', 'Deactivated', 'Activated');
+ALTER TABLE inventory_history
+    ALTER COLUMN operation_type TYPE inventory_operation_type USING operation_type::text::inventory_operation_type;
+DROP TYPE inventory_operation_type_old;
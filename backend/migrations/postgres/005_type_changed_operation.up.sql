@@ -0,0 +1,3 @@
+-- Needs Postgres 12 or newer, as migrations run in a transaction (see migrate.MinimumPostgresVersion)
+-- Recorded for every component moved to another type by a merge
+ALTER TYPE inventory_operation_type ADD VALUE IF NOT EXISTS 'Type Changed';
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_component_type_parent_id;
+
+ALTER TABLE component_types DROP COLUMN IF EXISTS parent_id;
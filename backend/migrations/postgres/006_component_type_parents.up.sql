@@ -0,0 +1,4 @@
+-- Component types can be grouped under a parent category and inherit its attributes
+ALTER TABLE component_types ADD COLUMN parent_id INT REFERENCES component_types(id) ON DELETE RESTRICT;
+
+CREATE INDEX idx_component_type_parent_id ON component_types(parent_id);
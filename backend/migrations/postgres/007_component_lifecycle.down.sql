@@ -0,0 +1,15 @@
+-- Statuses and operations outside the original enums cannot be represented any more
+UPDATE components SET status = 'Out of Inventory'
+    WHERE status NOT IN ('Being Used', 'Out of Inventory', 'Ready to Use');
+DELETE FROM inventory_history
+    WHERE operation_type NOT IN ('Assigned', 'Returned', 'Added', 'Deactivated', 'Activated', 'Type Changed');
+
+CREATE TYPE component_status AS ENUM ('Being Used', 'Out of Inventory', 'Ready to Use');
+CREATE TYPE inventory_operation_type AS ENUM ('Assigned', 'Returned', 'Added', 'Deactivated', 'Activated', 'Type Changed');
+
+ALTER TABLE components ALTER COLUMN status DROP DEFAULT;
+ALTER TABLE components ALTER COLUMN status TYPE component_status USING status::component_status;
+ALTER TABLE components ALTER COLUMN status SET DEFAULT 'Ready to Use';
+
+ALTER TABLE inventory_history
+    ALTER COLUMN operation_type TYPE inventory_operation_type USING operation_type::inventory_operation_type;
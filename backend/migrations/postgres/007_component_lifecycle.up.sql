@@ -0,0 +1,9 @@
+-- Statuses and history operations are defined by the configurable lifecycle, not by enums
+ALTER TABLE components ALTER COLUMN status DROP DEFAULT;
+ALTER TABLE components ALTER COLUMN status TYPE TEXT USING status::text;
+ALTER TABLE components ALTER COLUMN status SET DEFAULT 'Ready to Use';
+
+ALTER TABLE inventory_history ALTER COLUMN operation_type TYPE TEXT USING operation_type::text;
+
+DROP TYPE component_status;
+DROP TYPE inventory_operation_type;
@@ -0,0 +1,12 @@
+DROP TRIGGER component_types_search_vector ON component_types;
+DROP TRIGGER inventory_history_search_vector ON inventory_history;
+DROP TRIGGER components_search_vector ON components;
+
+DROP FUNCTION component_types_search_vector_update();
+DROP FUNCTION inventory_history_search_vector_update();
+DROP FUNCTION components_search_vector_update();
+DROP FUNCTION component_search_document(components);
+DROP FUNCTION component_holder_name(components);
+
+DROP INDEX components_search_vector_idx;
+ALTER TABLE components DROP COLUMN search_vector;
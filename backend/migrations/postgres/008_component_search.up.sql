@@ -0,0 +1,59 @@
+-- Full-text search over components. search_vector holds the serial number, brand and model,
+-- the type name, the name of the current holder and the notes, weighted in that order.
+-- Triggers keep it up to date when a component, its history or its type changes.
+ALTER TABLE components ADD COLUMN search_vector tsvector;
+
+CREATE FUNCTION component_holder_name(c components) RETURNS text
+LANGUAGE sql STABLE AS $$
+    SELECT ih.user_name FROM inventory_history ih
+    WHERE c.status = 'Being Used' AND ih.component_id = c.id
+    ORDER BY ih.created_at DESC, ih.id DESC
+    LIMIT 1
+$$;
+
+CREATE FUNCTION component_search_document(c components) RETURNS tsvector
+LANGUAGE sql STABLE AS $$
+    SELECT
+        setweight(to_tsvector('simple', concat_ws(' ', c.serial_number, c.brand, c.model)), 'A') ||
+        setweight(to_tsvector('simple', coalesce((SELECT t.name FROM component_types t WHERE t.id = c.type_id), '')), 'B') ||
+        setweight(to_tsvector('simple', coalesce(component_holder_name(c), '')), 'B') ||
+        setweight(to_tsvector('simple', coalesce(c.notes, '')), 'C')
+$$;
+
+CREATE FUNCTION components_search_vector_update() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    NEW.search_vector := component_search_document(NEW);
+    RETURN NEW;
+END
+$$;
+
+CREATE FUNCTION inventory_history_search_vector_update() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    UPDATE components SET search_vector = component_search_document(components) WHERE id = NEW.component_id;
+    RETURN NULL;
+END
+$$;
+
+CREATE FUNCTION component_types_search_vector_update() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    UPDATE components SET search_vector = component_search_document(components) WHERE type_id = NEW.id;
+    RETURN NULL;
+END
+$$;
+
+CREATE TRIGGER components_search_vector BEFORE INSERT OR UPDATE ON components
+    FOR EACH ROW EXECUTE PROCEDURE components_search_vector_update();
+
+CREATE TRIGGER inventory_history_search_vector AFTER INSERT ON inventory_history
+    FOR EACH ROW EXECUTE PROCEDURE inventory_history_search_vector_update();
+
+CREATE TRIGGER component_types_search_vector AFTER UPDATE OF name ON component_types
+    FOR EACH ROW WHEN (OLD.name IS DISTINCT FROM NEW.name)
+    EXECUTE PROCEDURE component_types_search_vector_update();
+
+UPDATE components SET search_vector = component_search_document(components);
+
+CREATE INDEX components_search_vector_idx ON components USING GIN (search_vector);
@@ -0,0 +1 @@
+ALTER TABLE components DROP COLUMN version;
@@ -0,0 +1,2 @@
+-- Every change to a component increments its version, which clients send back in If-Match
+ALTER TABLE components ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
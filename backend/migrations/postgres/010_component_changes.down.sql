@@ -0,0 +1 @@
+DROP TABLE component_changes;
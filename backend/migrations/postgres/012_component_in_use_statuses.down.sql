@@ -0,0 +1,11 @@
+CREATE OR REPLACE FUNCTION component_holder_name(c components) RETURNS text
+LANGUAGE sql STABLE AS $$
+    SELECT ih.user_name FROM inventory_history ih
+    WHERE c.status = 'Being Used' AND ih.component_id = c.id
+    ORDER BY ih.created_at DESC, ih.id DESC
+    LIMIT 1
+$$;
+
+DROP TABLE component_in_use_statuses;
+
+UPDATE components SET search_vector = component_search_document(components);
@@ -0,0 +1,16 @@
+-- The statuses in which a component is held by the user of its latest history entry, so that the
+-- holder name in search_vector follows the configured lifecycle. The server keeps the table in
+-- line with the lifecycle in use at startup.
+CREATE TABLE component_in_use_statuses (
+    status TEXT PRIMARY KEY
+);
+
+INSERT INTO component_in_use_statuses (status) VALUES ('Being Used');
+
+CREATE OR REPLACE FUNCTION component_holder_name(c components) RETURNS text
+LANGUAGE sql STABLE AS $$
+    SELECT ih.user_name FROM inventory_history ih
+    WHERE c.status IN (SELECT status FROM component_in_use_statuses) AND ih.component_id = c.id
+    ORDER BY ih.created_at DESC, ih.id DESC
+    LIMIT 1
+$$;
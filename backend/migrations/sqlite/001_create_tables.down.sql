@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS component_type_schema_versions;
+DROP TABLE IF EXISTS inventory_history;
+DROP TABLE IF EXISTS components;
+DROP TABLE IF EXISTS component_types;
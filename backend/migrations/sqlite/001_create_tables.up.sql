@@ -0,0 +1,55 @@
+-- SQLite starts from the schema Postgres reaches at version 007; later versions are kept in step.
+-- JSON columns hold text and statuses are plain text governed by the configurable lifecycle.
+CREATE TABLE component_types (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT UNIQUE NOT NULL,
+    attribute_schema TEXT NOT NULL DEFAULT '[]',
+    schema_version INTEGER NOT NULL DEFAULT 1,
+    parent_id INTEGER REFERENCES component_types(id) ON DELETE RESTRICT
+);
+
+CREATE TABLE components (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    status TEXT NOT NULL DEFAULT 'Ready to Use',
+    brand TEXT,
+    model TEXT,
+    model_year INTEGER,
+    type_id INTEGER NOT NULL REFERENCES component_types(id),
+    screen_size TEXT,
+    resolution TEXT,
+    processor_type TEXT,
+    processor_cores INTEGER,
+    ram INTEGER,
+    warranty_end_date DATETIME,
+    serial_number TEXT UNIQUE,
+    condition TEXT NOT NULL CHECK (condition IN ('Functioning', 'Slightly Damaged', 'Broken')),
+    notes TEXT,
+    email_notified BOOLEAN,
+    attributes TEXT NOT NULL DEFAULT '{}'
+);
+
+CREATE TABLE inventory_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    component_id INTEGER NOT NULL REFERENCES components(id),
+    user_id TEXT NOT NULL,
+    operation_type TEXT NOT NULL,
+    user_name TEXT
+);
+
+CREATE TABLE component_type_schema_versions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    type_id INTEGER NOT NULL REFERENCES component_types(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    attribute_schema TEXT NOT NULL,
+    change TEXT NOT NULL DEFAULT '{}',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (type_id, version)
+);
+
+CREATE INDEX idx_component_status ON components(status);
+CREATE INDEX idx_component_type_id ON components(type_id);
+CREATE INDEX idx_component_condition ON components(condition);
+CREATE INDEX idx_component_type_parent_id ON component_types(parent_id);
+CREATE INDEX idx_inventory_history_component_id ON inventory_history(component_id, created_at);
@@ -0,0 +1,2 @@
+-- Full-text search is Postgres only; SQLite keeps matching the search text with LIKE.
+SELECT 1;
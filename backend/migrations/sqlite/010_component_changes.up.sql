@@ -0,0 +1,14 @@
+-- Field level record of component edits; assignments and other operations stay in inventory_history
+CREATE TABLE component_changes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    component_id INTEGER NOT NULL REFERENCES components(id),
+    version INTEGER NOT NULL,
+    user_id TEXT NOT NULL,
+    user_name TEXT,
+    field TEXT NOT NULL,
+    old_value TEXT,
+    new_value TEXT
+);
+
+CREATE INDEX idx_component_changes_component_id ON component_changes(component_id, created_at);
@@ -0,0 +1,2 @@
+ALTER TABLE inventory_history DROP COLUMN recorded_by_name;
+ALTER TABLE inventory_history DROP COLUMN recorded_by_id;
@@ -0,0 +1,4 @@
+-- The signed-in user who recorded an entry, which differs from user_id when acting on someone's behalf.
+-- Entries recorded before are left without one.
+ALTER TABLE inventory_history ADD COLUMN recorded_by_id TEXT;
+ALTER TABLE inventory_history ADD COLUMN recorded_by_name TEXT;
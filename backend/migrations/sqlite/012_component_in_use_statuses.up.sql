@@ -0,0 +1,2 @@
+-- Holder searches are Postgres only; SQLite reads the in-use statuses from the lifecycle when searching.
+SELECT 1;
@@ -1,7 +1,12 @@
 import React, { useEffect, useState } from "react";
 import { Form } from "antd";
 import client from "../../client/client";
-import { FilterOptionsProps, OptionType } from "../../types/FilterInterfaces";
+import {
+  FacetsResponse,
+  FilterOptionsProps,
+  FilterValues,
+  OptionType,
+} from "../../types/FilterInterfaces";
 import FilterForm from "./FilterForm";
 import "../../styles/FilterOptions.css";
 
@@ -20,27 +25,39 @@ const FilterOptions: React.FC<FilterOptionsProps> = ({ onFiltersChange }) => {
   const [modelYearOptions, setModelYearOptions] = useState<OptionType[]>([]);
   const [typeOptions, setTypeOptions] = useState<OptionType[]>([]);
 
-  useEffect(() => {
-    const fetchOptions = async (
-      endpoint: string,
-      setOptions: React.Dispatch<React.SetStateAction<OptionType[]>>,
-    ) => {
-      try {
-        const url = `components/${endpoint}/uniquevalue`;
-        const response = await client.get<(number | string)[]>(url);
+  // Counts for each field ignore the filter on that field, so other choices stay visible
+  const fetchFacets = async (filters: FilterValues) => {
+    try {
+      const queryParams = new URLSearchParams();
+      Object.entries(filters).forEach(([key, value]) => {
+        if (value) {
+          queryParams.append(key, value.toString());
+        }
+      });
+      const response = await client.get<FacetsResponse>(
+        `components/facets?${queryParams.toString()}`,
+      );
 
-        const options: OptionType[] = response.data
-          .filter((value): value is number | string => value !== "")
-          .map((value: number | string) => ({
-            value: value,
-            label: value.toString(),
+      const toOptions = (field: string): OptionType[] =>
+        (response.data.facets[field] ?? [])
+          .filter((facet) => facet.value !== null)
+          .map((facet) => ({
+            value: facet.value as number | string,
+            label: `${facet.value} (${facet.count})`,
           }));
-        setOptions(options);
-      } catch (error) {
-        console.error(`Error fetching ${endpoint} options:`, error);
-      }
-    };
+      setRamOptions(toOptions("ram"));
+      setStatusOptions(toOptions("status"));
+      setConditionOptions(toOptions("condition"));
+      setProcessorTypeOptions(toOptions("processorType"));
+      setProcessorCoresOptions(toOptions("processorCores"));
+      setScreenSizeOptions(toOptions("screenSize"));
+      setModelYearOptions(toOptions("modelYear"));
+    } catch (error) {
+      console.error("Error fetching filter options:", error);
+    }
+  };
 
+  useEffect(() => {
     const fetchTypes = async () => {
       try {
         const url = `/types`;
@@ -59,24 +76,20 @@ const FilterOptions: React.FC<FilterOptionsProps> = ({ onFiltersChange }) => {
       }
     };
 
-    fetchOptions("ram", setRamOptions);
-    fetchOptions("status", setStatusOptions);
-    fetchOptions("condition", setConditionOptions);
-    fetchOptions("processor_type", setProcessorTypeOptions);
-    fetchOptions("processor_cores", setProcessorCoresOptions);
-    fetchOptions("screen_size", setScreenSizeOptions);
-    fetchOptions("model_year", setModelYearOptions);
+    fetchFacets({});
     fetchTypes();
   }, []);
 
   const handleValuesChange = () => {
     const values = form.getFieldsValue();
     onFiltersChange(values);
+    fetchFacets(values);
   };
 
   const handleClear = () => {
     form.resetFields();
     onFiltersChange({});
+    fetchFacets({});
   };
 
   return (
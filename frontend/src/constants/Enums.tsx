@@ -8,11 +8,14 @@ export enum Status {
   OutOfInventory = "Out of Inventory",
   BeingUsed = "Being Used",
   ReadyToUse = "Ready to Use",
+  InRepair = "In Repair",
+  Lost = "Lost",
+  Retired = "Retired",
 }
 
 export enum SortOption {
-  modelYear = "model_year",
-  processorCores = "processor_cores",
+  modelYear = "modelYear",
+  processorCores = "processorCores",
   ram = "ram",
-  warrantyEndDate = "warranty_end_date",
+  warrantyEndDate = "warrantyEndDate",
 }
@@ -10,8 +10,10 @@ import {
   Dropdown,
   Menu,
   Input,
+  AutoComplete,
   Upload,
   Image,
+  Tabs,
 } from "antd";
 import {
   InfoCircleOutlined,
@@ -23,7 +25,15 @@ import {
 } from "@ant-design/icons";
 import client from "../client/client";
 import { Status, Condition, SortOption } from "../constants";
-import { Component, User, ComponentType, InventoryHistory } from "../types";
+import {
+  Component,
+  User,
+  ComponentType,
+  InventoryHistory,
+  InventoryHistoryRequest,
+  ComponentChange,
+  Suggestion,
+} from "../types";
 import {
   EditComponent,
   ActionLink,
@@ -49,6 +59,13 @@ interface ItemsProps {
   currentUserData?: AccountInfo | null;
 }
 
+const PAGE_SIZE = 10;
+
+// Sends the version the user has seen, so the server refuses to overwrite newer changes
+const ifMatch = (component: Component) => ({
+  headers: { "If-Match": `"${component.version}"` },
+});
+
 const Items: React.FC<ItemsProps> = ({
   filters,
   currentUserData,
@@ -66,12 +83,19 @@ const Items: React.FC<ItemsProps> = ({
   const [inventoryHistory, setInventoryHistory] = useState<InventoryHistory[]>(
     [],
   );
+  const [componentChanges, setComponentChanges] = useState<ComponentChange[]>(
+    [],
+  );
   const [historyVisible, setHistoryVisible] = useState(false);
   const [assignVisible, setAssignVisible] = useState(false);
-  const [users, setUsers] = useState<User[]>([]);
+  const [userSuggestions, setUserSuggestions] = useState<Suggestion[]>([]);
+  const [searchSuggestions, setSearchSuggestions] = useState<Suggestion[]>(
+    [],
+  );
   const [selectedUser, setSelectedUser] = useState<string | null>(null);
   const [editMode, setEditMode] = useState(false);
   const [currentPage, setCurrentPage] = useState<number>(1);
+  const [total, setTotal] = useState<number>(0);
   const [hasErrors, setHasErrors] = useState<boolean>(false);
   const [sort, setSort] = useState<string>("");
   const [order, setOrder] = useState<string>("asc");
@@ -94,8 +118,11 @@ const Items: React.FC<ItemsProps> = ({
       }
     });
     if (searchInput) queryParams.append("search", searchInput);
-    if (sort) queryParams.append("sort", sort);
-    if (order) queryParams.append("order", order);
+    if (sort) {
+      queryParams.append("sort", order === "desc" ? `-${sort}` : sort);
+    }
+    queryParams.append("limit", PAGE_SIZE.toString());
+    queryParams.append("offset", ((currentPage - 1) * PAGE_SIZE).toString());
 
     return queryParams.toString();
   };
@@ -112,7 +139,8 @@ const Items: React.FC<ItemsProps> = ({
         );
         const url = `components?${queryParams}`;
         const response = await client.get(url);
-        const components = response.data;
+        const components = response.data.data;
+        setTotal(response.data.total);
 
         // Fetch last interactant user for each component if status is 'Being Used'
         const componentsWithUser = await Promise.all(
@@ -144,7 +172,7 @@ const Items: React.FC<ItemsProps> = ({
         setLoading(false);
       }
     },
-    [filters, sort, order],
+    [filters, sort, order, currentPage],
   );
   const debouncedFetchData = useMemo(
     () => debounce(fetchData, 300),
@@ -230,10 +258,12 @@ const Items: React.FC<ItemsProps> = ({
 
   const showInventoryHistory = async (componentId: number) => {
     try {
-      const response = await client.get(
-        `components/${componentId}/inventory-history`,
-      );
+      const [response, changesResponse] = await Promise.all([
+        client.get(`components/${componentId}/inventory-history`),
+        client.get(`components/${componentId}/changes`),
+      ]);
       const history = response.data;
+      const changes: ComponentChange[] = changesResponse.data;
 
       // Fetch user details for each history entry
       const historyWithUserDetails = await Promise.all(
@@ -257,24 +287,60 @@ const Items: React.FC<ItemsProps> = ({
       );
 
       setInventoryHistory(historyWithUserDetails);
+      setComponentChanges(
+        changes.sort(
+          (a, b) =>
+            dayjs(b.createdAt).valueOf() - dayjs(a.createdAt).valueOf() ||
+            b.id - a.id,
+        ),
+      );
       setHistoryVisible(true);
     } catch (error) {
       console.error("Error fetching inventory history:", error);
     }
   };
 
-  const showAssignModal = async (component: Component) => {
-    setAssignComponent(component);
-    setSelectedUser(null); // Reset selected user
+  const fetchSuggestions = async (
+    prefix: string,
+    kinds?: string,
+  ): Promise<Suggestion[]> => {
+    if (!prefix.trim()) return [];
+    const queryParams = new URLSearchParams({ q: prefix });
+    if (kinds) queryParams.append("kinds", kinds);
     try {
-      const response = await client.post("auth/users");
-      setUsers(response.data);
-      setAssignVisible(true);
+      const response = await client.get(
+        `suggestions?${queryParams.toString()}`,
+      );
+      return response.data;
     } catch (error) {
-      console.error("Error fetching users:", error);
+      console.error("Error fetching suggestions:", error);
+      return [];
     }
   };
 
+  const debouncedSearchSuggestions = useMemo(
+    () =>
+      debounce(async (prefix: string) => {
+        setSearchSuggestions(await fetchSuggestions(prefix));
+      }, 300),
+    [],
+  );
+
+  const debouncedUserSuggestions = useMemo(
+    () =>
+      debounce(async (prefix: string) => {
+        setUserSuggestions(await fetchSuggestions(prefix, "user"));
+      }, 300),
+    [],
+  );
+
+  const showAssignModal = (component: Component) => {
+    setAssignComponent(component);
+    setSelectedUser(null); // Reset selected user
+    setUserSuggestions([]);
+    setAssignVisible(true);
+  };
+
   const handleAssign = async () => {
     if (!assignComponent || !selectedUser) {
       message.error("Please select a user.");
@@ -282,13 +348,10 @@ const Items: React.FC<ItemsProps> = ({
     }
 
     try {
-      const inventoryHistoryEntry: InventoryHistory = {
-        id: 0,
-        createdAt: new Date().toISOString(),
+      const inventoryHistoryEntry: InventoryHistoryRequest = {
         componentId: assignComponent.id,
-        userId: selectedUser,
         operationType: "Assigned",
-        userName: "",
+        onBehalfOf: selectedUser,
       };
 
       await client.post("inventory-history", inventoryHistoryEntry);
@@ -309,13 +372,10 @@ const Items: React.FC<ItemsProps> = ({
     }
 
     try {
-      const inventoryHistoryEntry: InventoryHistory = {
-        id: 0,
-        createdAt: new Date().toISOString(),
+      const inventoryHistoryEntry: InventoryHistoryRequest = {
         componentId: component.id,
-        userId: component.user.id,
         operationType: "Returned",
-        userName: "",
+        onBehalfOf: component.user.id,
       };
 
       await client.post("inventory-history", inventoryHistoryEntry);
@@ -392,7 +452,12 @@ const Items: React.FC<ItemsProps> = ({
               : null,
         };
 
-        await client.put(`components/${aboutComponent.id}`, updatedComponent);
+        const response = await client.put(
+          `components/${aboutComponent.id}`,
+          updatedComponent,
+          ifMatch(aboutComponent),
+        );
+        const savedComponent: Component = response.data;
 
         if (uploadedFiles.length > 0) {
           const formData = new FormData();
@@ -410,7 +475,7 @@ const Items: React.FC<ItemsProps> = ({
 
         setData((prevData) =>
           prevData.map((component) =>
-            component.id === aboutComponent.id ? updatedComponent : component,
+            component.id === aboutComponent.id ? savedComponent : component,
           ),
         );
         setEditMode(false);
@@ -419,12 +484,31 @@ const Items: React.FC<ItemsProps> = ({
         setComponentImages([]);
         setAboutComponent(null);
       } catch (error) {
+        if (handleVersionConflict(error)) return;
         console.error("Error saving changes:", error);
         NotificationUtil.showFailNotification("Item");
       }
     }
   };
 
+  // Loads the current state of a component someone else has changed meanwhile
+  const handleVersionConflict = (error: unknown): boolean => {
+    if (!(error instanceof AxiosError) || error.response?.status !== 412) {
+      return false;
+    }
+    const current: Component = error.response.data.current;
+    message.warning(
+      "This item has been changed by someone else. Its latest state is shown; review it and try again.",
+    );
+    setAboutComponent(current);
+    setData((prevData) =>
+      prevData.map((component) =>
+        component.id === current.id ? current : component,
+      ),
+    );
+    return true;
+  };
+
   const handleErrorChange = (errors: boolean) => {
     setHasErrors(errors);
   };
@@ -435,13 +519,17 @@ const Items: React.FC<ItemsProps> = ({
         switch (aboutComponent.status) {
           case Status.OutOfInventory:
             await client.put(
-              `components/${aboutComponent.id}/activate/${currentUserData?.id}`,
+              `components/${aboutComponent.id}/activate`,
+              null,
+              ifMatch(aboutComponent),
             );
             break;
           case Status.ReadyToUse:
           case Status.BeingUsed:
             await client.put(
-              `components/${aboutComponent.id}/deactivate/${currentUserData?.id}`,
+              `components/${aboutComponent.id}/deactivate`,
+              null,
+              ifMatch(aboutComponent),
             );
             break;
           default:
@@ -451,6 +539,7 @@ const Items: React.FC<ItemsProps> = ({
         setEditMode(false);
         setAboutComponent(null);
       } catch (error) {
+        if (handleVersionConflict(error)) return;
         console.error("Error while activating item:", error);
       }
     }
@@ -541,6 +630,15 @@ const Items: React.FC<ItemsProps> = ({
           case Status.ReadyToUse:
             color = "lightgreen";
             break;
+          case Status.InRepair:
+            color = "orange";
+            break;
+          case Status.Lost:
+            color = "red";
+            break;
+          case Status.Retired:
+            color = "black";
+            break;
           default:
             color = "lightgreen";
         }
@@ -675,6 +773,44 @@ const Items: React.FC<ItemsProps> = ({
       render: (user: User | undefined, record: InventoryHistory) =>
         user ? `${user.displayName}` : record.userName,
     },
+    {
+      title: "Recorded By",
+      dataIndex: "recordedByName",
+      key: "recordedByName",
+      render: (recordedByName: string | undefined) => recordedByName || "-",
+    },
+  ];
+
+  const columnsChangesTable: ColumnsType<ComponentChange> = [
+    {
+      title: "Date",
+      dataIndex: "createdAt",
+      key: "createdAt",
+      render: (createdAt: string) =>
+        dayjs(createdAt).format("MMMM Do YYYY, h:mm:ss a"),
+    },
+    {
+      title: "Field",
+      dataIndex: "field",
+      key: "field",
+    },
+    {
+      title: "Before",
+      dataIndex: "oldValue",
+      key: "oldValue",
+      render: (value: string | null) => value ?? "-",
+    },
+    {
+      title: "After",
+      dataIndex: "newValue",
+      key: "newValue",
+      render: (value: string | null) => value ?? "-",
+    },
+    {
+      title: "User",
+      dataIndex: "userName",
+      key: "userName",
+    },
   ];
 
   const getOrderButtonText = () => {
@@ -701,8 +837,9 @@ const Items: React.FC<ItemsProps> = ({
     setOrder((prevOrder) => (prevOrder === "asc" ? "desc" : "asc"));
   };
 
-  const handleSearchChange = (event: React.ChangeEvent<HTMLInputElement>) => {
-    setSearchInput(event.target.value);
+  const handleSearchChange = (value: string) => {
+    setSearchInput(value);
+    debouncedSearchSuggestions(value);
   };
 
   const getTitle = (component: Component): string => {
@@ -735,23 +872,34 @@ const Items: React.FC<ItemsProps> = ({
   return (
     <>
       <div className="searchContainer">
-        <Input
-          placeholder="Search by Serial Number, Brand, Model or User..."
-          size="large"
+        <AutoComplete
+          value={searchInput}
+          options={searchSuggestions.map((suggestion) => ({
+            value: suggestion.query,
+            label: `${suggestion.label} (${suggestion.kind})`,
+          }))}
           onChange={handleSearchChange}
           className="searchInput"
-        />
+        >
+          <Input
+            placeholder="Search by Serial Number, Brand, Model or User, or filter like ram>=16 status:Lost..."
+            size="large"
+          />
+        </AutoComplete>
         <Dropdown
           className="DropDownOrder"
           overlay={
             <Menu
               onClick={handleMenuClick}
               items={[
-                { key: "model_year", label: "Order By Model Year" },
-                { key: "processor_cores", label: "Order By Processor Cores" },
-                { key: "ram", label: "Order By RAM" },
+                { key: SortOption.modelYear, label: "Order By Model Year" },
+                {
+                  key: SortOption.processorCores,
+                  label: "Order By Processor Cores",
+                },
+                { key: SortOption.ram, label: "Order By RAM" },
                 {
-                  key: "warranty_end_date",
+                  key: SortOption.warrantyEndDate,
                   label: "Order By Warranty End Date",
                 },
               ]}
@@ -772,7 +920,12 @@ const Items: React.FC<ItemsProps> = ({
         dataSource={data}
         rowKey="id"
         loading={loading}
-        pagination={{ current: currentPage, onChange: handlePageChange }}
+        pagination={{
+          current: currentPage,
+          pageSize: PAGE_SIZE,
+          total: total,
+          onChange: handlePageChange,
+        }}
       />
       {aboutComponent && (
         <Modal
@@ -889,16 +1042,40 @@ const Items: React.FC<ItemsProps> = ({
           </Button>
         }
       >
-        {inventoryHistory.length > 0 ? (
-          <Table
-            dataSource={inventoryHistory}
-            columns={columnsHistoryTable}
-            rowKey="id"
-            pagination={false}
-          />
-        ) : (
-          <p>No history available for this component.</p>
-        )}
+        <Tabs
+          items={[
+            {
+              key: "operations",
+              label: "Operations",
+              children:
+                inventoryHistory.length > 0 ? (
+                  <Table
+                    dataSource={inventoryHistory}
+                    columns={columnsHistoryTable}
+                    rowKey="id"
+                    pagination={false}
+                  />
+                ) : (
+                  <p>No history available for this component.</p>
+                ),
+            },
+            {
+              key: "edits",
+              label: "Edits",
+              children:
+                componentChanges.length > 0 ? (
+                  <Table
+                    dataSource={componentChanges}
+                    columns={columnsChangesTable}
+                    rowKey="id"
+                    pagination={false}
+                  />
+                ) : (
+                  <p>This component has not been edited.</p>
+                ),
+            },
+          ]}
+        />
       </Modal>
       <Modal
         title="Assign Component"
@@ -908,18 +1085,16 @@ const Items: React.FC<ItemsProps> = ({
       >
         <Select
           showSearch
-          placeholder="Select a user"
+          placeholder="Type a name or email"
           style={{ width: "100%" }}
           value={selectedUser} // Ensure selectedUser is controlled
           onChange={(value: string) => setSelectedUser(value)}
-          filterOption={(input, option) =>
-            option?.props.children.toLowerCase().indexOf(input.toLowerCase()) >=
-            0
-          }
+          onSearch={debouncedUserSuggestions}
+          filterOption={false} // The server matches users by name or email
         >
-          {users.map((user: User) => (
-            <Option key={user.id} value={user.id}>
-              {user.displayName}
+          {userSuggestions.map((suggestion: Suggestion) => (
+            <Option key={suggestion.id} value={suggestion.id}>
+              {suggestion.label}
             </Option>
           ))}
         </Select>
@@ -58,7 +58,6 @@ const NewItem = ({ currentUserData }: BodyType) => {
 
       const requestPayload = {
         component: formattedValues,
-        userId: currentUserData?.id,
       };
 
       await client.post("components", requestPayload);
@@ -18,6 +18,7 @@ export interface Component {
   ram: number | null;
   warrantyEndDate: string;
   notes: string;
+  version: number;
 }
 
 export interface UserComponent {
@@ -1,7 +1,28 @@
+export type AttributeDataType =
+  | "string"
+  | "int"
+  | "decimal"
+  | "date"
+  | "bool"
+  | "enum";
+
+export interface AttributeDefinition {
+  name: string;
+  type: AttributeDataType;
+  required: boolean;
+  allowedValues?: string[];
+  unit?: string;
+  pattern?: string;
+}
+
 export interface ComponentType {
   id: number;
   name: string;
+  parentId: number | null;
   attributes: string[];
+  attributeSchema: AttributeDefinition[];
+  effectiveSchema: AttributeDefinition[];
+  schemaVersion: number;
 }
 
 export interface NewComponentTypeForm {
@@ -46,3 +46,14 @@ export interface FilterMenuProps {
   collapsed: boolean;
   onFilterMenuChange: (filters: FilterValues) => void;
 }
+
+export interface FacetValue {
+  value: number | string | null;
+  label?: string;
+  count: number;
+}
+
+export interface FacetsResponse {
+  total: number;
+  facets: { [field: string]: FacetValue[] };
+}
@@ -7,9 +7,18 @@ export interface InventoryHistory {
   userId: string;
   operationType: string;
   userName: string;
+  recordedById?: string;
+  recordedByName?: string;
   user?: User;
 }
 
+// The entry is recorded for the signed-in user unless onBehalfOf names someone else
+export interface InventoryHistoryRequest {
+  componentId: number;
+  operationType: string;
+  onBehalfOf?: string;
+}
+
 export interface UserInventoryHistory {
   id: string;
   createdAt: string;
@@ -17,3 +26,15 @@ export interface UserInventoryHistory {
   componentId: number;
   componentSerialNumber?: string;
 }
+
+export interface ComponentChange {
+  id: number;
+  createdAt: string;
+  componentId: number;
+  version: number;
+  userId: string;
+  userName: string;
+  field: string;
+  oldValue: string | null;
+  newValue: string | null;
+}
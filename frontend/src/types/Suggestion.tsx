@@ -0,0 +1,8 @@
+export type SuggestionKind = "serialNumber" | "model" | "type" | "user";
+
+export interface Suggestion {
+  kind: SuggestionKind;
+  label: string;
+  query: string;
+  id?: string;
+}
@@ -4,3 +4,4 @@ export * from "./ComponentType";
 export * from "./InventoryHistory";
 export * from "./NewItemForm";
 export * from "./BodyType";
+export * from "./Suggestion";